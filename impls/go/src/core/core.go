@@ -68,7 +68,7 @@ func time_ms(a []MalType) (MalType, error) {
 
 // Hash Map functions
 func copy_hash_map(hm HashMap) HashMap {
-	new_hm := HashMap{map[string]MalType{}, nil}
+	new_hm := HashMap{map[MalType]MalType{}, nil}
 	for k, v := range hm.Val {
 		new_hm.Val[k] = v
 	}
@@ -88,10 +88,10 @@ func assoc(a []MalType) (MalType, error) {
 	new_hm := copy_hash_map(a[0].(HashMap))
 	for i := 1; i < len(a); i += 2 {
 		key := a[i]
-		if !String_Q(key) {
-			return nil, errors.New("assoc called with non-string key")
+		if !HashMapKey_Q(key) {
+			return nil, errors.New("assoc called with non-string or keyword key")
 		}
-		new_hm.Val[key.(string)] = a[i+1]
+		new_hm.Val[key] = a[i+1]
 	}
 	return new_hm, nil
 }
@@ -106,10 +106,10 @@ func dissoc(a []MalType) (MalType, error) {
 	new_hm := copy_hash_map(a[0].(HashMap))
 	for i := 1; i < len(a); i += 1 {
 		key := a[i]
-		if !String_Q(key) {
-			return nil, errors.New("dissoc called with non-string key")
+		if !HashMapKey_Q(key) {
+			return nil, errors.New("dissoc called with non-string or keyword key")
 		}
-		delete(new_hm.Val, key.(string))
+		delete(new_hm.Val, key)
 	}
 	return new_hm, nil
 }
@@ -121,10 +121,10 @@ func get(a []MalType) (MalType, error) {
 	if !HashMap_Q(a[0]) {
 		return nil, errors.New("get called on non-hash map")
 	}
-	if !String_Q(a[1]) {
-		return nil, errors.New("get called with non-string key")
+	if !HashMapKey_Q(a[1]) {
+		return nil, errors.New("get called with non-string or keyword key")
 	}
-	return a[0].(HashMap).Val[a[1].(string)], nil
+	return a[0].(HashMap).Val[a[1]], nil
 }
 
 func contains_Q(hm MalType, key MalType) (MalType, error) {
@@ -134,10 +134,10 @@ func contains_Q(hm MalType, key MalType) (MalType, error) {
 	if !HashMap_Q(hm) {
 		return nil, errors.New("get called on non-hash map")
 	}
-	if !String_Q(key) {
-		return nil, errors.New("get called with non-string key")
+	if !HashMapKey_Q(key) {
+		return nil, errors.New("get called with non-string or keyword key")
 	}
-	_, ok := hm.(HashMap).Val[key.(string)]
+	_, ok := hm.(HashMap).Val[key]
 	return ok, nil
 }
 
@@ -329,15 +329,18 @@ func conj(a []MalType) (MalType, error) {
 	}
 
 	if !HashMap_Q(a[0]) {
-		return nil, errors.New("dissoc called on non-hash map")
+		return nil, errors.New("conj called on non-list, vector or hash-map")
 	}
 	new_hm := copy_hash_map(a[0].(HashMap))
 	for i := 1; i < len(a); i += 1 {
-		key := a[i]
-		if !String_Q(key) {
-			return nil, errors.New("dissoc called with non-string key")
+		entry, ok := a[i].(Vector)
+		if !ok || len(entry.Val) != 2 {
+			return nil, errors.New("conj on a hash-map called with non-[key value] vector")
+		}
+		if !HashMapKey_Q(entry.Val[0]) {
+			return nil, errors.New("conj on a hash-map called with non-string or keyword key")
 		}
-		delete(new_hm.Val, key.(string))
+		new_hm.Val[entry.Val[0]] = entry.Val[1]
 	}
 	return new_hm, nil
 }
@@ -451,7 +454,7 @@ var NS = map[string]MalType{
 	"false?":  call1b(False_Q),
 	"symbol":  call1e(func(a []MalType) (MalType, error) { return Symbol{a[0].(string)}, nil }),
 	"symbol?": call1b(Symbol_Q),
-	"string?": call1e(func(a []MalType) (MalType, error) { return (String_Q(a[0]) && !Keyword_Q(a[0])), nil }),
+	"string?": call1b(String_Q),
 	"keyword": call1e(func(a []MalType) (MalType, error) {
 		if Keyword_Q(a[0]) {
 			return a[0], nil
@@ -31,10 +31,10 @@ func Pr_str(obj types.MalType, print_readably bool) string {
 			str_list = append(str_list, Pr_str(v, print_readably))
 		}
 		return "{" + strings.Join(str_list, " ") + "}"
+	case types.Keyword:
+		return ":" + tobj.Val
 	case string:
-		if strings.HasPrefix(tobj, "\u029e") {
-			return ":" + tobj[2:len(tobj)]
-		} else if print_readably {
+		if print_readably {
 			return `"` + strings.Replace(
 				strings.Replace(
 					strings.Replace(tobj, `\`, `\\`, -1),
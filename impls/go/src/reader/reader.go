@@ -67,14 +67,7 @@ func read_atom(rdr Reader) (MalType, error) {
 		return i, nil
 	} else if match, _ :=
 		  regexp.MatchString(`^"(?:\\.|[^\\"])*"$`, *token); match {
-		str := (*token)[1 : len(*token)-1]
-		return strings.Replace(
-			strings.Replace(
-			 strings.Replace(
-			  strings.Replace(str, `\\`, "\u029e", -1),
-			  `\"`, `"`, -1),
-			 `\n`, "\n", -1),
-			"\u029e", "\\", -1), nil
+		return unescape((*token)[1 : len(*token)-1]), nil
 	} else if (*token)[0] == '"' {
 		return nil, errors.New("expected '\"', got EOF")
 	} else if (*token)[0] == ':' {
@@ -91,6 +84,29 @@ func read_atom(rdr Reader) (MalType, error) {
 	return token, nil
 }
 
+// unescape replaces the escape sequences in the body of a string literal
+// in a single pass, so that no character needs to stand in for a backslash.
+func unescape(str string) string {
+	var b strings.Builder
+	for i := 0; i < len(str); i++ {
+		if str[i] == '\\' && i+1 < len(str) {
+			i++
+			switch str[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case '\\', '"':
+				b.WriteByte(str[i])
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(str[i])
+			}
+			continue
+		}
+		b.WriteByte(str[i])
+	}
+	return b.String()
+}
+
 func read_list(rdr Reader, start string, end string) (MalType, error) {
 	token := rdr.next()
 	if token == nil {
@@ -50,7 +50,7 @@ func eval_ast(ast MalType, env map[string]MalType) (MalType, error) {
 		return Vector{lst, nil}, nil
 	} else if HashMap_Q(ast) {
 		m := ast.(HashMap)
-		new_hm := HashMap{map[string]MalType{}, nil}
+		new_hm := HashMap{map[MalType]MalType{}, nil}
 		for k, v := range m.Val {
 			kv, e2 := EVAL(v, env)
 			if e2 != nil {
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
-	"strings"
 )
 
 // Errors/Exceptions
@@ -57,13 +56,17 @@ func Symbol_Q(obj MalType) bool {
 }
 
 // Keywords
+type Keyword struct {
+	Val string
+}
+
 func NewKeyword(s string) (MalType, error) {
-	return "\u029e" + s, nil
+	return Keyword{s}, nil
 }
 
 func Keyword_Q(obj MalType) bool {
-	s, ok := obj.(string)
-	return ok && strings.HasPrefix(s, "\u029e")
+	_, ok := obj.(Keyword)
+	return ok
 }
 
 // Strings
@@ -164,11 +167,19 @@ func GetSlice(seq MalType) ([]MalType, error) {
 }
 
 // Hash Maps
+//
+// Keys are strings or keywords; both are comparable, so they are used as Go
+// map keys directly and a string never collides with the keyword of the
+// same name.
 type HashMap struct {
-	Val  map[string]MalType
+	Val  map[MalType]MalType
 	Meta MalType
 }
 
+func HashMapKey_Q(obj MalType) bool {
+	return String_Q(obj) || Keyword_Q(obj)
+}
+
 func NewHashMap(seq MalType) (MalType, error) {
 	lst, e := GetSlice(seq)
 	if e != nil {
@@ -177,13 +188,12 @@ func NewHashMap(seq MalType) (MalType, error) {
 	if len(lst)%2 == 1 {
 		return nil, errors.New("Odd number of arguments to NewHashMap")
 	}
-	m := map[string]MalType{}
+	m := map[MalType]MalType{}
 	for i := 0; i < len(lst); i += 2 {
-		str, ok := lst[i].(string)
-		if !ok {
-			return nil, errors.New("expected hash-map key string")
+		if !HashMapKey_Q(lst[i]) {
+			return nil, errors.New("expected hash-map key string or keyword")
 		}
-		m[str] = lst[i+1]
+		m[lst[i]] = lst[i+1]
 	}
 	return HashMap{m, nil}, nil
 }
@@ -0,0 +1,119 @@
+// Package conformance reads the test files of mal, tests/step*.mal, so
+// that an interpreter can run them itself rather than through runtest.py.
+//
+// A test file is a sequence of forms, one per line, each followed by the
+// lines it should print, as regular expressions after ";/", and the result
+// it should print, after ";=>".  Lines starting with ";;;" are comments, and
+// those starting with ";;" are messages for whoever runs the tests.  A line
+// ";>>> soft=True" makes failures of the tests after it soft, which is to
+// say they are reported without failing, and ";>>> deferrable=True" and
+// ";>>> optional=True" start the sections of tests that an implementation
+// can leave until later steps, or not pass at all.
+package conformance
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Section is the part of a test file a test is in.
+type Section int
+
+const (
+	Required Section = iota
+	Deferrable
+	Optional
+)
+
+func (s Section) String() string {
+	switch s {
+	case Deferrable:
+		return "deferrable"
+	case Optional:
+		return "optional"
+	}
+	return "required"
+}
+
+// Case is a test: a form and what evaluating it should print.
+type Case struct {
+	File string
+	// Line is the line of the form.
+	Line int
+	Form string
+	// Out is a regular expression matching the lines the form should
+	// print, and Ret is what its result should print as.  With neither,
+	// the form is evaluated but what it prints is not checked.
+	Out     string
+	Ret     string
+	Soft    bool
+	Section Section
+}
+
+// Parse returns the tests in src, the contents of the test file name.
+func Parse(name, src string) ([]Case, error) {
+	var cases []Case
+	soft := false
+	section := Required
+	lines := strings.Split(src, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "", strings.HasPrefix(line, ";;"):
+			continue
+		case strings.HasPrefix(line, ";>>> "):
+			for _, setting := range strings.Split(line[5:], ",") {
+				switch strings.ReplaceAll(setting, " ", "") {
+				case "soft=True":
+					soft = true
+				case "soft=False":
+					soft = false
+				case "deferrable=True":
+					section = max(section, Deferrable)
+				case "optional=True":
+					section = Optional
+				}
+			}
+			continue
+		case strings.HasPrefix(line, ";"):
+			return nil, fmt.Errorf("%s:%d: unexpected comment: %s", name, i+1, line)
+		}
+
+		c := Case{File: name, Line: i + 1, Form: line, Soft: soft, Section: section}
+		var out []string
+		for ; i+1 < len(lines); i++ {
+			next := lines[i+1]
+			if strings.HasPrefix(next, ";/") {
+				out = append(out, next[2:])
+				continue
+			}
+			if strings.HasPrefix(next, ";=>") {
+				c.Ret = next[3:]
+				i++
+			}
+			break
+		}
+		c.Out = strings.Join(out, "\n")
+		if len(out) > 0 && c.Ret != "" {
+			c.Out += "\n"
+		}
+		cases = append(cases, c)
+	}
+	return cases, nil
+}
+
+// Match reports whether output, what the form printed followed by what its
+// result printed as, is what c expects.  The patterns of test files are
+// Python regular expressions, a few of which Go cannot compile; Match
+// returns an error for those.
+func (c Case) Match(output string) (bool, error) {
+	if c.Out == "" && c.Ret == "" {
+		return true, nil
+	}
+	re, err := regexp.Compile(`(?s)(?:\A|.*\n)` + c.Out + regexp.QuoteMeta(c.Ret))
+	if err != nil {
+		return false, fmt.Errorf("%s:%d: %s", c.File, c.Line, err)
+	}
+	return re.MatchString(output), nil
+}
@@ -0,0 +1,96 @@
+package conformance
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	src := strings.Join([]string{
+		";; A message",
+		";;; A comment",
+		"(prn 1)",
+		";/1",
+		";=>nil",
+		"",
+		"(do (prn 1) (prn 2))",
+		";/1",
+		";/2",
+		"(def! x 3)",
+		"x",
+		";=>3",
+		";>>> soft=True",
+		"(+ 1 1)",
+		";=>2",
+		";>>> deferrable=True",
+		"(+ 2 2)",
+		";=>4",
+		";>>> soft=False, optional=True",
+		"(+ 3 3)",
+		";=>6",
+		// A later deferrable=True does not end the optional tests.
+		";>>> deferrable=True",
+		"(+ 4 4)",
+		";=>8",
+	}, "\n")
+	want := []Case{
+		{File: "t.mal", Line: 3, Form: "(prn 1)", Out: "1\n", Ret: "nil"},
+		{File: "t.mal", Line: 7, Form: "(do (prn 1) (prn 2))", Out: "1\n2"},
+		{File: "t.mal", Line: 10, Form: "(def! x 3)"},
+		{File: "t.mal", Line: 11, Form: "x", Ret: "3"},
+		{File: "t.mal", Line: 14, Form: "(+ 1 1)", Ret: "2", Soft: true},
+		{File: "t.mal", Line: 17, Form: "(+ 2 2)", Ret: "4", Soft: true, Section: Deferrable},
+		{File: "t.mal", Line: 20, Form: "(+ 3 3)", Ret: "6", Section: Optional},
+		{File: "t.mal", Line: 23, Form: "(+ 4 4)", Ret: "8", Section: Optional},
+	}
+	got, err := Parse("t.mal", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d cases, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("case %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseBadComment(t *testing.T) {
+	_, err := Parse("t.mal", "(+ 1 1)\n;=>2\n; not a test line\n")
+	if err == nil || !strings.Contains(err.Error(), "t.mal:3: unexpected comment") {
+		t.Errorf("got %v, want an unexpected comment error for line 3", err)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	for _, c := range []struct {
+		c      Case
+		output string
+		want   bool
+	}{
+		{Case{Out: "1\n", Ret: "nil"}, "1\nnil", true},
+		{Case{Out: "1\n", Ret: "nil"}, "0\n1\nnil", true},
+		{Case{Out: "1\n", Ret: "nil"}, "21\nnil", false},
+		{Case{Out: "1\n", Ret: "nil"}, "1\n3", false},
+		{Case{Out: `.*\(1 2\).*` + "\n", Ret: "nil"}, "got (1 2) here\nnil", true},
+		// What a result prints as is not a pattern.
+		{Case{Ret: "(1 2)"}, "(1 2)", true},
+		{Case{Ret: "(1 2)"}, "1 2", false},
+		{Case{Form: "(def! x 3)"}, "anything", true},
+	} {
+		got, err := c.c.Match(c.output)
+		if err != nil {
+			t.Errorf("%+v: %v", c.c, err)
+		} else if got != c.want {
+			t.Errorf("%+v matching %q: got %v, want %v", c.c, c.output, got, c.want)
+		}
+	}
+
+	// Go has no lookbehind, which Python patterns can use.
+	c := Case{File: "t.mal", Line: 4, Out: `(?<=a)b` + "\n", Ret: "nil"}
+	if _, err := c.Match("ab\nnil"); err == nil || !strings.HasPrefix(err.Error(), "t.mal:4: ") {
+		t.Errorf("got %v, want an error for line 4", err)
+	}
+}
@@ -0,0 +1,983 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	. "github.com/jdugan1024/jdgo/printer"
+	. "github.com/jdugan1024/jdgo/reader"
+	. "github.com/jdugan1024/jdgo/types"
+)
+
+// NS holds the core functions that are defined in Go, keyed by the name they
+// are bound to in the REPL environment.
+var NS = map[string]func(...MalType) (MalType, error){
+	"=":           equal,
+	"identical?":  identical,
+	"throw":       throw,
+	"nil?":        isNil,
+	"true?":       isTrue,
+	"false?":      isFalse,
+	"symbol":      symbol,
+	"symbol?":     isSymbol,
+	"string?":     isString,
+	"keyword":     keyword,
+	"keyword?":    isKeyword,
+	"number?":     isNumber,
+	"fn?":         isFn,
+	"macro?":      isMacro,
+	"pr-str":      prStr,
+	"str":         str,
+	"prn":         prn,
+	"println":     println,
+	"read-string": readString,
+	"slurp":       slurp,
+	"<":           compareInts("<", func(a, b int) bool { return a < b }),
+	"<=":          compareInts("<=", func(a, b int) bool { return a <= b }),
+	">":           compareInts(">", func(a, b int) bool { return a > b }),
+	">=":          compareInts(">=", func(a, b int) bool { return a >= b }),
+	"+":           arithmetic("+", func(a, b int) int { return a + b }),
+	"-":           arithmetic("-", func(a, b int) int { return a - b }),
+	"*":           arithmetic("*", func(a, b int) int { return a * b }),
+	"/":           divide,
+	"time-ms":     timeMs,
+	"list":        list,
+	"list?":       isList,
+	"vector":      vector,
+	"vector?":     isVector,
+	"hash-map":    hashMap,
+	"map?":        isMap,
+	"hash-set":    hashSet,
+	"set":         set,
+	"set?":        isSet,
+	"disj":        disj,
+	"assoc":       assoc,
+	"dissoc":      dissoc,
+	"get":         get,
+	"contains?":   contains,
+	"keys":        keys,
+	"vals":        vals,
+	"sequential?": isSequential,
+	"cons":        cons,
+	"vec":         vec,
+	"nth":         nth,
+	"first":       first,
+	"rest":        rest,
+	"empty?":      isEmpty,
+	"count":       count,
+	"conj":        conj,
+	"peek":        peek,
+	"pop":         pop,
+	"seq":         seq,
+	"with-meta":   withMeta,
+	"meta":        meta,
+	"atom":        atom,
+	"atom?":       isAtom,
+	"deref":       deref,
+	"reset!":      reset,
+}
+
+// DynamicNS holds the core functions that call the functions they are
+// given.  Each is also given the dynamic var bindings of its caller, which
+// it calls them with.
+var DynamicNS = map[string]func(*Bindings, ...MalType) (MalType, error){
+	"apply":     apply,
+	"vary-meta": varyMeta,
+	"swap!":     swap,
+}
+
+func checkArgs(name string, args []MalType, n int) error {
+	if len(args) != n {
+		return fmt.Errorf("%s: wrong number of arguments (%d instead of %d)", name, len(args), n)
+	}
+	return nil
+}
+
+func checkMinArgs(name string, args []MalType, n int) error {
+	if len(args) < n {
+		return fmt.Errorf("%s: wrong number of arguments (%d, expected at least %d)", name, len(args), n)
+	}
+	return nil
+}
+
+func toInt(v MalType) (int, error) {
+	i, ok := v.(*Int)
+	if !ok {
+		return 0, fmt.Errorf("argument is not an Int: %s", v.Print(true))
+	}
+	return i.AsInt(), nil
+}
+
+func toString(v MalType) (string, error) {
+	s, ok := v.(*String)
+	if !ok || s.IsKeyword() {
+		return "", fmt.Errorf("argument is not a String: %s", v.Print(true))
+	}
+	return s.Value(), nil
+}
+
+// toHashMap returns v as a HashMap; a record is converted to the map of
+// its entries.
+func toHashMap(v MalType) (*HashMap, error) {
+	switch hm := v.(type) {
+	case *HashMap:
+		return hm, nil
+	case *Record:
+		return hm.Map(), nil
+	}
+	return nil, fmt.Errorf("argument is not a HashMap: %s", v.Print(true))
+}
+
+func toSet(v MalType) (*Set, error) {
+	s, ok := v.(*Set)
+	if !ok {
+		return nil, fmt.Errorf("argument is not a Set: %s", v.Print(true))
+	}
+	return s, nil
+}
+
+func isType[T MalType](name string) func(...MalType) (MalType, error) {
+	return func(args ...MalType) (MalType, error) {
+		if err := checkArgs(name, args, 1); err != nil {
+			return nil, err
+		}
+		_, ok := args[0].(T)
+		return NewBoolean(ok), nil
+	}
+}
+
+var (
+	isNil    = isType[*Nil]("nil?")
+	isSymbol = isType[*Symbol]("symbol?")
+	isNumber = isType[*Int]("number?")
+	isList   = isType[*List]("list?")
+	isVector = isType[*Vector]("vector?")
+	isSet    = isType[*Set]("set?")
+	isAtom   = isType[*Atom]("atom?")
+)
+
+// isMap reports whether its argument is a map; records are maps too.
+func isMap(args ...MalType) (MalType, error) {
+	if err := checkArgs("map?", args, 1); err != nil {
+		return nil, err
+	}
+	switch args[0].(type) {
+	case *HashMap, *Record:
+		return TrueValue, nil
+	}
+	return FalseValue, nil
+}
+
+func equal(args ...MalType) (MalType, error) {
+	if err := checkArgs("=", args, 2); err != nil {
+		return nil, err
+	}
+	return NewBoolean(Equal(args[0], args[1])), nil
+}
+
+// identical reports whether its arguments are the same value.  Symbols and
+// keywords are interned, so the same name is always identical.
+func identical(args ...MalType) (MalType, error) {
+	if err := checkArgs("identical?", args, 2); err != nil {
+		return nil, err
+	}
+	return NewBoolean(args[0] == args[1]), nil
+}
+
+func throw(args ...MalType) (MalType, error) {
+	if err := checkArgs("throw", args, 1); err != nil {
+		return nil, err
+	}
+	return nil, NewMalError(args[0])
+}
+
+func isTrue(args ...MalType) (MalType, error) {
+	if err := checkArgs("true?", args, 1); err != nil {
+		return nil, err
+	}
+	_, ok := args[0].(*Boolean)
+	return NewBoolean(ok && Truthy(args[0])), nil
+}
+
+func isFalse(args ...MalType) (MalType, error) {
+	if err := checkArgs("false?", args, 1); err != nil {
+		return nil, err
+	}
+	_, ok := args[0].(*Boolean)
+	return NewBoolean(ok && !Truthy(args[0])), nil
+}
+
+func symbol(args ...MalType) (MalType, error) {
+	if err := checkArgs("symbol", args, 1); err != nil {
+		return nil, err
+	}
+	s, err := toString(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return NewSymbol(s), nil
+}
+
+func isString(args ...MalType) (MalType, error) {
+	if err := checkArgs("string?", args, 1); err != nil {
+		return nil, err
+	}
+	s, ok := args[0].(*String)
+	return NewBoolean(ok && !s.IsKeyword()), nil
+}
+
+func keyword(args ...MalType) (MalType, error) {
+	if err := checkArgs("keyword", args, 1); err != nil {
+		return nil, err
+	}
+	s, ok := args[0].(*String)
+	if !ok {
+		return nil, fmt.Errorf("argument is not a String: %s", args[0].Print(true))
+	}
+	if s.IsKeyword() {
+		return s, nil
+	}
+	return NewKeyword(s.Value()), nil
+}
+
+func isKeyword(args ...MalType) (MalType, error) {
+	if err := checkArgs("keyword?", args, 1); err != nil {
+		return nil, err
+	}
+	s, ok := args[0].(*String)
+	return NewBoolean(ok && s.IsKeyword()), nil
+}
+
+func isFn(args ...MalType) (MalType, error) {
+	if err := checkArgs("fn?", args, 1); err != nil {
+		return nil, err
+	}
+	switch f := args[0].(type) {
+	case *Function:
+		return TrueValue, nil
+	case *Closure:
+		return NewBoolean(!f.IsMacro()), nil
+	case *MultiFn:
+		return TrueValue, nil
+	}
+	return FalseValue, nil
+}
+
+func isMacro(args ...MalType) (MalType, error) {
+	if err := checkArgs("macro?", args, 1); err != nil {
+		return nil, err
+	}
+	f, ok := args[0].(*Closure)
+	return NewBoolean(ok && f.IsMacro()), nil
+}
+
+// realizeAll realizes any lazy sequences in args so that errors raised while
+// doing so are reported instead of being printed.
+func realizeAll(args []MalType) error {
+	for _, v := range args {
+		if err := Realize(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func prStr(args ...MalType) (MalType, error) {
+	if err := realizeAll(args); err != nil {
+		return nil, err
+	}
+	return NewString(PrintList(args, true, " ")), nil
+}
+
+func str(args ...MalType) (MalType, error) {
+	if err := realizeAll(args); err != nil {
+		return nil, err
+	}
+	return NewString(PrintList(args, false, "")), nil
+}
+
+func prn(args ...MalType) (MalType, error) {
+	if err := realizeAll(args); err != nil {
+		return nil, err
+	}
+	fmt.Println(PrintList(args, true, " "))
+	return NilValue, nil
+}
+
+func println(args ...MalType) (MalType, error) {
+	if err := realizeAll(args); err != nil {
+		return nil, err
+	}
+	fmt.Println(PrintList(args, false, " "))
+	return NilValue, nil
+}
+
+func readString(args ...MalType) (MalType, error) {
+	if err := checkArgs("read-string", args, 1); err != nil {
+		return nil, err
+	}
+	s, err := toString(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return NewReader(Tokenize(s)).ReadForm()
+}
+
+func slurp(args ...MalType) (MalType, error) {
+	if err := checkArgs("slurp", args, 1); err != nil {
+		return nil, err
+	}
+	filename, err := toString(args[0])
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return NewString(string(b)), nil
+}
+
+func compareInts(name string, cmp func(a, b int) bool) func(...MalType) (MalType, error) {
+	return func(args ...MalType) (MalType, error) {
+		if err := checkArgs(name, args, 2); err != nil {
+			return nil, err
+		}
+		a, err := toInt(args[0])
+		if err != nil {
+			return nil, err
+		}
+		b, err := toInt(args[1])
+		if err != nil {
+			return nil, err
+		}
+		return NewBoolean(cmp(a, b)), nil
+	}
+}
+
+func arithmetic(name string, op func(a, b int) int) func(...MalType) (MalType, error) {
+	return func(args ...MalType) (MalType, error) {
+		// (+) and (*) return the identity so they can start a reduction.
+		switch {
+		case len(args) == 0 && name == "+":
+			return NewIntFromInt(0), nil
+		case len(args) == 0 && name == "*":
+			return NewIntFromInt(1), nil
+		}
+		if err := checkMinArgs(name, args, 1); err != nil {
+			return nil, err
+		}
+		r, err := toInt(args[0])
+		if err != nil {
+			return nil, err
+		}
+		if name == "-" && len(args) == 1 {
+			return NewIntFromInt(-r), nil
+		}
+		for _, v := range args[1:] {
+			i, err := toInt(v)
+			if err != nil {
+				return nil, err
+			}
+			r = op(r, i)
+		}
+		return NewIntFromInt(r), nil
+	}
+}
+
+func divide(args ...MalType) (MalType, error) {
+	if err := checkMinArgs("/", args, 2); err != nil {
+		return nil, err
+	}
+	r, err := toInt(args[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range args[1:] {
+		i, err := toInt(v)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			return nil, errors.New("divide by zero")
+		}
+		r /= i
+	}
+	return NewIntFromInt(r), nil
+}
+
+func timeMs(args ...MalType) (MalType, error) {
+	if err := checkArgs("time-ms", args, 0); err != nil {
+		return nil, err
+	}
+	return NewIntFromInt(int(time.Now().UnixMilli())), nil
+}
+
+func list(args ...MalType) (MalType, error) {
+	return NewList(args...), nil
+}
+
+func vector(args ...MalType) (MalType, error) {
+	return NewVector(args...), nil
+}
+
+func hashMap(args ...MalType) (MalType, error) {
+	if len(args)%2 != 0 {
+		return nil, errors.New("hash-map: uneven number of arguments")
+	}
+	return NewHashMap(args), nil
+}
+
+func hashSet(args ...MalType) (MalType, error) {
+	return NewSet(args...), nil
+}
+
+func set(args ...MalType) (MalType, error) {
+	if err := checkArgs("set", args, 1); err != nil {
+		return nil, err
+	}
+	if s, ok := args[0].(*Set); ok {
+		return s, nil
+	}
+	items, err := Sequence(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("set: %s", err)
+	}
+	return NewSet(items...), nil
+}
+
+func disj(args ...MalType) (MalType, error) {
+	if err := checkMinArgs("disj", args, 1); err != nil {
+		return nil, err
+	}
+	if _, ok := args[0].(*Nil); ok {
+		return args[0], nil
+	}
+	s, err := toSet(args[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range args[1:] {
+		s = s.Disj(v)
+	}
+	return s, nil
+}
+
+func assoc(args ...MalType) (MalType, error) {
+	if err := checkMinArgs("assoc", args, 1); err != nil {
+		return nil, err
+	}
+	if len(args)%2 != 1 {
+		return nil, errors.New("assoc: uneven number of key/value arguments")
+	}
+	if vec, ok := args[0].(*Vector); ok {
+		for i := 1; i < len(args); i += 2 {
+			idx, err := toInt(args[i])
+			if err != nil {
+				return nil, err
+			}
+			if vec, err = vec.Assoc(idx, args[i+1]); err != nil {
+				return nil, fmt.Errorf("assoc: %s", err)
+			}
+		}
+		return vec, nil
+	}
+	if rec, ok := args[0].(*Record); ok {
+		for i := 1; i < len(args); i += 2 {
+			rec = rec.Assoc(args[i], args[i+1])
+		}
+		return rec, nil
+	}
+	hm, err := toHashMap(args[0])
+	if err != nil {
+		return nil, err
+	}
+	for i := 1; i < len(args); i += 2 {
+		hm = hm.Assoc(args[i], args[i+1])
+	}
+	return hm, nil
+}
+
+func dissoc(args ...MalType) (MalType, error) {
+	if err := checkMinArgs("dissoc", args, 1); err != nil {
+		return nil, err
+	}
+	if rec, ok := args[0].(*Record); ok {
+		if len(args) == 1 {
+			return rec, nil
+		}
+		return dissoc(append([]MalType{rec.Dissoc(args[1])}, args[2:]...)...)
+	}
+	hm, err := toHashMap(args[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range args[1:] {
+		hm = hm.Dissoc(k)
+	}
+	return hm, nil
+}
+
+func get(args ...MalType) (MalType, error) {
+	if err := checkArgs("get", args, 2); err != nil {
+		return nil, err
+	}
+	var v MalType
+	var ok bool
+	switch coll := args[0].(type) {
+	case *Nil:
+		return coll, nil
+	case Associative:
+		v, ok = coll.Get(args[1])
+	default:
+		return nil, fmt.Errorf("argument is not a HashMap: %s", args[0].Print(true))
+	}
+	if !ok {
+		return NilValue, nil
+	}
+	return v, nil
+}
+
+func contains(args ...MalType) (MalType, error) {
+	if err := checkArgs("contains?", args, 2); err != nil {
+		return nil, err
+	}
+	if _, ok := args[0].(*Nil); ok {
+		return FalseValue, nil
+	}
+	coll, ok := args[0].(Associative)
+	if !ok {
+		return nil, fmt.Errorf("argument is not a HashMap: %s", args[0].Print(true))
+	}
+	_, ok = coll.Get(args[1])
+	return NewBoolean(ok), nil
+}
+
+func keys(args ...MalType) (MalType, error) {
+	if err := checkArgs("keys", args, 1); err != nil {
+		return nil, err
+	}
+	hm, err := toHashMap(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return NewList(hm.Keys()...), nil
+}
+
+func vals(args ...MalType) (MalType, error) {
+	if err := checkArgs("vals", args, 1); err != nil {
+		return nil, err
+	}
+	hm, err := toHashMap(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return NewList(hm.Vals()...), nil
+}
+
+func isSequential(args ...MalType) (MalType, error) {
+	if err := checkArgs("sequential?", args, 1); err != nil {
+		return nil, err
+	}
+	switch args[0].(type) {
+	case *List, *Vector, *Cons, *LazySeq:
+		return TrueValue, nil
+	}
+	return FalseValue, nil
+}
+
+func cons(args ...MalType) (MalType, error) {
+	if err := checkArgs("cons", args, 2); err != nil {
+		return nil, err
+	}
+	switch s := args[1].(type) {
+	case *Cons, *LazySeq:
+		return NewCons(args[0], s.(Seq)), nil
+	}
+	items, err := Sequence(args[1])
+	if err != nil {
+		return nil, err
+	}
+	r := make([]MalType, 0, len(items)+1)
+	r = append(r, args[0])
+	return NewList(append(r, items...)...), nil
+}
+
+func vec(args ...MalType) (MalType, error) {
+	if err := checkArgs("vec", args, 1); err != nil {
+		return nil, err
+	}
+	if v, ok := args[0].(*Vector); ok {
+		return v, nil
+	}
+	items, err := Sequence(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return NewVector(items...), nil
+}
+
+func nth(args ...MalType) (MalType, error) {
+	if err := checkArgs("nth", args, 2); err != nil {
+		return nil, err
+	}
+	i, err := toInt(args[1])
+	if err != nil {
+		return nil, err
+	}
+	if vec, ok := args[0].(*Vector); ok {
+		if i < 0 || i >= vec.Length() {
+			return nil, fmt.Errorf("nth: index %d out of range", i)
+		}
+		return vec.Nth(i), nil
+	}
+	s, err := ToSeq(args[0])
+	if err != nil {
+		return nil, err
+	}
+	for ; i >= 0; i-- {
+		empty, err := s.IsEmpty()
+		if err != nil {
+			return nil, err
+		}
+		if empty {
+			break
+		}
+		if i == 0 {
+			return s.First()
+		}
+		if s, err = s.Rest(); err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("nth: index %s out of range", args[1].Print(true))
+}
+
+func first(args ...MalType) (MalType, error) {
+	if err := checkArgs("first", args, 1); err != nil {
+		return nil, err
+	}
+	s, err := ToSeq(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return s.First()
+}
+
+func rest(args ...MalType) (MalType, error) {
+	if err := checkArgs("rest", args, 1); err != nil {
+		return nil, err
+	}
+	s, err := ToSeq(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return s.Rest()
+}
+
+func isEmpty(args ...MalType) (MalType, error) {
+	if err := checkArgs("empty?", args, 1); err != nil {
+		return nil, err
+	}
+	if s, ok := args[0].(*String); ok && s.IsKeyword() {
+		return nil, fmt.Errorf("empty?: argument is not a collection or string: %s", s.Print(true))
+	}
+	if s, ok := args[0].(Seq); ok {
+		empty, err := s.IsEmpty()
+		if err != nil {
+			return nil, err
+		}
+		return NewBoolean(empty), nil
+	}
+	n, err := count(args...)
+	if err != nil {
+		return nil, err
+	}
+	return NewBoolean(n.(*Int).AsInt() == 0), nil
+}
+
+func count(args ...MalType) (MalType, error) {
+	if err := checkArgs("count", args, 1); err != nil {
+		return nil, err
+	}
+	switch v := args[0].(type) {
+	case *HashMap:
+		return NewIntFromInt(v.Length()), nil
+	case *Vector:
+		return NewIntFromInt(v.Length()), nil
+	case *Set:
+		return NewIntFromInt(v.Length()), nil
+	case *Record:
+		return NewIntFromInt(v.Length()), nil
+	case *String:
+		if v.IsKeyword() {
+			return nil, fmt.Errorf("count: argument is not a collection or string: %s", v.Print(true))
+		}
+		return NewIntFromInt(len([]rune(v.Value()))), nil
+	}
+	items, err := Sequence(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return NewIntFromInt(len(items)), nil
+}
+
+func apply(b *Bindings, args ...MalType) (MalType, error) {
+	if err := checkMinArgs("apply", args, 2); err != nil {
+		return nil, err
+	}
+	last, err := Sequence(args[len(args)-1])
+	if err != nil {
+		return nil, err
+	}
+	fargs := make([]MalType, 0, len(args)-2+len(last))
+	fargs = append(fargs, args[1:len(args)-1]...)
+	fargs = append(fargs, last...)
+	return Apply(b, args[0], fargs...)
+}
+
+func conj(args ...MalType) (MalType, error) {
+	if err := checkMinArgs("conj", args, 1); err != nil {
+		return nil, err
+	}
+	switch coll := args[0].(type) {
+	case *List:
+		r := make([]MalType, 0, coll.Length()+len(args)-1)
+		for i := len(args) - 1; i > 0; i-- {
+			r = append(r, args[i])
+		}
+		return NewList(append(r, coll.Items()...)...), nil
+	case *Vector:
+		for _, v := range args[1:] {
+			coll = coll.Conj(v)
+		}
+		return coll, nil
+	case *HashMap:
+		for _, v := range args[1:] {
+			entry, ok := v.(*Vector)
+			if !ok || entry.Length() != 2 {
+				return nil, fmt.Errorf("conj: map entry is not a vector of two items: %s", v.Print(true))
+			}
+			coll = coll.Assoc(entry.Nth(0), entry.Nth(1))
+		}
+		return coll, nil
+	case *Record:
+		for _, v := range args[1:] {
+			entry, ok := v.(*Vector)
+			if !ok || entry.Length() != 2 {
+				return nil, fmt.Errorf("conj: map entry is not a vector of two items: %s", v.Print(true))
+			}
+			coll = coll.Assoc(entry.Nth(0), entry.Nth(1))
+		}
+		return coll, nil
+	case *Set:
+		for _, v := range args[1:] {
+			coll = coll.Conj(v)
+		}
+		return coll, nil
+	case *Cons, *LazySeq:
+		s := coll.(Seq)
+		for _, v := range args[1:] {
+			s = NewCons(v, s)
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("conj: argument is not a collection: %s", args[0].Print(true))
+}
+
+func peek(args ...MalType) (MalType, error) {
+	if err := checkArgs("peek", args, 1); err != nil {
+		return nil, err
+	}
+	switch coll := args[0].(type) {
+	case *List:
+		if coll.Length() == 0 {
+			return NilValue, nil
+		}
+		return coll.Items()[0], nil
+	case *Vector:
+		if coll.Length() == 0 {
+			return NilValue, nil
+		}
+		return coll.Nth(coll.Length() - 1), nil
+	case *Nil:
+		return coll, nil
+	}
+	return nil, fmt.Errorf("peek: argument is not a List or Vector: %s", args[0].Print(true))
+}
+
+func pop(args ...MalType) (MalType, error) {
+	if err := checkArgs("pop", args, 1); err != nil {
+		return nil, err
+	}
+	switch coll := args[0].(type) {
+	case *List:
+		if coll.Length() == 0 {
+			return nil, errors.New("pop: can't pop an empty list")
+		}
+		return NewList(coll.Items()[1:]...), nil
+	case *Vector:
+		r, err := coll.Pop()
+		if err != nil {
+			return nil, fmt.Errorf("pop: %s", err)
+		}
+		return r, nil
+	case *Nil:
+		return coll, nil
+	}
+	return nil, fmt.Errorf("pop: argument is not a List or Vector: %s", args[0].Print(true))
+}
+
+func seq(args ...MalType) (MalType, error) {
+	if err := checkArgs("seq", args, 1); err != nil {
+		return nil, err
+	}
+	switch v := args[0].(type) {
+	case *List:
+		if v.Length() == 0 {
+			return NilValue, nil
+		}
+		return v, nil
+	case *Vector:
+		if v.Length() == 0 {
+			return NilValue, nil
+		}
+		return NewList(v.Items()...), nil
+	case *Set:
+		if v.Length() == 0 {
+			return NilValue, nil
+		}
+		return NewList(v.Items()...), nil
+	case *String:
+		if v.IsKeyword() {
+			break
+		}
+		if v.Value() == "" {
+			return NilValue, nil
+		}
+		r := []MalType{}
+		for _, c := range v.Value() {
+			r = append(r, NewString(string(c)))
+		}
+		return NewList(r...), nil
+	case *HashMap, *Record:
+		items, err := Sequence(v)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) == 0 {
+			return NilValue, nil
+		}
+		return NewList(items...), nil
+	case *Cons, *LazySeq:
+		empty, err := v.(Seq).IsEmpty()
+		if err != nil {
+			return nil, err
+		}
+		if empty {
+			return NilValue, nil
+		}
+		return v, nil
+	case *Nil:
+		return v, nil
+	}
+	return nil, fmt.Errorf("seq: argument is not a sequence or string: %s", args[0].Print(true))
+}
+
+func withMeta(args ...MalType) (MalType, error) {
+	if err := checkArgs("with-meta", args, 2); err != nil {
+		return nil, err
+	}
+	v, ok := args[0].(Metadatable)
+	if !ok {
+		return nil, fmt.Errorf("with-meta: %s does not support metadata", args[0].TypeName())
+	}
+	return v.WithMeta(args[1]), nil
+}
+
+func meta(args ...MalType) (MalType, error) {
+	if err := checkArgs("meta", args, 1); err != nil {
+		return nil, err
+	}
+	v, ok := args[0].(Metadatable)
+	if !ok {
+		return NilValue, nil
+	}
+	return v.Meta(), nil
+}
+
+func varyMeta(b *Bindings, args ...MalType) (MalType, error) {
+	if err := checkMinArgs("vary-meta", args, 2); err != nil {
+		return nil, err
+	}
+	v, ok := args[0].(Metadatable)
+	if !ok {
+		return nil, fmt.Errorf("vary-meta: %s does not support metadata", args[0].TypeName())
+	}
+	fargs := append([]MalType{v.Meta()}, args[2:]...)
+	m, err := Apply(b, args[1], fargs...)
+	if err != nil {
+		return nil, err
+	}
+	return v.WithMeta(m), nil
+}
+
+func atom(args ...MalType) (MalType, error) {
+	if err := checkArgs("atom", args, 1); err != nil {
+		return nil, err
+	}
+	return NewAtom(args[0]), nil
+}
+
+func toAtom(v MalType) (*Atom, error) {
+	a, ok := v.(*Atom)
+	if !ok {
+		return nil, fmt.Errorf("argument is not an Atom: %s", v.Print(true))
+	}
+	return a, nil
+}
+
+func deref(args ...MalType) (MalType, error) {
+	if err := checkArgs("deref", args, 1); err != nil {
+		return nil, err
+	}
+	if r, ok := args[0].(*Reduced); ok {
+		return r.Deref(), nil
+	}
+	a, err := toAtom(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return a.Deref(), nil
+}
+
+func reset(args ...MalType) (MalType, error) {
+	if err := checkArgs("reset!", args, 2); err != nil {
+		return nil, err
+	}
+	a, err := toAtom(args[0])
+	if err != nil {
+		return nil, err
+	}
+	a.Reset(args[1])
+	return args[1], nil
+}
+
+func swap(b *Bindings, args ...MalType) (MalType, error) {
+	if err := checkMinArgs("swap!", args, 2); err != nil {
+		return nil, err
+	}
+	a, err := toAtom(args[0])
+	if err != nil {
+		return nil, err
+	}
+	fargs := append([]MalType{a.Deref()}, args[2:]...)
+	v, err := Apply(b, args[1], fargs...)
+	if err != nil {
+		return nil, err
+	}
+	a.Reset(v)
+	return v, nil
+}
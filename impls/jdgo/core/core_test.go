@@ -0,0 +1,96 @@
+package core
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	. "github.com/jdugan1024/jdgo/types"
+)
+
+var sink MalType
+
+// BenchmarkPredicates calls builtins that return nil or a boolean.
+func BenchmarkPredicates(b *testing.B) {
+	one := NewIntFromInt(1)
+	fns := []func(...MalType) (MalType, error){NS["nil?"], NS["="], NS["<"], NS["empty?"], NS["get"]}
+	args := [][]MalType{{one}, {one, one}, {one, one}, {NewList()}, {NewHashMap(nil), one}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		j := i % len(fns)
+		v, err := fns[j](args[j]...)
+		if err != nil {
+			b.Fatal(err)
+		}
+		sink = v
+	}
+}
+
+// captureStdout returns what f prints to os.Stdout.
+func captureStdout(t *testing.T, f func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	printed := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		printed <- string(b)
+	}()
+	f()
+	os.Stdout = stdout
+	w.Close()
+	return <-printed
+}
+
+// TestPrintFunctions checks that pr-str and prn print strings readably,
+// quoted and escaped, and that str and println print them as they are.
+func TestPrintFunctions(t *testing.T) {
+	args := []MalType{NewString("a \"b\"\n"), NewKeyword("k"), NewVector(NewString("c"))}
+	for _, c := range []struct {
+		name string
+		args []MalType
+		want string
+	}{
+		{"pr-str", args, `"a \"b\"\n" :k ["c"]`},
+		{"str", args, "a \"b\"\n:k[c]"},
+		{"pr-str", nil, ""},
+		{"str", []MalType{NilValue, NewIntFromInt(1)}, "nil1"},
+	} {
+		v, err := NS[c.name](c.args...)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if got := v.(*String).Value(); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+	for _, c := range []struct{ name, want string }{
+		{"prn", `"a \"b\"\n" :k ["c"]` + "\n"},
+		{"println", "a \"b\"\n :k [c]\n"},
+	} {
+		got := captureStdout(t, func() {
+			if _, err := NS[c.name](args...); err != nil {
+				t.Error(err)
+			}
+		})
+		if got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+// TestKeywordIsNotACollection checks that the functions taking a collection
+// or string refuse a keyword, though it is a String.
+func TestKeywordIsNotACollection(t *testing.T) {
+	for _, name := range []string{"count", "empty?", "seq"} {
+		if _, err := NS[name](NewKeyword("a")); err == nil {
+			t.Errorf("(%s :a) did not fail", name)
+		}
+	}
+	if v, err := NS["count"](NewString("ab")); err != nil || !v.Equal(NewIntFromInt(2)) {
+		t.Errorf(`(count "ab"): got %v, %v, want 2`, v, err)
+	}
+}
@@ -0,0 +1,378 @@
+package core
+
+import (
+	. "github.com/jdugan1024/jdgo/types"
+)
+
+// LazyNS holds the functions that build lazy sequences.  None of them walk
+// more of their arguments than is needed for the items asked for, so they
+// work on infinite sequences.  Called without a collection, map, take,
+// filter, remove and partition-all return a transducer instead; see
+// ReduceNS.
+var LazyNS = map[string]func(...MalType) (MalType, error){
+	"concat":        concat,
+	"range":         rangeFn,
+	"repeat":        repeat,
+	"take":          take,
+	"drop":          drop,
+	"partition-all": partitionAll,
+}
+
+// LazyDynamicNS holds the functions that build lazy sequences by calling
+// the functions they are given.  Each is also given the dynamic var
+// bindings of its caller, which the sequence it returns calls them with
+// as its items are needed.
+var LazyDynamicNS = map[string]func(*Bindings, ...MalType) (MalType, error){
+	"lazy-seq*":  lazySeq,
+	"map":        mapFn,
+	"iterate":    iterate,
+	"filter":     filter,
+	"remove":     remove,
+	"take-while": takeWhile,
+}
+
+// lazySeq returns a LazySeq produced by calling the function of no arguments
+// it is given.  The lazy-seq macro wraps its body in such a function.
+func lazySeq(b *Bindings, args ...MalType) (MalType, error) {
+	if err := checkArgs("lazy-seq*", args, 1); err != nil {
+		return nil, err
+	}
+	f := args[0]
+	return NewLazySeq(func() (MalType, error) { return Apply(b, f) }), nil
+}
+
+func iterate(b *Bindings, args ...MalType) (MalType, error) {
+	if err := checkArgs("iterate", args, 2); err != nil {
+		return nil, err
+	}
+	return iterateSeq(b, args[0], args[1]), nil
+}
+
+func iterateSeq(b *Bindings, f, x MalType) Seq {
+	return NewCons(x, NewLazySeq(func() (MalType, error) {
+		y, err := Apply(b, f, x)
+		if err != nil {
+			return nil, err
+		}
+		return iterateSeq(b, f, y), nil
+	}))
+}
+
+// rangeFn implements (range), (range end), (range start end) and
+// (range start end step).
+func rangeFn(args ...MalType) (MalType, error) {
+	if len(args) > 3 {
+		return nil, checkArgs("range", args, 3)
+	}
+	bounds := []int{0, 0, 1}
+	for i, v := range args {
+		n, err := toInt(v)
+		if err != nil {
+			return nil, err
+		}
+		bounds[i] = n
+	}
+	switch len(args) {
+	case 0:
+		return rangeSeq(0, 0, 1, false), nil
+	case 1:
+		return rangeSeq(0, bounds[0], 1, true), nil
+	}
+	return rangeSeq(bounds[0], bounds[1], bounds[2], true), nil
+}
+
+func rangeSeq(start, end, step int, bounded bool) Seq {
+	return NewLazySeq(func() (MalType, error) {
+		if bounded && ((step >= 0 && start >= end) || (step < 0 && start <= end)) {
+			return NilValue, nil
+		}
+		return NewCons(NewIntFromInt(start), rangeSeq(start+step, end, step, bounded)), nil
+	})
+}
+
+// repeat implements (repeat x) and (repeat n x).
+func repeat(args ...MalType) (MalType, error) {
+	switch len(args) {
+	case 1:
+		return repeatSeq(0, args[0], false), nil
+	case 2:
+		n, err := toInt(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return repeatSeq(n, args[1], true), nil
+	}
+	return nil, checkArgs("repeat", args, 2)
+}
+
+func repeatSeq(n int, x MalType, bounded bool) Seq {
+	return NewLazySeq(func() (MalType, error) {
+		if bounded && n <= 0 {
+			return NilValue, nil
+		}
+		return NewCons(x, repeatSeq(n-1, x, bounded)), nil
+	})
+}
+
+// mapFn implements (map f coll ...), which applies f to the first items of
+// the collections, then to the second items, and so on until one of them
+// runs out.  f is applied to the items only as they are needed.
+func mapFn(b *Bindings, args ...MalType) (MalType, error) {
+	if len(args) == 1 {
+		return mapXf(args[0]), nil
+	}
+	if err := checkMinArgs("map", args, 2); err != nil {
+		return nil, err
+	}
+	seqs := make([]Seq, len(args)-1)
+	for i, v := range args[1:] {
+		s, err := ToSeq(v)
+		if err != nil {
+			return nil, err
+		}
+		seqs[i] = s
+	}
+	return mapSeq(b, args[0], seqs), nil
+}
+
+func mapSeq(b *Bindings, f MalType, seqs []Seq) Seq {
+	return NewLazySeq(func() (MalType, error) {
+		xs := make([]MalType, len(seqs))
+		rests := make([]Seq, len(seqs))
+		for i, s := range seqs {
+			x, rest, ok, err := uncons(s)
+			if !ok || err != nil {
+				return NilValue, err
+			}
+			xs[i], rests[i] = x, rest
+		}
+		y, err := Apply(b, f, xs...)
+		if err != nil {
+			return nil, err
+		}
+		return NewCons(y, mapSeq(b, f, rests)), nil
+	})
+}
+
+func concat(args ...MalType) (MalType, error) {
+	seqs := make([]Seq, len(args))
+	for i, v := range args {
+		s, err := ToSeq(v)
+		if err != nil {
+			return nil, err
+		}
+		seqs[i] = s
+	}
+	return concatSeq(seqs), nil
+}
+
+// concatSeq returns the items of each of seqs in turn.
+func concatSeq(seqs []Seq) Seq {
+	return NewLazySeq(func() (MalType, error) {
+		for len(seqs) > 0 {
+			x, rest, ok, err := uncons(seqs[0])
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				return NewCons(x, concatSeq(append([]Seq{rest}, seqs[1:]...))), nil
+			}
+			seqs = seqs[1:]
+		}
+		return NilValue, nil
+	})
+}
+
+func take(args ...MalType) (MalType, error) {
+	if len(args) != 1 {
+		if err := checkArgs("take", args, 2); err != nil {
+			return nil, err
+		}
+	}
+	n, err := toInt(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 1 {
+		return takeXf(n), nil
+	}
+	s, err := ToSeq(args[1])
+	if err != nil {
+		return nil, err
+	}
+	return takeSeq(n, s), nil
+}
+
+func takeSeq(n int, s Seq) Seq {
+	return NewLazySeq(func() (MalType, error) {
+		if n <= 0 {
+			return NilValue, nil
+		}
+		x, rest, ok, err := uncons(s)
+		if !ok || err != nil {
+			return NilValue, err
+		}
+		return NewCons(x, takeSeq(n-1, rest)), nil
+	})
+}
+
+func drop(args ...MalType) (MalType, error) {
+	if err := checkArgs("drop", args, 2); err != nil {
+		return nil, err
+	}
+	n, err := toInt(args[0])
+	if err != nil {
+		return nil, err
+	}
+	s, err := ToSeq(args[1])
+	if err != nil {
+		return nil, err
+	}
+	return NewLazySeq(func() (MalType, error) {
+		for i := 0; i < n; i++ {
+			_, rest, ok, err := uncons(s)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				break
+			}
+			s = rest
+		}
+		return s, nil
+	}), nil
+}
+
+func filter(b *Bindings, args ...MalType) (MalType, error) {
+	if len(args) == 1 {
+		return filterXf("filter", args[0], false), nil
+	}
+	if err := checkArgs("filter", args, 2); err != nil {
+		return nil, err
+	}
+	s, err := ToSeq(args[1])
+	if err != nil {
+		return nil, err
+	}
+	return filterSeq(b, args[0], s, false), nil
+}
+
+func remove(b *Bindings, args ...MalType) (MalType, error) {
+	if len(args) == 1 {
+		return filterXf("remove", args[0], true), nil
+	}
+	if err := checkArgs("remove", args, 2); err != nil {
+		return nil, err
+	}
+	s, err := ToSeq(args[1])
+	if err != nil {
+		return nil, err
+	}
+	return filterSeq(b, args[0], s, true), nil
+}
+
+// filterSeq keeps the items of s for which pred is truthy, or with remove
+// set the items for which it is not.
+func filterSeq(b *Bindings, pred MalType, s Seq, remove bool) Seq {
+	return NewLazySeq(func() (MalType, error) {
+		for {
+			x, rest, ok, err := uncons(s)
+			if !ok || err != nil {
+				return NilValue, err
+			}
+			keep, err := Apply(b, pred, x)
+			if err != nil {
+				return nil, err
+			}
+			if Truthy(keep) != remove {
+				return NewCons(x, filterSeq(b, pred, rest, remove)), nil
+			}
+			s = rest
+		}
+	})
+}
+
+func takeWhile(b *Bindings, args ...MalType) (MalType, error) {
+	if err := checkArgs("take-while", args, 2); err != nil {
+		return nil, err
+	}
+	s, err := ToSeq(args[1])
+	if err != nil {
+		return nil, err
+	}
+	return takeWhileSeq(b, args[0], s), nil
+}
+
+func takeWhileSeq(b *Bindings, pred MalType, s Seq) Seq {
+	return NewLazySeq(func() (MalType, error) {
+		x, rest, ok, err := uncons(s)
+		if !ok || err != nil {
+			return NilValue, err
+		}
+		keep, err := Apply(b, pred, x)
+		if err != nil {
+			return nil, err
+		}
+		if !Truthy(keep) {
+			return NilValue, nil
+		}
+		return NewCons(x, takeWhileSeq(b, pred, rest)), nil
+	})
+}
+
+func partitionAll(args ...MalType) (MalType, error) {
+	if len(args) != 1 {
+		if err := checkArgs("partition-all", args, 2); err != nil {
+			return nil, err
+		}
+	}
+	n, err := partitionSize(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 1 {
+		return partitionAllXf(n), nil
+	}
+	s, err := ToSeq(args[1])
+	if err != nil {
+		return nil, err
+	}
+	return partitionSeq(n, s), nil
+}
+
+func partitionSeq(n int, s Seq) Seq {
+	return NewLazySeq(func() (MalType, error) {
+		part := []MalType{}
+		for len(part) < n {
+			x, rest, ok, err := uncons(s)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				break
+			}
+			part = append(part, x)
+			s = rest
+		}
+		if len(part) == 0 {
+			return NilValue, nil
+		}
+		return NewCons(NewList(part...), partitionSeq(n, s)), nil
+	})
+}
+
+// uncons splits s into its first item and the rest; ok is false if s is
+// empty.
+func uncons(s Seq) (first MalType, rest Seq, ok bool, err error) {
+	empty, err := s.IsEmpty()
+	if err != nil || empty {
+		return nil, nil, false, err
+	}
+	if first, err = s.First(); err != nil {
+		return nil, nil, false, err
+	}
+	if rest, err = s.Rest(); err != nil {
+		return nil, nil, false, err
+	}
+	return first, rest, true, nil
+}
@@ -0,0 +1,140 @@
+package core
+
+import (
+	"testing"
+
+	. "github.com/jdugan1024/jdgo/types"
+)
+
+// call calls the builtin name, which may be in NS, LazyNS or
+// LazyDynamicNS, failing t if it returns an error.
+func call(t *testing.T, name string, args ...MalType) MalType {
+	t.Helper()
+	f, ok := LazyDynamicNS[name]
+	if !ok {
+		g, ok := LazyNS[name]
+		if !ok {
+			g = NS[name]
+		}
+		f = func(_ *Bindings, args ...MalType) (MalType, error) { return g(args...) }
+	}
+	v, err := f(nil, args...)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return v
+}
+
+// TestLazyOnInfiniteSequences checks that map, concat and the functions
+// built on them take no more of an infinite sequence than they are asked
+// for.
+func TestLazyOnInfiniteSequences(t *testing.T) {
+	n := NewIntFromInt
+	inc := NewFunction("inc", func(args ...MalType) (MalType, error) { return NS["+"](args[0], n(1)) })
+	for _, c := range []struct {
+		name string
+		v    func() MalType
+		want MalType
+	}{
+		{"(take 2 (map inc (range)))", func() MalType {
+			return call(t, "take", n(2), call(t, "map", inc, inf()))
+		}, NewList(n(1), n(2))},
+		{"(first (concat (range) [1]))", func() MalType {
+			return call(t, "first", call(t, "concat", inf(), NewVector(n(1))))
+		}, n(0)},
+		{"(take 3 (concat [:a] nil () (range)))", func() MalType {
+			return call(t, "take", n(3), call(t, "concat", NewVector(NewKeyword("a")), NilValue, NewList(), inf()))
+		}, NewList(NewKeyword("a"), n(0), n(1))},
+		{"(nth (map inc (concat [10] (range))) 3)", func() MalType {
+			return call(t, "nth", call(t, "map", inc, call(t, "concat", NewVector(n(10)), inf())), n(3))
+		}, n(3)},
+		{"(take 2 (map inc (filter even? (range))))", func() MalType {
+			even := NewFunction("even?", func(args ...MalType) (MalType, error) {
+				return NewBoolean(args[0].(*Int).AsInt()%2 == 0), nil
+			})
+			return call(t, "take", n(2), call(t, "map", inc, call(t, "filter", even, inf())))
+		}, NewList(n(1), n(3))},
+	} {
+		if got := c.v(); !got.Equal(c.want) {
+			t.Errorf("%s: got %s, want %s", c.name, got.Print(true), c.want.Print(true))
+		}
+	}
+	if _, err := concat(inf(), NewKeyword("a")); err == nil {
+		t.Error("concat of a keyword did not fail")
+	}
+}
+
+// TestMapRealizesOnDemand checks that map calls its function on the items
+// only when they are asked for, once each, and that with several
+// collections it stops at the end of the shortest.
+func TestMapRealizesOnDemand(t *testing.T) {
+	n := NewIntFromInt
+	calls := 0
+	count := NewFunction("count-calls", func(args ...MalType) (MalType, error) {
+		calls++
+		return args[0], nil
+	})
+	s := call(t, "map", count, inf())
+	if calls != 0 {
+		t.Fatalf("map called its function %d times, want 0", calls)
+	}
+	call(t, "nth", s, n(4))
+	if calls != 5 {
+		t.Errorf("(nth s 4) called the function %d times in all, want 5", calls)
+	}
+	call(t, "nth", s, n(4))
+	call(t, "first", s)
+	if calls != 5 {
+		t.Errorf("walking s again called the function %d times in all, want 5", calls)
+	}
+
+	// An error is raised when the item is asked for.
+	fail := NewFunction("throw", NS["throw"])
+	s = call(t, "map", fail, NewList(NewString("my err")))
+	if _, err := NS["first"](s); err == nil {
+		t.Error("map of throw did not fail")
+	}
+	if v := call(t, "map", fail, NewList()); !v.Equal(NewList()) {
+		t.Errorf("map over () gave %s", v.Print(true))
+	}
+
+	plus := NewFunction("+", NS["+"])
+	if v := call(t, "map", plus, inf(), NewVector(n(10), n(20)), inf()); !v.Equal(NewList(n(10), n(22))) {
+		t.Errorf("map over (range), [10 20] and (range) gave %s", v.Print(true))
+	}
+	if _, err := mapFn(nil, plus, inf(), NewKeyword("a")); err == nil {
+		t.Error("map over a keyword did not fail")
+	}
+}
+
+// TestSeq checks what seq makes of each kind of collection.
+func TestSeq(t *testing.T) {
+	n, kw := NewIntFromInt, NewKeyword
+	point := NewRecordType("user", "Point", []*String{kw("x"), kw("y")})
+	p, err := NewRecord(point, n(1), n(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		in   MalType
+		want string
+	}{
+		{NewHashMap([]MalType{kw("a"), n(1), kw("b"), n(2)}), "([:a 1] [:b 2])"},
+		{NewHashMap(nil), "nil"},
+		{p, "([:x 1] [:y 2])"},
+		{p.Assoc(kw("z"), n(3)), "([:x 1] [:y 2] [:z 3])"},
+		{NewVector(n(1)), "(1)"},
+		{NewVector(), "nil"},
+		{NewSet(n(1)), "(1)"},
+		{NewString("ab"), `("a" "b")`},
+		{NewString(""), "nil"},
+		{call(t, "concat"), "nil"},
+		{call(t, "concat", NewVector(), NilValue), "nil"},
+		{NilValue, "nil"},
+	} {
+		v := call(t, "seq", c.in)
+		if got := v.Print(true); got != c.want {
+			t.Errorf("(seq %s): got %s, want %s", c.in.Print(true), got, c.want)
+		}
+	}
+}
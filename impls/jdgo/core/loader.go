@@ -0,0 +1,115 @@
+package core
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	. "github.com/jdugan1024/jdgo/types"
+)
+
+// Loader finds the files defining namespaces along a search path and loads
+// each of them once.  The search path is taken from the JDGO_PATH
+// environment variable, a list of directories separated like PATH, and
+// defaults to the current directory.  Files not found on the search path
+// are looked for in a built in file system last, so that a library built
+// into the binary can be overridden by putting a copy on the search path.
+type Loader struct {
+	path    []string
+	builtin fs.FS
+	// load evaluates src, the contents of the file at filename.
+	load   func(filename string, src []byte) error
+	loaded map[string]bool
+	// loading holds the namespaces being loaded, outermost first, to
+	// detect circular requires.
+	loading []string
+}
+
+// ModuleLoader loads the namespaces required with require.  It is nil until
+// the interpreter, which is the one that can evaluate code, sets it.
+var ModuleLoader *Loader
+
+// NewLoader returns a Loader that evaluates files with load and falls back
+// on builtin, which may be nil.
+func NewLoader(load func(filename string, src []byte) error, builtin fs.FS) *Loader {
+	path := filepath.SplitList(os.Getenv("JDGO_PATH"))
+	if len(path) == 0 {
+		path = []string{"."}
+	}
+	return &Loader{path: path, builtin: builtin, load: load, loaded: map[string]bool{}}
+}
+
+// Path returns the directories searched, in order.
+func (l *Loader) Path() []string { return l.path }
+
+// SetPath replaces the directories searched.
+func (l *Loader) SetPath(dirs ...string) { l.path = dirs }
+
+// AddPath adds dir to the end of the directories searched.
+func (l *Loader) AddPath(dir string) { l.path = append(l.path, dir) }
+
+// Loaded returns the names of the namespaces loaded so far, sorted.
+func (l *Loader) Loaded() []string {
+	r := make([]string, 0, len(l.loaded))
+	for name := range l.loaded {
+		r = append(r, name)
+	}
+	sort.Strings(r)
+	return r
+}
+
+// MarkLoaded records that the namespace called name is loaded, as when it
+// is restored from an image, so that require does not load it again.
+func (l *Loader) MarkLoaded(name string) { l.loaded[name] = true }
+
+// read returns the name and contents of the file defining the namespace
+// called name: the first one found on the search path, or else in the built
+// in file system, as described by NamespaceFile.
+func (l *Loader) read(name string) (string, []byte, error) {
+	rel := NamespaceFile(name)
+	for _, dir := range l.path {
+		filename := filepath.Join(dir, rel)
+		if src, err := os.ReadFile(filename); err == nil {
+			return filename, src, nil
+		}
+	}
+	if l.builtin != nil {
+		if src, err := fs.ReadFile(l.builtin, rel); err == nil {
+			return "builtin:" + rel, src, nil
+		}
+	}
+	return "", nil, fmt.Errorf("could not find %s on the load path %s", rel, strings.Join(l.path, string(filepath.ListSeparator)))
+}
+
+// Require loads the namespace called name unless it has been loaded
+// already, or, with reload set, loads it again.  A namespace that has no
+// file but exists, because it was created at the REPL, needs no loading.
+func (l *Loader) Require(name string, reload bool) error {
+	if l.loaded[name] && !reload {
+		return nil
+	}
+	for i, n := range l.loading {
+		if n == name {
+			chain := append(append([]string{}, l.loading[i:]...), name)
+			return fmt.Errorf("circular require: %s", strings.Join(chain, " -> "))
+		}
+	}
+	filename, src, err := l.read(name)
+	if err != nil {
+		if FindNamespace(name) != nil {
+			return nil
+		}
+		return err
+	}
+
+	l.loading = append(l.loading, name)
+	defer func() { l.loading = l.loading[:len(l.loading)-1] }()
+	if err := l.load(filename, src); err != nil {
+		return err
+	}
+	l.loaded[name] = true
+	return nil
+}
@@ -0,0 +1,101 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// testLoader returns a Loader over the files given as a map from paths
+// relative to a temporary directory to their contents.  Loading a file
+// requires the namespaces it lists, one per line, and counts the load.
+func testLoader(t *testing.T, files map[string]string) (*Loader, map[string]int) {
+	dir := t.TempDir()
+	for name, content := range files {
+		filename := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	loads := map[string]int{}
+	builtin := fstest.MapFS{
+		"lib.mal":     {Data: []byte("")},
+		"builtin.mal": {Data: []byte("lib")},
+	}
+	var l *Loader
+	l = NewLoader(func(filename string, src []byte) error {
+		if rel, err := filepath.Rel(dir, filename); err == nil {
+			filename = filepath.ToSlash(rel)
+		}
+		loads[filename]++
+		for _, name := range strings.Fields(string(src)) {
+			if err := l.Require(name, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, builtin)
+	l.SetPath(filepath.Join(dir, "override"), dir)
+	return l, loads
+}
+
+func TestLoaderLoadsOnce(t *testing.T) {
+	l, loads := testLoader(t, map[string]string{
+		"app/main.mal":      "app.util-fns lib",
+		"app/util_fns.mal":  "lib",
+		"lib.mal":           "",
+		"override/lib.mal":  "",
+		"unused/module.mal": "",
+	})
+	if err := l.Require("app.main", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Require("app.main", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Require("builtin", false); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{"app/main.mal": 1, "app/util_fns.mal": 1, "override/lib.mal": 1, "builtin:builtin.mal": 1}
+	for name, n := range want {
+		if loads[name] != n {
+			t.Errorf("%s loaded %d times, want %d", name, loads[name], n)
+		}
+	}
+	if len(loads) != len(want) {
+		t.Errorf("loaded %v, want %v", loads, want)
+	}
+	if got := strings.Join(l.Loaded(), " "); got != "app.main app.util-fns builtin lib" {
+		t.Errorf("loaded namespaces: got %s", got)
+	}
+
+	if err := l.Require("app.main", true); err != nil {
+		t.Fatal(err)
+	}
+	if loads["app/main.mal"] != 2 || loads["app/util_fns.mal"] != 1 {
+		t.Errorf("reloading app.main: got loads %v", loads)
+	}
+}
+
+func TestLoaderErrors(t *testing.T) {
+	l, _ := testLoader(t, map[string]string{
+		"a.mal": "b",
+		"b.mal": "c",
+		"c.mal": "a",
+	})
+	err := l.Require("a", false)
+	if err == nil || !strings.Contains(err.Error(), "circular require: a -> b -> c -> a") {
+		t.Errorf("circular require: got %v", err)
+	}
+	if len(l.Loaded()) != 0 {
+		t.Errorf("a failed load left %v loaded", l.Loaded())
+	}
+	if err := l.Require("missing.module", false); err == nil || !strings.Contains(err.Error(), "missing/module.mal") {
+		t.Errorf("missing module: got %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+package core
+
+import (
+	"fmt"
+
+	. "github.com/jdugan1024/jdgo/types"
+)
+
+// MultiNS holds the functions behind multimethods and the functions that
+// build the hierarchy they dispatch through.  The defmulti and defmethod
+// macros are defined in mal on top of multi-fn and add-method.
+var MultiNS = map[string]func(...MalType) (MalType, error){
+	"multi-fn":           multiFn,
+	"add-method":         addMethod,
+	"remove-method":      removeMethod,
+	"remove-all-methods": removeAllMethods,
+	"prefer-method":      preferMethod,
+	"methods":            methods,
+	"prefers":            prefers,
+	"get-method":         getMethod,
+	"derive":             derive,
+	"underive":           underive,
+	"isa?":               isa,
+	"parents":            parents,
+	"ancestors":          ancestors,
+	"descendants":        descendants,
+}
+
+var kwDefault = NewKeyword("default")
+
+func toMultiFn(v MalType) (*MultiFn, error) {
+	mf, ok := v.(*MultiFn)
+	if !ok {
+		return nil, fmt.Errorf("argument is not a multimethod: %s", v.Print(true))
+	}
+	return mf, nil
+}
+
+// multiFn implements (multi-fn name dispatch-fn :default value), where the
+// :default option names the dispatch value of the default method and is
+// :default if left out.
+func multiFn(args ...MalType) (MalType, error) {
+	if len(args) != 4 {
+		if err := checkArgs("multi-fn", args, 2); err != nil {
+			return nil, err
+		}
+	}
+	name, ok := args[0].(*Symbol)
+	if !ok {
+		return nil, fmt.Errorf("multi-fn: name is not a symbol: %s", args[0].Print(true))
+	}
+	defaultVal := MalType(kwDefault)
+	if len(args) == 4 {
+		if args[2] != kwDefault {
+			return nil, fmt.Errorf("multi-fn: unknown option %s", args[2].Print(true))
+		}
+		defaultVal = args[3]
+	}
+	return NewMultiFn(name.Print(true), args[1], defaultVal, GlobalHierarchy), nil
+}
+
+func addMethod(args ...MalType) (MalType, error) {
+	if err := checkArgs("add-method", args, 3); err != nil {
+		return nil, err
+	}
+	mf, err := toMultiFn(args[0])
+	if err != nil {
+		return nil, err
+	}
+	mf.AddMethod(args[1], args[2])
+	return mf, nil
+}
+
+func removeMethod(args ...MalType) (MalType, error) {
+	if err := checkArgs("remove-method", args, 2); err != nil {
+		return nil, err
+	}
+	mf, err := toMultiFn(args[0])
+	if err != nil {
+		return nil, err
+	}
+	mf.RemoveMethod(args[1])
+	return mf, nil
+}
+
+func removeAllMethods(args ...MalType) (MalType, error) {
+	if err := checkArgs("remove-all-methods", args, 1); err != nil {
+		return nil, err
+	}
+	mf, err := toMultiFn(args[0])
+	if err != nil {
+		return nil, err
+	}
+	mf.RemoveAllMethods()
+	return mf, nil
+}
+
+func preferMethod(args ...MalType) (MalType, error) {
+	if err := checkArgs("prefer-method", args, 3); err != nil {
+		return nil, err
+	}
+	mf, err := toMultiFn(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := mf.PreferMethod(args[1], args[2]); err != nil {
+		return nil, err
+	}
+	return mf, nil
+}
+
+func methods(args ...MalType) (MalType, error) {
+	if err := checkArgs("methods", args, 1); err != nil {
+		return nil, err
+	}
+	mf, err := toMultiFn(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return mf.Methods(), nil
+}
+
+func prefers(args ...MalType) (MalType, error) {
+	if err := checkArgs("prefers", args, 1); err != nil {
+		return nil, err
+	}
+	mf, err := toMultiFn(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return mf.Prefers(), nil
+}
+
+// getMethod returns the method that would be called for a dispatch value,
+// or nil if there is none.
+func getMethod(args ...MalType) (MalType, error) {
+	if err := checkArgs("get-method", args, 2); err != nil {
+		return nil, err
+	}
+	mf, err := toMultiFn(args[0])
+	if err != nil {
+		return nil, err
+	}
+	f, err := mf.Method(args[1])
+	if err != nil {
+		return nil, err
+	}
+	if f == nil {
+		return NilValue, nil
+	}
+	return f, nil
+}
+
+func derive(args ...MalType) (MalType, error) {
+	if err := checkArgs("derive", args, 2); err != nil {
+		return nil, err
+	}
+	if err := GlobalHierarchy.Derive(args[0], args[1]); err != nil {
+		return nil, fmt.Errorf("derive: %s", err)
+	}
+	return NilValue, nil
+}
+
+func underive(args ...MalType) (MalType, error) {
+	if err := checkArgs("underive", args, 2); err != nil {
+		return nil, err
+	}
+	GlobalHierarchy.Underive(args[0], args[1])
+	return NilValue, nil
+}
+
+func isa(args ...MalType) (MalType, error) {
+	if err := checkArgs("isa?", args, 2); err != nil {
+		return nil, err
+	}
+	return NewBoolean(GlobalHierarchy.Isa(args[0], args[1])), nil
+}
+
+// setOrNil returns s, or nil if it is empty, as parents, ancestors and
+// descendants do.
+func setOrNil(s *Set) MalType {
+	if s.Length() == 0 {
+		return NilValue
+	}
+	return s
+}
+
+func parents(args ...MalType) (MalType, error) {
+	if err := checkArgs("parents", args, 1); err != nil {
+		return nil, err
+	}
+	return setOrNil(GlobalHierarchy.Parents(args[0])), nil
+}
+
+func ancestors(args ...MalType) (MalType, error) {
+	if err := checkArgs("ancestors", args, 1); err != nil {
+		return nil, err
+	}
+	return setOrNil(GlobalHierarchy.Ancestors(args[0])), nil
+}
+
+func descendants(args ...MalType) (MalType, error) {
+	if err := checkArgs("descendants", args, 1); err != nil {
+		return nil, err
+	}
+	return setOrNil(GlobalHierarchy.Descendants(args[0])), nil
+}
@@ -0,0 +1,348 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	. "github.com/jdugan1024/jdgo/types"
+)
+
+// NamespaceNS holds the functions that create, switch and connect
+// namespaces.  The ns macro is defined in mal and calls ns-form to build
+// its expansion.
+var NamespaceNS = map[string]func(...MalType) (MalType, error){
+	"in-ns":       inNs,
+	"create-ns":   createNs,
+	"find-ns":     findNs,
+	"remove-ns":   removeNs,
+	"all-ns":      allNs,
+	"ns-name":     nsName,
+	"ns-publics":  nsPublics,
+	"alias":       alias,
+	"refer":       refer,
+	"require":     require,
+	"loaded-libs": loadedLibs,
+	"ns-form":     nsForm,
+}
+
+var (
+	kwAs       = NewKeyword("as")
+	kwRefer    = NewKeyword("refer")
+	kwAll      = NewKeyword("all")
+	kwOnly     = NewKeyword("only")
+	kwReload   = NewKeyword("reload")
+	symInNs    = NewSymbol("in-ns")
+	symRequire = NewSymbol("require")
+)
+
+func toSymbol(v MalType) (*Symbol, error) {
+	s, ok := v.(*Symbol)
+	if !ok {
+		return nil, fmt.Errorf("argument is not a Symbol: %s", v.Print(true))
+	}
+	return s, nil
+}
+
+// toNamespace returns the namespace v is or names.
+func toNamespace(v MalType) (*Namespace, error) {
+	switch t := v.(type) {
+	case *Namespace:
+		return t, nil
+	case *Symbol:
+		if ns := FindNamespace(t.Print(true)); ns != nil {
+			return ns, nil
+		}
+		return nil, fmt.Errorf("no namespace: %s", t.Print(true))
+	}
+	return nil, fmt.Errorf("argument is not a namespace: %s", v.Print(true))
+}
+
+// inNs implements (in-ns name), which makes the namespace called name the
+// current one, creating it if needed.
+func inNs(args ...MalType) (MalType, error) {
+	if err := checkArgs("in-ns", args, 1); err != nil {
+		return nil, err
+	}
+	name, err := toSymbol(args[0])
+	if err != nil {
+		return nil, err
+	}
+	ns := CreateNamespace(name.Print(true))
+	SetCurrentNamespace(ns)
+	return ns, nil
+}
+
+func createNs(args ...MalType) (MalType, error) {
+	if err := checkArgs("create-ns", args, 1); err != nil {
+		return nil, err
+	}
+	name, err := toSymbol(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return CreateNamespace(name.Print(true)), nil
+}
+
+func findNs(args ...MalType) (MalType, error) {
+	if err := checkArgs("find-ns", args, 1); err != nil {
+		return nil, err
+	}
+	name, err := toSymbol(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if ns := FindNamespace(name.Print(true)); ns != nil {
+		return ns, nil
+	}
+	return NilValue, nil
+}
+
+func removeNs(args ...MalType) (MalType, error) {
+	if err := checkArgs("remove-ns", args, 1); err != nil {
+		return nil, err
+	}
+	name, err := toSymbol(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if name.Print(true) == CoreNamespaceName {
+		return nil, fmt.Errorf("cannot remove %s", CoreNamespaceName)
+	}
+	if ns := RemoveNamespace(name.Print(true)); ns != nil {
+		return ns, nil
+	}
+	return NilValue, nil
+}
+
+func allNs(args ...MalType) (MalType, error) {
+	if err := checkArgs("all-ns", args, 0); err != nil {
+		return nil, err
+	}
+	r := []MalType{}
+	for _, ns := range AllNamespaces() {
+		r = append(r, ns)
+	}
+	return NewList(r...), nil
+}
+
+func nsName(args ...MalType) (MalType, error) {
+	if err := checkArgs("ns-name", args, 1); err != nil {
+		return nil, err
+	}
+	ns, err := toNamespace(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return NewSymbol(ns.Name()), nil
+}
+
+// nsPublics returns a map from the symbols defined in a namespace, other
+// than the private ones, to their values.
+func nsPublics(args ...MalType) (MalType, error) {
+	if err := checkArgs("ns-publics", args, 1); err != nil {
+		return nil, err
+	}
+	ns, err := toNamespace(args[0])
+	if err != nil {
+		return nil, err
+	}
+	forms := []MalType{}
+	for k, v := range ns.Publics() {
+		forms = append(forms, k, v)
+	}
+	return NewHashMap(forms), nil
+}
+
+// alias implements (alias alias-name namespace-name) for the current
+// namespace.
+func alias(args ...MalType) (MalType, error) {
+	if err := checkArgs("alias", args, 2); err != nil {
+		return nil, err
+	}
+	a, err := toSymbol(args[0])
+	if err != nil {
+		return nil, err
+	}
+	target, err := toNamespace(args[1])
+	if err != nil {
+		return nil, err
+	}
+	CurrentNamespace().Alias(a, target)
+	return NilValue, nil
+}
+
+// refer implements (refer namespace-name) and (refer namespace-name :only
+// [name ...]), which let the current namespace use all, or only the named,
+// public definitions of another namespace without qualifying them.
+func refer(args ...MalType) (MalType, error) {
+	if len(args) != 1 {
+		if err := checkArgs("refer", args, 3); err != nil {
+			return nil, err
+		}
+		if args[1] != kwOnly {
+			return nil, fmt.Errorf("refer: unknown option %s", args[1].Print(true))
+		}
+	}
+	from, err := toNamespace(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 1 {
+		return NilValue, referAll(from)
+	}
+	return NilValue, referOnly(from, args[2])
+}
+
+func referAll(from *Namespace) error {
+	ns := CurrentNamespace()
+	for k := range from.Publics() {
+		if err := ns.Refer(k, from); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func referOnly(from *Namespace, names MalType) error {
+	items, err := Sequence(names)
+	if err != nil {
+		return fmt.Errorf("refer: %s", err)
+	}
+	ns := CurrentNamespace()
+	for _, v := range items {
+		sym, err := toSymbol(v)
+		if err != nil {
+			return err
+		}
+		if err := ns.Refer(sym, from); err != nil {
+			return fmt.Errorf("refer: %s", err)
+		}
+	}
+	return nil
+}
+
+// require implements (require spec ... :reload?), where each spec is the
+// name of a namespace or a vector of the name followed by options:
+//
+//	:as alias          qualify the namespace's symbols with alias
+//	:refer [name ...]  use the named definitions without qualifying them
+//	:refer :all        use all of its public definitions
+//
+// Each namespace is loaded with ModuleLoader the first time it is required,
+// and again every time if the :reload flag is given.
+func require(args ...MalType) (MalType, error) {
+	reload := false
+	specs := []MalType{}
+	for _, v := range args {
+		if v == kwReload {
+			reload = true
+		} else {
+			specs = append(specs, v)
+		}
+	}
+	for _, spec := range specs {
+		if err := requireOne(spec, reload); err != nil {
+			return nil, err
+		}
+	}
+	return NilValue, nil
+}
+
+func requireOne(spec MalType, reload bool) error {
+	var opts []MalType
+	if v, ok := spec.(*Vector); ok && v.Length() > 0 {
+		opts = v.Items()[1:]
+		spec = v.Nth(0)
+	}
+	name, ok := spec.(*Symbol)
+	if !ok {
+		return fmt.Errorf("require: not a namespace name or vector: %s", spec.Print(true))
+	}
+	if len(opts)%2 != 0 {
+		return fmt.Errorf("require: uneven number of options for %s", name.Print(true))
+	}
+
+	if ModuleLoader != nil {
+		if err := ModuleLoader.Require(name.Print(true), reload); err != nil {
+			return err
+		}
+	}
+	ns := FindNamespace(name.Print(true))
+	if ns == nil {
+		return fmt.Errorf("require: no namespace: %s", name.Print(true))
+	}
+
+	for i := 0; i < len(opts); i += 2 {
+		switch opts[i] {
+		case kwAs:
+			a, err := toSymbol(opts[i+1])
+			if err != nil {
+				return fmt.Errorf("require: %s", err)
+			}
+			CurrentNamespace().Alias(a, ns)
+		case kwRefer:
+			var err error
+			if opts[i+1] == kwAll {
+				err = referAll(ns)
+			} else {
+				err = referOnly(ns, opts[i+1])
+			}
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("require: unknown option %s", opts[i].Print(true))
+		}
+	}
+	return nil
+}
+
+// loadedLibs returns the set of the names of the namespaces loaded by
+// require.
+func loadedLibs(args ...MalType) (MalType, error) {
+	if err := checkArgs("loaded-libs", args, 0); err != nil {
+		return nil, err
+	}
+	r := NewSet()
+	if ModuleLoader != nil {
+		for _, name := range ModuleLoader.Loaded() {
+			r = r.Conj(NewSymbol(name))
+		}
+	}
+	return r, nil
+}
+
+// nsForm expands (ns name "doc"? (:require spec ...)) to code that switches
+// to the namespace and requires the specs.
+func nsForm(args ...MalType) (MalType, error) {
+	if err := checkMinArgs("ns", args, 1); err != nil {
+		return nil, err
+	}
+	name, err := toSymbol(args[0])
+	if err != nil {
+		return nil, err
+	}
+	r := []MalType{symDo, NewList(symInNs, NewList(symQuote, name))}
+	for _, v := range args[1:] {
+		if _, ok := v.(*String); ok {
+			continue
+		}
+		clause, ok := v.(*List)
+		if !ok || clause.Length() == 0 || clause.Items()[0] != NewKeyword("require") {
+			return nil, fmt.Errorf("ns: unsupported clause %s", v.Print(true))
+		}
+		call := []MalType{symRequire}
+		for _, spec := range clause.Items()[1:] {
+			call = append(call, NewList(symQuote, spec))
+		}
+		r = append(r, NewList(call...))
+	}
+	return NewList(append(r, NilValue)...), nil
+}
+
+// NamespaceFile returns the path, relative to a directory on the load
+// path, of the file defining the namespace called name: foo.bar-baz is
+// defined in foo/bar_baz.mal.
+func NamespaceFile(name string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(name, ".", "/"), "-", "_") + ".mal"
+}
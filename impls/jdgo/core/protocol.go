@@ -0,0 +1,268 @@
+package core
+
+import (
+	"fmt"
+
+	. "github.com/jdugan1024/jdgo/types"
+)
+
+// ProtocolNS holds the functions behind protocols.  The defprotocol,
+// extend-type and extend-protocol macros are defined in mal and call the
+// *-form functions here to build their expansions.
+var ProtocolNS = map[string]func(...MalType) (MalType, error){
+	"type":                 typeFn,
+	"protocol":             protocol,
+	"protocol-method":      protocolMethod,
+	"extend":               extend,
+	"extends?":             extends,
+	"satisfies?":           satisfies,
+	"defprotocol-form":     defprotocolForm,
+	"extend-type-form":     extendTypeForm,
+	"extend-protocol-form": extendProtocolForm,
+}
+
+// TypeNames are the names of the builtin types, which are bound to their
+// BuiltinType so that protocols can be extended to them.  Object is the
+// fallback for the types a protocol is not extended to.
+var TypeNames = []string{
+	"Nil", "Boolean", "Int", "Float", "String", "Keyword", "Symbol",
+	"List", "Vector", "HashMap", "Set", "Cons", "LazySeq",
+	"Function", "Closure", "MultiFn", "Atom", "Object",
+}
+
+func toProtocol(v MalType) (*Protocol, error) {
+	p, ok := v.(*Protocol)
+	if !ok {
+		return nil, fmt.Errorf("argument is not a protocol: %s", v.Print(true))
+	}
+	return p, nil
+}
+
+// toType returns v as a type that a protocol can be extended to; nil
+// stands for the type of nil.
+func toType(v MalType) (MalType, error) {
+	switch t := v.(type) {
+	case *BuiltinType, *RecordType:
+		return t, nil
+	case *Nil:
+		return TypeOf(t), nil
+	}
+	return nil, fmt.Errorf("argument is not a type: %s", v.Print(true))
+}
+
+func typeFn(args ...MalType) (MalType, error) {
+	if err := checkArgs("type", args, 1); err != nil {
+		return nil, err
+	}
+	return TypeOf(args[0]), nil
+}
+
+// protocol implements (protocol name (method ...)), which returns a new
+// protocol with no implementations.
+func protocol(args ...MalType) (MalType, error) {
+	if err := checkArgs("protocol", args, 2); err != nil {
+		return nil, err
+	}
+	name, ok := args[0].(*Symbol)
+	if !ok {
+		return nil, fmt.Errorf("protocol: name is not a symbol: %s", args[0].Print(true))
+	}
+	items, err := Sequence(args[1])
+	if err != nil {
+		return nil, fmt.Errorf("protocol: %s", err)
+	}
+	methods := make([]string, 0, len(items))
+	for _, v := range items {
+		m, ok := v.(*Symbol)
+		if !ok {
+			return nil, fmt.Errorf("protocol: method name is not a symbol: %s", v.Print(true))
+		}
+		methods = append(methods, m.Print(true))
+	}
+	return NewProtocol(name.Print(true), methods), nil
+}
+
+func protocolMethod(args ...MalType) (MalType, error) {
+	if err := checkArgs("protocol-method", args, 2); err != nil {
+		return nil, err
+	}
+	p, err := toProtocol(args[0])
+	if err != nil {
+		return nil, err
+	}
+	name, ok := args[1].(*Symbol)
+	if !ok {
+		return nil, fmt.Errorf("protocol-method: method name is not a symbol: %s", args[1].Print(true))
+	}
+	return p.Method(name.Print(true)), nil
+}
+
+// extend implements (extend type protocol {:method f ...} ...), extending
+// each protocol to type with the functions in the map that follows it.
+func extend(args ...MalType) (MalType, error) {
+	if err := checkMinArgs("extend", args, 3); err != nil {
+		return nil, err
+	}
+	if len(args)%2 != 1 {
+		return nil, fmt.Errorf("extend: uneven number of protocol/method map arguments")
+	}
+	t, err := toType(args[0])
+	if err != nil {
+		return nil, err
+	}
+	for i := 1; i < len(args); i += 2 {
+		p, err := toProtocol(args[i])
+		if err != nil {
+			return nil, err
+		}
+		hm, err := toHashMap(args[i+1])
+		if err != nil {
+			return nil, err
+		}
+		impls := map[string]MalType{}
+		for _, k := range hm.Keys() {
+			name, ok := k.(*String)
+			if !ok || !name.IsKeyword() {
+				return nil, fmt.Errorf("extend: method name is not a keyword: %s", k.Print(true))
+			}
+			impls[name.Value()], _ = hm.Get(k)
+		}
+		if err := p.Extend(t, impls); err != nil {
+			return nil, fmt.Errorf("extend: %s", err)
+		}
+	}
+	return NilValue, nil
+}
+
+func extends(args ...MalType) (MalType, error) {
+	if err := checkArgs("extends?", args, 2); err != nil {
+		return nil, err
+	}
+	p, err := toProtocol(args[0])
+	if err != nil {
+		return nil, err
+	}
+	t, err := toType(args[1])
+	if err != nil {
+		return nil, err
+	}
+	return NewBoolean(p.Extends(t)), nil
+}
+
+func satisfies(args ...MalType) (MalType, error) {
+	if err := checkArgs("satisfies?", args, 2); err != nil {
+		return nil, err
+	}
+	p, err := toProtocol(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return NewBoolean(p.Satisfies(args[1])), nil
+}
+
+var (
+	symDo             = NewSymbol("do")
+	symDef            = NewSymbol("def!")
+	symFn             = NewSymbol("fn*")
+	symQuote          = NewSymbol("quote")
+	symExtend         = NewSymbol("extend")
+	symProtocol       = NewSymbol("protocol")
+	symProtocolMethod = NewSymbol("protocol-method")
+)
+
+// defprotocolForm expands (defprotocol Name "doc"? (method [this ...] "doc"?) ...)
+// to code that defines the protocol and a function for each method.  Only
+// the method names are used; the argument lists and doc strings are there
+// for the reader.
+func defprotocolForm(args ...MalType) (MalType, error) {
+	if err := checkMinArgs("defprotocol", args, 1); err != nil {
+		return nil, err
+	}
+	name, ok := args[0].(*Symbol)
+	if !ok {
+		return nil, fmt.Errorf("defprotocol: name is not a symbol: %s", args[0].Print(true))
+	}
+	names := []MalType{}
+	defs := []MalType{}
+	for _, v := range args[1:] {
+		if _, ok := v.(*String); ok {
+			continue
+		}
+		sig, ok := v.(*List)
+		if !ok || sig.Length() == 0 {
+			return nil, fmt.Errorf("defprotocol: malformed method signature: %s", v.Print(true))
+		}
+		m, ok := sig.Items()[0].(*Symbol)
+		if !ok {
+			return nil, fmt.Errorf("defprotocol: method name is not a symbol: %s", sig.Items()[0].Print(true))
+		}
+		names = append(names, m)
+		defs = append(defs, NewList(symDef, m, NewList(symProtocolMethod, name, NewList(symQuote, m))))
+	}
+	r := []MalType{symDo, NewList(symDef, name, NewList(symProtocol, NewList(symQuote, name), NewList(symQuote, NewList(names...))))}
+	r = append(r, defs...)
+	return NewList(append(r, name)...), nil
+}
+
+// extendTypeForm expands (extend-type Type Protocol (method [this ...] body ...) ...)
+// to a call to extend.
+func extendTypeForm(args ...MalType) (MalType, error) {
+	if err := checkMinArgs("extend-type", args, 1); err != nil {
+		return nil, err
+	}
+	call := []MalType{symExtend, args[0]}
+	groups, err := implGroups("extend-type", args[1:])
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range groups {
+		call = append(call, g...)
+	}
+	return NewList(call...), nil
+}
+
+// extendProtocolForm expands (extend-protocol Protocol Type (method [this ...] body ...) ...)
+// to a call to extend for each type.
+func extendProtocolForm(args ...MalType) (MalType, error) {
+	if err := checkMinArgs("extend-protocol", args, 1); err != nil {
+		return nil, err
+	}
+	groups, err := implGroups("extend-protocol", args[1:])
+	if err != nil {
+		return nil, err
+	}
+	r := []MalType{symDo}
+	for _, g := range groups {
+		r = append(r, NewList(symExtend, g[0], args[0], g[1]))
+	}
+	return NewList(append(r, NilValue)...), nil
+}
+
+// implGroups splits forms, a sequence of names each followed by method
+// implementations, into pairs of the name and a map from the method names
+// to the fn* forms implementing them.
+func implGroups(caller string, forms []MalType) ([][]MalType, error) {
+	groups := [][]MalType{}
+	for _, v := range forms {
+		impl, ok := v.(*List)
+		if !ok {
+			groups = append(groups, []MalType{v, NewHashMap(nil)})
+			continue
+		}
+		if len(groups) == 0 {
+			return nil, fmt.Errorf("%s: method implementation before a name: %s", caller, v.Print(true))
+		}
+		items := impl.Items()
+		if len(items) < 2 {
+			return nil, fmt.Errorf("%s: malformed method implementation: %s", caller, v.Print(true))
+		}
+		m, ok := items[0].(*Symbol)
+		if !ok {
+			return nil, fmt.Errorf("%s: method name is not a symbol: %s", caller, items[0].Print(true))
+		}
+		body := append([]MalType{symDo}, items[2:]...)
+		g := groups[len(groups)-1]
+		g[1] = g[1].(*HashMap).Assoc(NewKeyword(m.Print(true)), NewList(symFn, items[1], NewList(body...)))
+	}
+	return groups, nil
+}
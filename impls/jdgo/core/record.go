@@ -0,0 +1,139 @@
+package core
+
+import (
+	"fmt"
+
+	. "github.com/jdugan1024/jdgo/types"
+)
+
+// RecordNS holds the functions behind the defrecord and deftype macros,
+// which define a type along with its constructors and predicate.
+var RecordNS = map[string]func(...MalType) (MalType, error){
+	"record-type":      recordType,
+	"record":           record,
+	"map->record":      mapToRecord,
+	"record?":          isRecord,
+	"deftype-type":     deftypeType,
+	"deftype-instance": deftypeInstance,
+	"instance?":        isInstance,
+}
+
+var isRecord = isType[*Record]("record?")
+
+// toRecordType returns v as a type defined with defrecord or, with
+// deftype set, with deftype.
+func toRecordType(v MalType, deftype bool) (*RecordType, error) {
+	rt, ok := v.(*RecordType)
+	if !ok || rt.IsDeftype() != deftype {
+		if deftype {
+			return nil, fmt.Errorf("argument is not a deftype: %s", v.Print(true))
+		}
+		return nil, fmt.Errorf("argument is not a record type: %s", v.Print(true))
+	}
+	return rt, nil
+}
+
+// recordType implements (record-type name [field ...]), which returns a new
+// record type with fields named by the keywords of the field symbols.
+func recordType(args ...MalType) (MalType, error) {
+	name, fields, err := typeFields("record-type", args)
+	if err != nil {
+		return nil, err
+	}
+	return NewRecordType(CurrentNamespace().Name(), name, fields), nil
+}
+
+// deftypeType implements (deftype-type name [field ...]), which is
+// record-type for deftype.
+func deftypeType(args ...MalType) (MalType, error) {
+	name, fields, err := typeFields("deftype-type", args)
+	if err != nil {
+		return nil, err
+	}
+	return NewDeftype(CurrentNamespace().Name(), name, fields), nil
+}
+
+// typeFields returns the name and the field keywords given to the function
+// name as a symbol and a sequence of field symbols.
+func typeFields(name string, args []MalType) (string, []*String, error) {
+	if err := checkArgs(name, args, 2); err != nil {
+		return "", nil, err
+	}
+	sym, ok := args[0].(*Symbol)
+	if !ok {
+		return "", nil, fmt.Errorf("%s: name is not a symbol: %s", name, args[0].Print(true))
+	}
+	items, err := Sequence(args[1])
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %s", name, err)
+	}
+	fields := make([]*String, 0, len(items))
+	for _, v := range items {
+		f, ok := v.(*Symbol)
+		if !ok {
+			return "", nil, fmt.Errorf("%s: field is not a symbol: %s", name, v.Print(true))
+		}
+		fields = append(fields, NewKeyword(f.Print(true)))
+	}
+	return sym.Print(true), fields, nil
+}
+
+// record implements (record type value ...), taking the field values in
+// the order the fields were declared.
+func record(args ...MalType) (MalType, error) {
+	if err := checkMinArgs("record", args, 1); err != nil {
+		return nil, err
+	}
+	rt, err := toRecordType(args[0], false)
+	if err != nil {
+		return nil, err
+	}
+	return NewRecord(rt, args[1:]...)
+}
+
+// deftypeInstance implements (deftype-instance type value ...), taking the
+// field values in the order the fields were declared.
+func deftypeInstance(args ...MalType) (MalType, error) {
+	if err := checkMinArgs("deftype-instance", args, 1); err != nil {
+		return nil, err
+	}
+	rt, err := toRecordType(args[0], true)
+	if err != nil {
+		return nil, err
+	}
+	return NewInstance(rt, args[1:]...)
+}
+
+func mapToRecord(args ...MalType) (MalType, error) {
+	if err := checkArgs("map->record", args, 2); err != nil {
+		return nil, err
+	}
+	rt, err := toRecordType(args[0], false)
+	if err != nil {
+		return nil, err
+	}
+	hm, err := toHashMap(args[1])
+	if err != nil {
+		return nil, err
+	}
+	return NewRecordFromMap(rt, hm), nil
+}
+
+// isInstance implements (instance? type x), which reports whether x is a
+// record or deftype instance of the given type.
+func isInstance(args ...MalType) (MalType, error) {
+	if err := checkArgs("instance?", args, 2); err != nil {
+		return nil, err
+	}
+	rt, ok := args[0].(*RecordType)
+	if !ok {
+		return nil, fmt.Errorf("argument is not a record type or deftype: %s", args[0].Print(true))
+	}
+	switch v := args[1].(type) {
+	case *Record:
+		return NewBoolean(v.Type() == rt), nil
+	case *Instance:
+		return NewBoolean(v.Type() == rt), nil
+	}
+	return FalseValue, nil
+}
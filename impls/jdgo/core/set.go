@@ -0,0 +1,151 @@
+package core
+
+import (
+	"fmt"
+
+	. "github.com/jdugan1024/jdgo/types"
+)
+
+// SetNS holds the set algebra functions, keyed by their unqualified names.
+// The interpreter defines them in a namespace of their own, set, following
+// Clojure's clojure.set, so they are called as set/union, or required with
+// an alias as in (require '[set :as s]).
+var SetNS = map[string]func(...MalType) (MalType, error){
+	"union":        union,
+	"intersection": intersection,
+	"difference":   difference,
+	"subset?":      isSubset,
+	"superset?":    isSuperset,
+}
+
+// SetDynamicNS holds the functions of the set namespace that call the
+// functions they are given, with the dynamic var bindings of their caller.
+var SetDynamicNS = map[string]func(*Bindings, ...MalType) (MalType, error){
+	"select": selectSet,
+}
+
+// toSets converts args to Sets, treating nil as the empty set.
+func toSets(name string, args []MalType) ([]*Set, error) {
+	r := make([]*Set, 0, len(args))
+	for _, v := range args {
+		if _, ok := v.(*Nil); ok {
+			r = append(r, NewSet())
+			continue
+		}
+		s, err := toSet(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		r = append(r, s)
+	}
+	return r, nil
+}
+
+func union(args ...MalType) (MalType, error) {
+	sets, err := toSets("set/union", args)
+	if err != nil {
+		return nil, err
+	}
+	r := NewSet()
+	for _, s := range sets {
+		for _, v := range s.Items() {
+			r = r.Conj(v)
+		}
+	}
+	return r, nil
+}
+
+func intersection(args ...MalType) (MalType, error) {
+	if err := checkMinArgs("set/intersection", args, 1); err != nil {
+		return nil, err
+	}
+	sets, err := toSets("set/intersection", args)
+	if err != nil {
+		return nil, err
+	}
+	r := sets[0]
+	for _, s := range sets[1:] {
+		for _, v := range r.Items() {
+			if !s.Contains(v) {
+				r = r.Disj(v)
+			}
+		}
+	}
+	return r, nil
+}
+
+func difference(args ...MalType) (MalType, error) {
+	if err := checkMinArgs("set/difference", args, 1); err != nil {
+		return nil, err
+	}
+	sets, err := toSets("set/difference", args)
+	if err != nil {
+		return nil, err
+	}
+	r := sets[0]
+	for _, s := range sets[1:] {
+		for _, v := range s.Items() {
+			r = r.Disj(v)
+		}
+	}
+	return r, nil
+}
+
+func subset(name string, args []MalType) (bool, error) {
+	if err := checkArgs(name, args, 2); err != nil {
+		return false, err
+	}
+	sets, err := toSets(name, args)
+	if err != nil {
+		return false, err
+	}
+	if sets[0].Length() > sets[1].Length() {
+		return false, nil
+	}
+	for _, v := range sets[0].Items() {
+		if !sets[1].Contains(v) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func isSubset(args ...MalType) (MalType, error) {
+	ok, err := subset("set/subset?", args)
+	if err != nil {
+		return nil, err
+	}
+	return NewBoolean(ok), nil
+}
+
+func isSuperset(args ...MalType) (MalType, error) {
+	if len(args) == 2 {
+		args = []MalType{args[1], args[0]}
+	}
+	ok, err := subset("set/superset?", args)
+	if err != nil {
+		return nil, err
+	}
+	return NewBoolean(ok), nil
+}
+
+func selectSet(b *Bindings, args ...MalType) (MalType, error) {
+	if err := checkArgs("set/select", args, 2); err != nil {
+		return nil, err
+	}
+	sets, err := toSets("set/select", args[1:])
+	if err != nil {
+		return nil, err
+	}
+	r := sets[0]
+	for _, v := range r.Items() {
+		keep, err := Apply(b, args[0], v)
+		if err != nil {
+			return nil, err
+		}
+		if !Truthy(keep) {
+			r = r.Disj(v)
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,314 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	. "github.com/jdugan1024/jdgo/types"
+)
+
+// ReduceNS holds reduce and the functions for working with transducers.
+//
+// A reducing function takes an accumulated result and an item and returns
+// the new result; it can return (reduced result) to stop the reduction.  A
+// transducer takes a reducing function and returns another one, so that
+// (comp (map f) (filter g)) describes a pipeline without building the
+// intermediate sequences.  The reducing functions built by transducers also
+// accept no arguments, to produce an initial result, and just the result, to
+// complete it; transduce and into add these arities to the mal function they
+// are given.
+var ReduceNS = map[string]func(...MalType) (MalType, error){
+	"reduced":  reduced,
+	"reduced?": isReduced,
+	"comp":     comp,
+}
+
+// ReduceDynamicNS holds the functions of ReduceNS that call the functions
+// they are given.  Each is also given the dynamic var bindings of its
+// caller, which it calls them with.
+var ReduceDynamicNS = map[string]func(*Bindings, ...MalType) (MalType, error){
+	"reduce":    reduce,
+	"transduce": transduce,
+	"into":      into,
+}
+
+var isReduced = isType[*Reduced]("reduced?")
+
+func reduced(args ...MalType) (MalType, error) {
+	if err := checkArgs("reduced", args, 1); err != nil {
+		return nil, err
+	}
+	return NewReduced(args[0]), nil
+}
+
+// reduce implements (reduce f coll) and (reduce f init coll).
+func reduce(b *Bindings, args ...MalType) (MalType, error) {
+	switch len(args) {
+	case 2:
+		s, err := ToSeq(args[1])
+		if err != nil {
+			return nil, err
+		}
+		x, rest, ok, err := uncons(s)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return Apply(b, args[0])
+		}
+		return reduceSeq(b, args[0], x, rest)
+	case 3:
+		s, err := ToSeq(args[2])
+		if err != nil {
+			return nil, err
+		}
+		return reduceSeq(b, args[0], args[1], s)
+	}
+	return nil, checkArgs("reduce", args, 3)
+}
+
+// reduceSeq applies f to acc and each item of s in turn, stopping early if
+// f returns a Reduced.
+func reduceSeq(b *Bindings, f, acc MalType, s Seq) (MalType, error) {
+	err := each(s, func(x MalType) (bool, error) {
+		r, err := Apply(b, f, acc, x)
+		if err != nil {
+			return false, err
+		}
+		if red, ok := r.(*Reduced); ok {
+			acc = red.Deref()
+			return false, nil
+		}
+		acc = r
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return acc, nil
+}
+
+// each calls f with the items of s until f returns false.  Lazy sequences
+// are walked one item at a time, so f can stop before an infinite sequence
+// is realized.
+func each(s Seq, f func(MalType) (bool, error)) error {
+	switch s.(type) {
+	case *List, *Vector, *Set:
+		items, _ := Sequence(s)
+		for _, x := range items {
+			more, err := f(x)
+			if !more || err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for {
+		x, rest, ok, err := uncons(s)
+		if !ok || err != nil {
+			return err
+		}
+		more, err := f(x)
+		if !more || err != nil {
+			return err
+		}
+		s = rest
+	}
+}
+
+// completing turns the mal function f, which takes a result and an item,
+// into a reducing function whose completion arity returns the result as is.
+func completing(f MalType) MalType {
+	return NewDynamicFunction("completing", func(b *Bindings, args ...MalType) (MalType, error) {
+		if len(args) == 1 {
+			return args[0], nil
+		}
+		return Apply(b, f, args...)
+	})
+}
+
+// transduce implements (transduce xform f coll) and
+// (transduce xform f init coll).
+func transduce(b *Bindings, args ...MalType) (MalType, error) {
+	if len(args) != 3 && len(args) != 4 {
+		return nil, checkArgs("transduce", args, 4)
+	}
+	f := completing(args[1])
+	rf, err := Apply(b, args[0], f)
+	if err != nil {
+		return nil, err
+	}
+	var init MalType
+	if len(args) == 4 {
+		init = args[2]
+	} else if init, err = Apply(b, args[1]); err != nil {
+		return nil, err
+	}
+	s, err := ToSeq(args[len(args)-1])
+	if err != nil {
+		return nil, err
+	}
+	acc, err := reduceSeq(b, rf, init, s)
+	if err != nil {
+		return nil, err
+	}
+	return Apply(b, rf, acc)
+}
+
+// into implements (into to from) and (into to xform from), adding the items
+// of from to to with conj.
+func into(b *Bindings, args ...MalType) (MalType, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, checkArgs("into", args, 3)
+	}
+	conjFn := NewFunction("conj", conj)
+	if len(args) == 3 {
+		return transduce(b, args[1], conjFn, args[0], args[2])
+	}
+	s, err := ToSeq(args[1])
+	if err != nil {
+		return nil, err
+	}
+	return reduceSeq(b, conjFn, args[0], s)
+}
+
+// comp returns the composition of its arguments: ((comp f g) x) is (f (g x)).
+func comp(args ...MalType) (MalType, error) {
+	fns := args
+	return NewDynamicFunction("comp", func(b *Bindings, args ...MalType) (MalType, error) {
+		if len(fns) == 0 {
+			if len(args) != 1 {
+				return nil, checkArgs("comp", args, 1)
+			}
+			return args[0], nil
+		}
+		r, err := Apply(b, fns[len(fns)-1], args...)
+		for i := len(fns) - 2; i >= 0 && err == nil; i-- {
+			r, err = Apply(b, fns[i], r)
+		}
+		return r, err
+	}), nil
+}
+
+// transducer returns a transducer that builds its reducing function with xf,
+// which is called afresh each time the transducer is applied so that any
+// state it keeps is not shared.  The reducing function calls the functions
+// it uses with the dynamic var bindings it is itself called with.
+func transducer(name string, xf func(rf MalType) func(*Bindings, ...MalType) (MalType, error)) MalType {
+	return NewFunction(name, func(args ...MalType) (MalType, error) {
+		if err := checkArgs(name, args, 1); err != nil {
+			return nil, err
+		}
+		return NewDynamicFunction(name, xf(args[0])), nil
+	})
+}
+
+// stepper returns a reducing function that passes the init and completion
+// arities on to rf and handles items with step.
+func stepper(rf MalType, step func(b *Bindings, acc, x MalType) (MalType, error)) func(*Bindings, ...MalType) (MalType, error) {
+	return func(b *Bindings, args ...MalType) (MalType, error) {
+		switch len(args) {
+		case 0:
+			return Apply(b, rf)
+		case 1:
+			return Apply(b, rf, args[0])
+		case 2:
+			return step(b, args[0], args[1])
+		}
+		return nil, fmt.Errorf("reducing function: wrong number of arguments (%d)", len(args))
+	}
+}
+
+func mapXf(f MalType) MalType {
+	return transducer("map", func(rf MalType) func(*Bindings, ...MalType) (MalType, error) {
+		return stepper(rf, func(b *Bindings, acc, x MalType) (MalType, error) {
+			y, err := Apply(b, f, x)
+			if err != nil {
+				return nil, err
+			}
+			return Apply(b, rf, acc, y)
+		})
+	})
+}
+
+// filterXf keeps the items for which pred is truthy, or with remove set the
+// items for which it is not.
+func filterXf(name string, pred MalType, remove bool) MalType {
+	return transducer(name, func(rf MalType) func(*Bindings, ...MalType) (MalType, error) {
+		return stepper(rf, func(b *Bindings, acc, x MalType) (MalType, error) {
+			keep, err := Apply(b, pred, x)
+			if err != nil {
+				return nil, err
+			}
+			if Truthy(keep) == remove {
+				return acc, nil
+			}
+			return Apply(b, rf, acc, x)
+		})
+	})
+}
+
+func takeXf(n int) MalType {
+	return transducer("take", func(rf MalType) func(*Bindings, ...MalType) (MalType, error) {
+		remaining := n
+		return stepper(rf, func(b *Bindings, acc, x MalType) (MalType, error) {
+			if remaining > 0 {
+				remaining--
+				var err error
+				if acc, err = Apply(b, rf, acc, x); err != nil {
+					return nil, err
+				}
+			}
+			if _, ok := acc.(*Reduced); !ok && remaining <= 0 {
+				acc = NewReduced(acc)
+			}
+			return acc, nil
+		})
+	})
+}
+
+func partitionAllXf(n int) MalType {
+	return transducer("partition-all", func(rf MalType) func(*Bindings, ...MalType) (MalType, error) {
+		buf := []MalType{}
+		return func(b *Bindings, args ...MalType) (MalType, error) {
+			switch len(args) {
+			case 0:
+				return Apply(b, rf)
+			case 1:
+				acc := args[0]
+				if len(buf) > 0 {
+					r, err := Apply(b, rf, acc, NewVector(buf...))
+					if err != nil {
+						return nil, err
+					}
+					buf = nil
+					if red, ok := r.(*Reduced); ok {
+						r = red.Deref()
+					}
+					acc = r
+				}
+				return Apply(b, rf, acc)
+			case 2:
+				buf = append(buf, args[1])
+				if len(buf) < n {
+					return args[0], nil
+				}
+				v := NewVector(buf...)
+				buf = []MalType{}
+				return Apply(b, rf, args[0], v)
+			}
+			return nil, fmt.Errorf("reducing function: wrong number of arguments (%d)", len(args))
+		}
+	})
+}
+
+func partitionSize(v MalType) (int, error) {
+	n, err := toInt(v)
+	if err != nil {
+		return 0, err
+	}
+	if n <= 0 {
+		return 0, errors.New("partition-all: size must be positive")
+	}
+	return n, nil
+}
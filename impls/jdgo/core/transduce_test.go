@@ -0,0 +1,69 @@
+package core
+
+import (
+	"testing"
+
+	. "github.com/jdugan1024/jdgo/types"
+)
+
+// TestReducedStopsEarly checks that reduce, transduce and into stop as soon
+// as the reducing function returns a reduced value, which they must do to
+// finish on the infinite (range).
+func TestReducedStopsEarly(t *testing.T) {
+	n := NewIntFromInt
+	seen := 0
+	// upTo3 adds up the items it is given and stops after 3.
+	upTo3 := NewFunction("up-to-3", func(args ...MalType) (MalType, error) {
+		if len(args) == 0 {
+			return n(0), nil
+		}
+		seen++
+		acc, err := NS["+"](args...)
+		if err != nil || args[1].Equal(n(3)) {
+			return NewReduced(acc), err
+		}
+		return acc, nil
+	})
+	inc := NewFunction("inc", func(args ...MalType) (MalType, error) { return NS["+"](args[0], n(1)) })
+	plus := NewFunction("+", NS["+"])
+	for _, c := range []struct {
+		name string
+		f    string
+		args func() []MalType
+		want MalType
+		seen int
+	}{
+		{"reduce", "reduce", func() []MalType { return []MalType{upTo3, inf()} }, n(6), 3},
+		{"reduce with init", "reduce", func() []MalType { return []MalType{upTo3, n(10), inf()} }, n(16), 4},
+		{"transduce", "transduce", func() []MalType { return []MalType{mapXf(inc), upTo3, inf()} }, n(6), 3},
+		{"transduce with take", "transduce", func() []MalType { return []MalType{takeXf(2), plus, n(0), inf()} }, n(1), 0},
+		{"take inside the reducer's limit", "transduce", func() []MalType { return []MalType{takeXf(10), upTo3, inf()} }, n(6), 4},
+		{"into", "into", func() []MalType { return []MalType{NewVector(), takeXf(3), inf()} }, NewVector(n(0), n(1), n(2)), 0},
+		{"into a list", "into", func() []MalType {
+			xf, _ := comp(mapXf(inc), takeXf(2))
+			return []MalType{NewList(), xf, inf()}
+		}, NewList(n(2), n(1)), 0},
+	} {
+		seen = 0
+		got, err := ReduceDynamicNS[c.f](nil, c.args()...)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if !got.Equal(c.want) {
+			t.Errorf("%s: got %s, want %s", c.name, got.Print(true), c.want.Print(true))
+		}
+		if seen != c.seen {
+			t.Errorf("%s: the reducing function saw %d items, want %d", c.name, seen, c.seen)
+		}
+	}
+}
+
+// inf returns (range), which never ends.
+func inf() MalType {
+	r, err := rangeFn()
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
@@ -1,6 +1,6 @@
 module github.com/jdugan1024/jdgo
 
-go 1.21.2
+go 1.24
 
 require github.com/chzyer/readline v1.5.1
 
@@ -0,0 +1,227 @@
+package printer
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	. "github.com/jdugan1024/jdgo/types"
+)
+
+// NoLimit disables the corresponding truncation in Limits.
+const NoLimit = -1
+
+// Limits controls how much of a value PrettyPrintLimits prints.  At most
+// Length items of each collection are printed and collections nested more
+// than Level deep are elided; the elided part is printed as "...".
+type Limits struct {
+	Length int
+	Level  int
+}
+
+// The pretty printer follows Wadler's "prettier printer": a value is first
+// turned into a document made of text, line breaks, nesting and groups, and
+// the document is then laid out so that each group is printed flat on one
+// line if it fits in the remaining width and broken over several lines
+// otherwise.
+type doc interface{}
+
+type text string
+
+// line is a space when its group is printed flat and a newline followed by
+// the current indentation when it is broken.
+type line struct{}
+
+type concat []doc
+
+type nest struct {
+	indent int
+	doc    doc
+}
+
+type group struct {
+	doc doc
+}
+
+// PrettyPrint writes v to w, breaking collections over several lines so
+// that the output stays within width columns where possible.
+func PrettyPrint(w io.Writer, v MalType, width int) error {
+	return PrettyPrintLimits(w, v, width, Limits{NoLimit, NoLimit})
+}
+
+// PrettyPrintLimits is like PrettyPrint but truncates v according to limits.
+func PrettyPrintLimits(w io.Writer, v MalType, width int, limits Limits) error {
+	b := bufio.NewWriter(w)
+	layout(b, width, toDoc(v, limits, 0))
+	b.WriteString("\n")
+	return b.Flush()
+}
+
+func toDoc(v MalType, limits Limits, level int) doc {
+	switch c := v.(type) {
+	case *List:
+		return seqDoc("(", ")", c.Items(), limits, level)
+	case *Vector:
+		return seqDoc("[", "]", c.Items(), limits, level)
+	case *Set:
+		return seqDoc("#{", "}", c.Items(), limits, level)
+	case *Cons, *LazySeq:
+		return seqDoc("(", ")", seqPrefix(c.(Seq), limits.Length), limits, level)
+	case *HashMap:
+		return mapDoc("{", c, limits, level)
+	case *Record:
+		return mapDoc("#"+c.Type().Print(true)+"{", c.Map(), limits, level)
+	}
+	return text(v.Print(true))
+}
+
+func mapDoc(open string, hm *HashMap, limits Limits, level int) doc {
+	if limits.Level != NoLimit && level >= limits.Level {
+		return text("...")
+	}
+	entries := []doc{}
+	for i, k := range hm.Keys() {
+		if limits.Length != NoLimit && i >= limits.Length {
+			entries = append(entries, text("..."))
+			break
+		}
+		val, _ := hm.Get(k)
+		entries = append(entries, group{concat{
+			toDoc(k, limits, level+1),
+			nest{2, concat{line{}, toDoc(val, limits, level+1)}},
+		}})
+	}
+	return bracket(open, "}", entries)
+}
+
+func seqDoc(open, close string, items []MalType, limits Limits, level int) doc {
+	if limits.Level != NoLimit && level >= limits.Level {
+		return text("...")
+	}
+	docs := []doc{}
+	for i, v := range items {
+		if limits.Length != NoLimit && i >= limits.Length {
+			docs = append(docs, text("..."))
+			break
+		}
+		docs = append(docs, toDoc(v, limits, level+1))
+	}
+	return bracket(open, close, docs)
+}
+
+// seqPrefix returns up to n+1 items of s, or all of them if n is NoLimit, so
+// that an infinite sequence can be printed when *print-length* is set.
+// Realizing s can fail; the items before the failure are returned.
+func seqPrefix(s Seq, n int) []MalType {
+	r := []MalType{}
+	for n == NoLimit || len(r) <= n {
+		empty, err := s.IsEmpty()
+		if err != nil || empty {
+			break
+		}
+		v, err := s.First()
+		if err != nil {
+			break
+		}
+		r = append(r, v)
+		if s, err = s.Rest(); err != nil {
+			break
+		}
+	}
+	return r
+}
+
+func bracket(open, close string, items []doc) doc {
+	body := concat{}
+	for i, d := range items {
+		if i > 0 {
+			body = append(body, line{})
+		}
+		body = append(body, d)
+	}
+	return group{concat{text(open), nest{len(open), body}, text(close)}}
+}
+
+type mode int
+
+const (
+	flat mode = iota
+	broken
+)
+
+type frame struct {
+	indent int
+	mode   mode
+	doc    doc
+}
+
+func layout(w *bufio.Writer, width int, d doc) {
+	col := 0
+	stack := []frame{{0, broken, d}}
+	for len(stack) > 0 {
+		f := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		switch d := f.doc.(type) {
+		case text:
+			w.WriteString(string(d))
+			col += utf8.RuneCountInString(string(d))
+		case line:
+			if f.mode == flat {
+				w.WriteString(" ")
+				col++
+			} else {
+				w.WriteString("\n")
+				w.WriteString(strings.Repeat(" ", f.indent))
+				col = f.indent
+			}
+		case concat:
+			for i := len(d) - 1; i >= 0; i-- {
+				stack = append(stack, frame{f.indent, f.mode, d[i]})
+			}
+		case nest:
+			stack = append(stack, frame{f.indent + d.indent, f.mode, d.doc})
+		case group:
+			m := broken
+			if f.mode == flat || fits(width-col, frame{f.indent, flat, d.doc}, stack) {
+				m = flat
+			}
+			stack = append(stack, frame{f.indent, m, d.doc})
+		}
+	}
+}
+
+// fits reports whether f, followed by the rest of the output up to the next
+// line break, fits in width columns when f is printed flat.
+func fits(width int, f frame, rest []frame) bool {
+	stack := []frame{f}
+	for width >= 0 {
+		if len(stack) == 0 {
+			if len(rest) == 0 {
+				return true
+			}
+			stack = append(stack, rest[len(rest)-1])
+			rest = rest[:len(rest)-1]
+		}
+		f := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		switch d := f.doc.(type) {
+		case text:
+			width -= utf8.RuneCountInString(string(d))
+		case line:
+			if f.mode == broken {
+				return true
+			}
+			width--
+		case concat:
+			for i := len(d) - 1; i >= 0; i-- {
+				stack = append(stack, frame{f.indent, f.mode, d[i]})
+			}
+		case nest:
+			stack = append(stack, frame{f.indent + d.indent, f.mode, d.doc})
+		case group:
+			stack = append(stack, frame{f.indent, f.mode, d.doc})
+		}
+	}
+	return false
+}
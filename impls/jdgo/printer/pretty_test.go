@@ -0,0 +1,50 @@
+package printer
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/jdugan1024/jdgo/types"
+)
+
+func ints(n int) []MalType {
+	r := make([]MalType, n)
+	for i := range r {
+		r[i] = NewIntFromInt(i)
+	}
+	return r
+}
+
+// TestPrettyPrint checks that collections are printed on one line when
+// they fit in the width, broken over several when they do not, and
+// truncated by the limits *print-length* and *print-level* give.
+func TestPrettyPrint(t *testing.T) {
+	nested := NewHashMap([]MalType{
+		NewKeyword("name"), NewString("pretty"),
+		NewKeyword("items"), NewVector(ints(12)...),
+		NewKeyword("more"), NewList(NewList(NewSymbol("a"), NewSymbol("b")), NewVector(NewString("c"))),
+	})
+	for _, c := range []struct {
+		name   string
+		v      MalType
+		width  int
+		limits Limits
+		want   string
+	}{
+		{"fits", NewVector(ints(3)...), 80, Limits{NoLimit, NoLimit}, "[0 1 2]"},
+		{"breaks", NewVector(ints(12)...), 10, Limits{NoLimit, NoLimit}, "[0\n 1\n 2\n 3\n 4\n 5\n 6\n 7\n 8\n 9\n 10\n 11]"},
+		{"map", nested, 30, Limits{NoLimit, NoLimit}, "{:name \"pretty\"\n :items\n   [0 1 2 3 4 5 6 7 8 9 10 11]\n :more ((a b) [\"c\"])}"},
+		{"map flat", nested, 200, Limits{NoLimit, NoLimit}, `{:name "pretty" :items [0 1 2 3 4 5 6 7 8 9 10 11] :more ((a b) ["c"])}`},
+		{"length", NewList(ints(5)...), 80, Limits{Length: 3, Level: NoLimit}, "(0 1 2 ...)"},
+		{"level", NewVector(NewIntFromInt(1), NewVector(NewIntFromInt(2), NewVector(NewIntFromInt(3)))), 80, Limits{Length: NoLimit, Level: 2}, "[1 [2 ...]]"},
+		{"strings", NewString("a\"b"), 80, Limits{NoLimit, NoLimit}, `"a\"b"`},
+	} {
+		var b strings.Builder
+		if err := PrettyPrintLimits(&b, c.v, c.width, c.limits); err != nil {
+			t.Fatal(err)
+		}
+		if got := b.String(); got != c.want+"\n" {
+			t.Errorf("%s: got\n%s\nwant\n%s", c.name, got, c.want)
+		}
+	}
+}
@@ -1,7 +1,23 @@
 package printer
 
-import . "github.com/jdugan1024/jdgo/types"
+import (
+	"strings"
 
-func PrintStr(ast MalType) string {
-	return ast.Print()
+	. "github.com/jdugan1024/jdgo/types"
+)
+
+// PrintStr renders ast.  With readably set strings are quoted and escaped,
+// as pr-str and prn print them; otherwise they are printed raw, as str and
+// println do.
+func PrintStr(ast MalType, readably bool) string {
+	return ast.Print(readably)
+}
+
+// PrintList renders each of items with PrintStr and joins them with sep.
+func PrintList(items []MalType, readably bool, sep string) string {
+	s := make([]string, 0, len(items))
+	for _, v := range items {
+		s = append(s, PrintStr(v, readably))
+	}
+	return strings.Join(s, sep)
 }
@@ -0,0 +1,50 @@
+package printer
+
+import (
+	"testing"
+
+	. "github.com/jdugan1024/jdgo/types"
+)
+
+// BenchmarkPrintStr prints, readably, a map holding the kinds of values
+// printing has to take care over: strings to escape, keywords and nested
+// collections.
+func BenchmarkPrintStr(b *testing.B) {
+	v := NewHashMap([]MalType{
+		NewKeyword("name"), NewString("a \"quoted\"\nline"),
+		NewKeyword("items"), NewVector(NewIntFromInt(1), NewFloat(2.5), NilValue, TrueValue, NewSymbol("sym")),
+		NewKeyword("nested"), NewList(NewList(NewString("x"), NewKeyword("y")), NewSet(NewIntFromInt(3))),
+	})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		PrintStr(v, true)
+	}
+}
+
+func TestPrintStr(t *testing.T) {
+	for _, c := range []struct {
+		v                 MalType
+		readable, display string
+	}{
+		{NewString(`a"b\c` + "\nd"), `"a\"b\\c\nd"`, "a\"b\\c\nd"},
+		{NewString(""), `""`, ""},
+		{NewKeyword("k"), ":k", ":k"},
+		{NewSymbol("s"), "s", "s"},
+		{NilValue, "nil", "nil"},
+		{TrueValue, "true", "true"},
+		{NewIntFromInt(-3), "-3", "-3"},
+		{NewList(NewString("x"), NewList()), `("x" ())`, "(x ())"},
+		{NewVector(NewString("y"), NewKeyword("z")), `["y" :z]`, "[y :z]"},
+		{NewHashMap([]MalType{NewString("k"), NewString("v")}), `{"k" "v"}`, "{k v}"},
+	} {
+		if got := PrintStr(c.v, true); got != c.readable {
+			t.Errorf("readably: got %s, want %s", got, c.readable)
+		}
+		if got := PrintStr(c.v, false); got != c.display {
+			t.Errorf("for display: got %s, want %s", got, c.display)
+		}
+	}
+	if got := PrintList([]MalType{NewString("a"), NewIntFromInt(1)}, true, " "); got != `"a" 1` {
+		t.Errorf("PrintList: got %s", got)
+	}
+}
@@ -2,6 +2,7 @@ package reader
 
 import (
 	"errors"
+	"fmt"
 	"regexp"
 	"strings"
 
@@ -65,6 +66,8 @@ func (r *Reader) ReadForm() (MalType, error) {
 		return nil, errors.New("unexpected }")
 	case "{":
 		return r.ReadHashMap()
+	case "#":
+		return r.ReadSet()
 	case "'":
 		r.Next()
 		form, err := r.ReadForm()
@@ -105,11 +108,44 @@ func (r *Reader) ReadForm() (MalType, error) {
 		}
 		l := NewList(NewSymbol("splice-unquote"), form)
 		return l, nil
+	case "^":
+		r.Next()
+		meta, err := r.ReadMeta()
+		if err != nil {
+			return nil, err
+		}
+		form, err := r.ReadForm()
+		if err != nil {
+			return nil, err
+		}
+		l := NewList(NewSymbol("with-meta"), form, meta)
+		return l, nil
 	default:
 		return r.ReadAtom()
 	}
 }
 
+// ReadMeta reads the metadata following a ^.  Besides a full map, ^:flag is
+// shorthand for {:flag true} and ^Type for {:tag Type}.
+func (r *Reader) ReadMeta() (MalType, error) {
+	form, err := r.ReadForm()
+	if err != nil {
+		return nil, err
+	}
+	switch m := form.(type) {
+	case *HashMap:
+		return m, nil
+	case *String:
+		if m.IsKeyword() {
+			return NewHashMap([]MalType{m, TrueValue}), nil
+		}
+		return NewHashMap([]MalType{NewKeyword("tag"), m}), nil
+	case *Symbol:
+		return NewHashMap([]MalType{NewKeyword("tag"), NewList(NewSymbol("quote"), m)}), nil
+	}
+	return nil, fmt.Errorf("metadata must be a map, keyword, symbol or string: %s", form.Print(true))
+}
+
 func (r *Reader) ReadAtom() (MalType, error) {
 	t, err := r.Next()
 	if err != nil {
@@ -138,11 +174,11 @@ func (r *Reader) ReadAtom() (MalType, error) {
 
 	switch t {
 	case "true":
-		return NewBoolean(true), nil
+		return TrueValue, nil
 	case "false":
-		return NewBoolean(false), nil
+		return FalseValue, nil
 	case "nil":
-		return &Nil{}, nil
+		return NilValue, nil
 	}
 
 	return NewSymbol(t), nil
@@ -153,7 +189,7 @@ func (r *Reader) ReadList() (*List, error) {
 	if err != nil {
 		return nil, err
 	}
-	l := NewList()
+	forms := []MalType{}
 
 	for {
 		t, err := r.Peek()
@@ -170,10 +206,10 @@ func (r *Reader) ReadList() (*List, error) {
 		if err != nil {
 			return nil, err
 		}
-		l.Append(form)
+		forms = append(forms, form)
 	}
 
-	return l, nil
+	return NewList(forms...), nil
 }
 
 func (r *Reader) ReadVector() (*Vector, error) {
@@ -181,7 +217,7 @@ func (r *Reader) ReadVector() (*Vector, error) {
 	if err != nil {
 		return nil, err
 	}
-	v := NewVector()
+	forms := []MalType{}
 
 	for {
 		t, err := r.Peek()
@@ -202,10 +238,10 @@ func (r *Reader) ReadVector() (*Vector, error) {
 		if err != nil {
 			return nil, err
 		}
-		v.Append(form)
+		forms = append(forms, form)
 	}
 
-	return v, nil
+	return NewVector(forms...), nil
 }
 
 func (r *Reader) ReadHashMap() (*HashMap, error) {
@@ -246,11 +282,59 @@ func (r *Reader) ReadHashMap() (*HashMap, error) {
 	return NewHashMap(forms), nil
 }
 
+// ReadSet reads a set literal, #{...}.
+func (r *Reader) ReadSet() (*Set, error) {
+	_, err := r.Next()
+	if err != nil {
+		return nil, err
+	}
+	t, err := r.Peek()
+	if err != nil {
+		return nil, err
+	}
+	if t != "{" {
+		return nil, fmt.Errorf("unexpected # before %s", t)
+	}
+	_, err = r.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	forms := []MalType{}
+
+	for {
+		t, err := r.Peek()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if t == "}" {
+			_, err := r.Next()
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+
+		form, err := r.ReadForm()
+		if err != nil {
+			return nil, err
+		}
+		forms = append(forms, form)
+	}
+
+	return NewSet(forms...), nil
+}
+
 func Tokenize(input string) []string {
 	rawTokens := tokenRegexp.FindAllStringSubmatch(input, -1)
 	tokens := []string{}
 
 	for _, t := range rawTokens {
+		if t[1] == "" || strings.HasPrefix(t[1], ";") {
+			continue
+		}
 		tokens = append(tokens, t[1])
 	}
 
@@ -0,0 +1,133 @@
+package reader
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/jdugan1024/jdgo/types"
+)
+
+var sink MalType
+
+// BenchmarkReadConstants reads a form made only of nil, true and false,
+// which should cost no more than the list holding them.
+func BenchmarkReadConstants(b *testing.B) {
+	tokens := Tokenize("[nil true false nil true false nil true false]")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		v, err := NewReader(tokens).ReadForm()
+		if err != nil {
+			b.Fatal(err)
+		}
+		sink = v
+	}
+}
+
+func TestReadConstantsAreCanonical(t *testing.T) {
+	for input, want := range map[string]MalType{"nil": NilValue, "true": TrueValue, "false": FalseValue} {
+		v, err := NewReader(Tokenize(input)).ReadForm()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != want {
+			t.Errorf("reading %s gave a new value rather than the canonical one", input)
+		}
+	}
+}
+
+// benchSource is a file of the mal library, for the benchmarks to read.
+func benchSource(b *testing.B) string {
+	src, err := os.ReadFile("../../lib/perf.mal")
+	if err != nil {
+		b.Fatal(err)
+	}
+	return string(src)
+}
+
+func BenchmarkTokenize(b *testing.B) {
+	src := benchSource(b)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(src)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Tokenize(src)
+	}
+}
+
+// BenchmarkReadForm reads every form of a tokenized file.
+func BenchmarkReadForm(b *testing.B) {
+	tokens := Tokenize(benchSource(b))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := NewReader(tokens)
+		for {
+			if _, err := r.Peek(); err != nil {
+				break
+			}
+			v, err := r.ReadForm()
+			if err != nil {
+				b.Fatal(err)
+			}
+			sink = v
+		}
+	}
+}
+
+func TestReadSet(t *testing.T) {
+	for _, c := range []struct {
+		input string
+		want  string
+		err   bool
+	}{
+		{input: "#{}", want: "#{}"},
+		{input: "#{1 :a \"b\"}", want: `#{1 :a "b"}`},
+		{input: "#{1 2 1}", want: "#{1 2}"},
+		{input: "#{[1 2] (1 2)}", want: "#{[1 2]}"},
+		{input: "#{#{1} {:a 1}}", want: "#{#{1} {:a 1}}"},
+		{input: "#(1)", err: true},
+		{input: "#{1 2", err: true},
+	} {
+		v, err := NewReader(Tokenize(c.input)).ReadForm()
+		if c.err {
+			if err == nil {
+				t.Errorf("%s: read %s, want an error", c.input, v.Print(true))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.input, err)
+			continue
+		}
+		if _, ok := v.(*Set); !ok {
+			t.Errorf("%s: read a %s, want a Set", c.input, v.TypeName())
+		}
+		if got := v.Print(true); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.input, got, c.want)
+		}
+	}
+}
+
+// TestReadMeta checks that ^ reads as with-meta, with the shorthands for
+// the metadata expanded.
+func TestReadMeta(t *testing.T) {
+	for _, c := range []struct{ input, want string }{
+		{`^{:a 1} [1]`, `(with-meta [1] {:a 1})`},
+		{`^:dynamic *d*`, `(with-meta *d* {:dynamic true})`},
+		{`^String x`, `(with-meta x {:tag (quote String)})`},
+		{`^"s" x`, `(with-meta x {:tag "s"})`},
+		{`^:a ^:b x`, `(with-meta (with-meta x {:b true}) {:a true})`},
+	} {
+		v, err := NewReader(Tokenize(c.input)).ReadForm()
+		if err != nil {
+			t.Errorf("%s: %v", c.input, err)
+		} else if got := v.Print(true); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.input, got, c.want)
+		}
+	}
+	for _, input := range []string{`^1 x`, `^:a`} {
+		if v, err := NewReader(Tokenize(input)).ReadForm(); err == nil {
+			t.Errorf("%s: read %s, want an error", input, v.Print(true))
+		}
+	}
+}
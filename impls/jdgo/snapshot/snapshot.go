@@ -0,0 +1,578 @@
+// Package snapshot saves the state of an interpreter to an image, a file
+// that another run of the interpreter can load to get the same definitions
+// without evaluating the code that made them.
+//
+// An image holds every namespace but the core one, which the interpreter
+// makes itself: the definitions of each, including functions and macros,
+// and its aliases and refers, as well as the names of the namespaces loaded
+// by require.  Builtins are saved by name, and loading an image fails if
+// the interpreter loading it does not have one of them.  Closures are saved
+// as the forms they were made from and the local variables they captured,
+// and are compiled again when the image is loaded.  Protocols, multimethods
+// and lazy sequences cannot be saved, and the definitions that hold them
+// are left out.
+package snapshot
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/jdugan1024/jdgo/core"
+	. "github.com/jdugan1024/jdgo/types"
+)
+
+// Version is the version of the image format.  It changes whenever the
+// format does, and Load only loads images of the same version.
+const Version = 1
+
+const magic = "jdgo image"
+
+// Maker makes a closure from the parameters and body of a fn*, whose
+// globals are looked up in env and which captured the local variables
+// names.  Their values, which may refer to the closure itself, are set
+// afterwards with set, by their position in names.
+type Maker func(params, body MalType, env *Env, names []*Symbol) (c *Closure, set func(i int, v MalType), err error)
+
+// image is what an image file holds.  The values of the namespaces refer
+// to Values by their positions in it, so that a value used in several
+// places, or that refers to itself, is saved once.
+type image struct {
+	Magic      string
+	Version    int
+	Values     []value
+	Namespaces []namespace
+	Loaded     []string
+}
+
+type kind byte
+
+const (
+	kindNil kind = iota
+	kindTrue
+	kindFalse
+	kindInt
+	kindFloat
+	kindString
+	kindKeyword
+	kindSymbol
+	kindList
+	kindVector
+	kindHashMap
+	kindSet
+	kindAtom
+	kindBuiltin
+	kindClosure
+	kindNamespace
+	kindBuiltinType
+	kindRecordType
+	kindRecord
+	kindDeftype
+	kindInstance
+)
+
+// value is a saved value.  What its fields hold depends on its kind:
+//
+//	kindInt, kindFloat          Int or Float
+//	kindString, kindKeyword,
+//	kindSymbol, kindBuiltin,
+//	kindNamespace,
+//	kindBuiltinType             Str, the name
+//	kindList, kindVector,
+//	kindSet                     Refs, the items
+//	kindHashMap                 Refs, the keys and values in turn
+//	kindAtom                    Refs, the value
+//	kindClosure                 Str, the namespace its globals are in;
+//	                            Refs, the parameters, the body and the
+//	                            values of the captured variables Names;
+//	                            Macro
+//	kindRecordType,
+//	kindDeftype                 Str, the namespace; Names, the name and
+//	                            then the fields
+//	kindRecord                  Refs, the type and a map of the entries
+//	kindInstance                Refs, the type and a vector of the field
+//	                            values
+//
+// Meta is the position of the metadata plus one, or 0 if there is none.
+type value struct {
+	Kind  kind
+	Int   int64
+	Float float64
+	Str   string
+	Names []string
+	Refs  []int
+	Meta  int
+	Macro bool
+}
+
+type namespace struct {
+	Name    string
+	Defs    []def
+	Aliases map[string]string
+	Refers  map[string]string
+}
+
+type def struct {
+	Name    string
+	Value   int
+	Private bool
+	Dynamic bool
+}
+
+// Save writes an image of the interpreter's namespaces to w.  A definition
+// whose value cannot be saved is left out of the image, along with the
+// refers to it, and a line saying why is written to warnings.
+func Save(w, warnings io.Writer) error {
+	e := &encoder{img: &image{Magic: magic, Version: Version}, ids: map[MalType]int{}, skipped: map[string]bool{}}
+	for _, ns := range AllNamespaces() {
+		if ns == CoreNamespace() {
+			continue
+		}
+		for _, err := range e.namespace(ns) {
+			fmt.Fprintln(warnings, err)
+		}
+	}
+	for _, n := range e.img.Namespaces {
+		for sym, from := range n.Refers {
+			if e.skipped[from+"/"+sym] {
+				delete(n.Refers, sym)
+			}
+		}
+	}
+	if core.ModuleLoader != nil {
+		e.img.Loaded = core.ModuleLoader.Loaded()
+	}
+	return gob.NewEncoder(w).Encode(e.img)
+}
+
+type encoder struct {
+	img *image
+	ids map[MalType]int
+	// skipped holds the qualified names of the definitions left out.
+	skipped map[string]bool
+}
+
+// namespace saves ns, and returns an error for each definition left out.
+func (e *encoder) namespace(ns *Namespace) []error {
+	var errs []error
+	n := namespace{Name: ns.Name(), Aliases: map[string]string{}, Refers: map[string]string{}}
+	defs := ns.Definitions()
+	syms := make([]*Symbol, 0, len(defs))
+	for sym := range defs {
+		syms = append(syms, sym)
+	}
+	sort.Slice(syms, func(i, j int) bool { return syms[i].Print(true) < syms[j].Print(true) })
+	for _, sym := range syms {
+		d := def{Name: sym.Print(true), Private: ns.IsPrivate(sym)}
+		v := defs[sym]
+		if dv, ok := v.(*Var); ok {
+			d.Dynamic = true
+			v = dv.Root()
+		}
+		mark := len(e.img.Values)
+		var err error
+		if d.Value, err = e.value(v); err != nil {
+			e.forget(mark)
+			e.skipped[ns.Name()+"/"+d.Name] = true
+			errs = append(errs, fmt.Errorf("not saving %s/%s: %s", ns.Name(), d.Name, err))
+			continue
+		}
+		n.Defs = append(n.Defs, d)
+	}
+	for sym, target := range ns.Aliases() {
+		n.Aliases[sym.Print(true)] = target.Name()
+	}
+	for sym, from := range ns.Refers() {
+		n.Refers[sym.Print(true)] = from.Name()
+	}
+	e.img.Namespaces = append(e.img.Namespaces, n)
+	return errs
+}
+
+// forget drops the values saved from position mark on, for a definition
+// that turned out not to be savable.
+func (e *encoder) forget(mark int) {
+	e.img.Values = e.img.Values[:mark]
+	for v, id := range e.ids {
+		if id >= mark {
+			delete(e.ids, v)
+		}
+	}
+}
+
+func (e *encoder) values(vs []MalType) ([]int, error) {
+	r := make([]int, len(vs))
+	for i, v := range vs {
+		id, err := e.value(v)
+		if err != nil {
+			return nil, err
+		}
+		r[i] = id
+	}
+	return r, nil
+}
+
+// value saves v, unless it is saved already, and returns its position.
+func (e *encoder) value(v MalType) (int, error) {
+	if id, ok := e.ids[v]; ok {
+		return id, nil
+	}
+	// The position is taken before the parts of v are saved, so that they
+	// can refer to v.
+	id := len(e.img.Values)
+	e.img.Values = append(e.img.Values, value{})
+	e.ids[v] = id
+
+	var r value
+	var err error
+	switch t := v.(type) {
+	case *Nil:
+		r.Kind = kindNil
+	case *Boolean:
+		r.Kind = kindFalse
+		if t == TrueValue {
+			r.Kind = kindTrue
+		}
+	case *Int:
+		r.Kind, r.Int = kindInt, int64(t.AsInt())
+	case *Float:
+		r.Kind, r.Float = kindFloat, t.Value()
+	case *String:
+		r.Kind, r.Str = kindString, t.Value()
+		if t.IsKeyword() {
+			r.Kind = kindKeyword
+		}
+	case *Symbol:
+		r.Kind, r.Str = kindSymbol, t.Print(true)
+	case *List:
+		r.Kind = kindList
+		r.Refs, err = e.values(t.Items())
+	case *Vector:
+		r.Kind = kindVector
+		r.Refs, err = e.values(t.Items())
+	case *HashMap:
+		r.Kind = kindHashMap
+		keys, vals := t.Keys(), t.Vals()
+		forms := make([]MalType, 0, 2*len(keys))
+		for i := range keys {
+			forms = append(forms, keys[i], vals[i])
+		}
+		r.Refs, err = e.values(forms)
+	case *Set:
+		r.Kind = kindSet
+		r.Refs, err = e.values(t.Items())
+	case *Atom:
+		r.Kind = kindAtom
+		r.Refs, err = e.values([]MalType{t.Deref()})
+	case *Function:
+		// Only builtins can be saved, since a Function made at run time,
+		// by comp for example, cannot be found again by its name.
+		if b, ok := CoreNamespace().Env().Lookup(NewSymbol(t.Name())); !ok || b != t.Base() {
+			return 0, fmt.Errorf("cannot save %s, which is not a builtin", t.Name())
+		}
+		r.Kind, r.Str = kindBuiltin, t.Name()
+	case *Closure:
+		src, ok := t.Source()
+		if !ok || src.Env == nil || src.Env.Namespace() == nil {
+			return 0, errors.New("cannot save a function that was not defined in a namespace")
+		}
+		r.Kind, r.Str, r.Macro = kindClosure, src.Env.Namespace().Name(), t.IsMacro()
+		for _, n := range src.Names {
+			r.Names = append(r.Names, n.Print(true))
+		}
+		r.Refs, err = e.values(append([]MalType{src.Params, src.Body}, src.Values...))
+	case *Namespace:
+		r.Kind, r.Str = kindNamespace, t.Name()
+	case *BuiltinType:
+		r.Kind, r.Str = kindBuiltinType, t.Print(true)
+	case *RecordType:
+		r.Kind, r.Str, r.Names = kindRecordType, t.Namespace(), []string{t.Name()}
+		if t.IsDeftype() {
+			r.Kind = kindDeftype
+		}
+		for _, f := range t.Fields() {
+			r.Names = append(r.Names, f.Value())
+		}
+	case *Record:
+		r.Kind = kindRecord
+		r.Refs, err = e.values([]MalType{t.Type(), t.Map()})
+	case *Instance:
+		r.Kind = kindInstance
+		r.Refs, err = e.values([]MalType{t.Type(), NewVector(t.Values()...)})
+	case *Protocol:
+		return 0, fmt.Errorf("cannot save protocol %s", t.Name())
+	case *MultiFn:
+		return 0, errors.New("cannot save a multimethod")
+	case *LazySeq:
+		return 0, errors.New("cannot save a lazy sequence, which may be infinite")
+	default:
+		return 0, fmt.Errorf("cannot save a value of type %s", v.TypeName())
+	}
+	if err != nil {
+		return 0, err
+	}
+	if m, ok := v.(Metadatable); ok && m.Meta() != NilValue {
+		meta, err := e.value(m.Meta())
+		if err != nil {
+			return 0, err
+		}
+		r.Meta = meta + 1
+	}
+	e.img.Values[id] = r
+	return id, nil
+}
+
+// Load reads an image from r and restores its namespaces, making its
+// closures with makeClosure.  Definitions in the image replace those of the
+// same names.
+func Load(r io.Reader, makeClosure Maker) error {
+	var img image
+	if err := gob.NewDecoder(r).Decode(&img); err != nil || img.Magic != magic {
+		return errors.New("not a jdgo image")
+	}
+	if img.Version != Version {
+		return fmt.Errorf("image has version %d, but this interpreter loads version %d", img.Version, Version)
+	}
+	if missing := missingBuiltins(&img); len(missing) > 0 {
+		return fmt.Errorf("image refers to builtins that this interpreter does not have: %v", missing)
+	}
+
+	d := &decoder{img: &img, vals: make([]MalType, len(img.Values)), busy: make([]bool, len(img.Values)), makeClosure: makeClosure}
+	for _, n := range img.Namespaces {
+		ns := CreateNamespace(n.Name)
+		for _, def := range n.Defs {
+			v, err := d.value(def.Value)
+			if err != nil {
+				return fmt.Errorf("loading %s/%s: %s", n.Name, def.Name, err)
+			}
+			sym := NewSymbol(def.Name)
+			ns.Env().Define(sym, v, def.Dynamic)
+			if def.Private {
+				ns.MarkPrivate(sym)
+			}
+		}
+	}
+	// Referring to a definition needs it to exist, so refers are restored
+	// once every namespace is.
+	for _, n := range img.Namespaces {
+		ns := FindNamespace(n.Name)
+		for alias, target := range n.Aliases {
+			ns.Alias(NewSymbol(alias), CreateNamespace(target))
+		}
+		for sym, from := range n.Refers {
+			if err := ns.Refer(NewSymbol(sym), CreateNamespace(from)); err != nil {
+				return err
+			}
+		}
+	}
+	if core.ModuleLoader != nil {
+		for _, name := range img.Loaded {
+			core.ModuleLoader.MarkLoaded(name)
+		}
+	}
+	return nil
+}
+
+// missingBuiltins returns the names of the builtins img refers to that the
+// core namespace does not define.
+func missingBuiltins(img *image) []string {
+	var r []string
+	for _, v := range img.Values {
+		if v.Kind != kindBuiltin {
+			continue
+		}
+		if f, ok := CoreNamespace().Env().Lookup(NewSymbol(v.Str)); !ok || f.TypeName() != "Function" {
+			r = append(r, v.Str)
+		}
+	}
+	return r
+}
+
+type decoder struct {
+	img  *image
+	vals []MalType
+	// busy marks the values being restored, to catch cycles that cannot
+	// be restored.
+	busy        []bool
+	makeClosure Maker
+}
+
+func (d *decoder) values(ids []int) ([]MalType, error) {
+	r := make([]MalType, len(ids))
+	for i, id := range ids {
+		v, err := d.value(id)
+		if err != nil {
+			return nil, err
+		}
+		r[i] = v
+	}
+	return r, nil
+}
+
+// value restores the value at position id.
+func (d *decoder) value(id int) (MalType, error) {
+	if id < 0 || id >= len(d.vals) {
+		return nil, errors.New("corrupt image")
+	}
+	if d.vals[id] != nil {
+		return d.vals[id], nil
+	}
+	if d.busy[id] {
+		return nil, errors.New("image holds a value that refers to itself other than through an atom or a closure")
+	}
+	d.busy[id] = true
+	v := d.img.Values[id]
+
+	var meta MalType = NilValue
+	if v.Meta > 0 {
+		var err error
+		if meta, err = d.value(v.Meta - 1); err != nil {
+			return nil, err
+		}
+	}
+	withMeta := func(r MalType) MalType {
+		if m, ok := r.(Metadatable); ok && meta != NilValue {
+			return m.WithMeta(meta)
+		}
+		return r
+	}
+
+	var r MalType
+	switch v.Kind {
+	case kindNil:
+		r = NilValue
+	case kindTrue:
+		r = TrueValue
+	case kindFalse:
+		r = FalseValue
+	case kindInt:
+		r = NewIntFromInt(int(v.Int))
+	case kindFloat:
+		r = NewFloat(v.Float)
+	case kindString:
+		r = NewString(v.Str)
+	case kindKeyword:
+		r = NewKeyword(v.Str)
+	case kindSymbol:
+		r = NewSymbol(v.Str)
+	case kindList, kindVector, kindSet, kindHashMap:
+		items, err := d.values(v.Refs)
+		if err != nil {
+			return nil, err
+		}
+		switch v.Kind {
+		case kindList:
+			r = NewList(items...)
+		case kindVector:
+			r = NewVector(items...)
+		case kindSet:
+			r = NewSet(items...)
+		default:
+			r = NewHashMap(items)
+		}
+		r = withMeta(r)
+	case kindAtom:
+		if len(v.Refs) != 1 {
+			return nil, errors.New("corrupt image")
+		}
+		a := NewAtom(NilValue)
+		d.vals[id] = a
+		x, err := d.value(v.Refs[0])
+		if err != nil {
+			return nil, err
+		}
+		a.Reset(x)
+		return a, nil
+	case kindBuiltin:
+		f, _ := CoreNamespace().Env().Lookup(NewSymbol(v.Str))
+		r = withMeta(f)
+	case kindClosure:
+		return d.closure(id, v, withMeta)
+	case kindNamespace:
+		r = CreateNamespace(v.Str)
+	case kindBuiltinType:
+		r = NewBuiltinType(v.Str)
+	case kindRecordType, kindDeftype:
+		if len(v.Names) == 0 {
+			return nil, errors.New("corrupt image")
+		}
+		fields := make([]*String, len(v.Names)-1)
+		for i, f := range v.Names[1:] {
+			fields[i] = NewKeyword(f)
+		}
+		if v.Kind == kindDeftype {
+			r = NewDeftype(v.Str, v.Names[0], fields)
+		} else {
+			r = NewRecordType(v.Str, v.Names[0], fields)
+		}
+	case kindRecord:
+		parts, err := d.values(v.Refs)
+		if err != nil {
+			return nil, err
+		}
+		if len(parts) != 2 {
+			return nil, errors.New("corrupt image")
+		}
+		rt, ok1 := parts[0].(*RecordType)
+		m, ok2 := parts[1].(*HashMap)
+		if !ok1 || !ok2 {
+			return nil, errors.New("corrupt image")
+		}
+		r = withMeta(NewRecordFromMap(rt, m))
+	case kindInstance:
+		parts, err := d.values(v.Refs)
+		if err != nil {
+			return nil, err
+		}
+		if len(parts) != 2 {
+			return nil, errors.New("corrupt image")
+		}
+		rt, ok1 := parts[0].(*RecordType)
+		vals, ok2 := parts[1].(*Vector)
+		if !ok1 || !ok2 || !rt.IsDeftype() {
+			return nil, errors.New("corrupt image")
+		}
+		if r, err = NewInstance(rt, vals.Items()...); err != nil {
+			return nil, errors.New("corrupt image")
+		}
+	default:
+		return nil, fmt.Errorf("image holds a value of unknown kind %d", v.Kind)
+	}
+	d.vals[id] = r
+	return r, nil
+}
+
+// closure restores the closure v, at position id.  The closure is recorded
+// before the values of its captured variables are restored, since they may
+// refer to it.
+func (d *decoder) closure(id int, v value, withMeta func(MalType) MalType) (MalType, error) {
+	if len(v.Refs) != 2+len(v.Names) {
+		return nil, errors.New("corrupt image")
+	}
+	code, err := d.values(v.Refs[:2])
+	if err != nil {
+		return nil, err
+	}
+	names := make([]*Symbol, len(v.Names))
+	for i, n := range v.Names {
+		names[i] = NewSymbol(n)
+	}
+	c, set, err := d.makeClosure(code[0], code[1], CreateNamespace(v.Str).Env(), names)
+	if err != nil {
+		return nil, err
+	}
+	if v.Macro {
+		c = c.AsMacro()
+	}
+	d.vals[id] = withMeta(c)
+	for i, ref := range v.Refs[2:] {
+		x, err := d.value(ref)
+		if err != nil {
+			return nil, err
+		}
+		set(i, x)
+	}
+	return d.vals[id], nil
+}
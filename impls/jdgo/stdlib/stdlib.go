@@ -0,0 +1,17 @@
+// Package stdlib holds the standard library of mal namespaces that is built
+// into the jdgo binary, so that scripts can require them wherever they are
+// run from.  The files are curated copies of the ones in the repository's
+// lib directory, each declaring its namespace with ns.
+//
+// Files on the load path take precedence over the built in ones, so running
+// with JDGO_PATH=impls/jdgo/stdlib picks up edits to this directory without
+// rebuilding.
+package stdlib
+
+import "embed"
+
+// FS holds the library, laid out as the loader expects: the namespace
+// mal.threading is defined in mal/threading.mal.
+//
+//go:embed mal
+var FS embed.FS
@@ -19,7 +19,12 @@ func READ(input string) (MalType, error) {
 	}
 	return ast, nil
 }
-func EVAL(ast MalType, env Env) (MalType, error) {
+
+// symbolTable maps the names of the builtins to their values.  Step 2 has
+// no def!, so its environment is a plain map rather than an Env.
+type symbolTable map[string]MalType
+
+func EVAL(ast MalType, env symbolTable) (MalType, error) {
 	switch ast.(type) {
 	case *List:
 	default:
@@ -35,10 +40,10 @@ func EVAL(ast MalType, env Env) (MalType, error) {
 	return applyList(l, env)
 }
 func PRINT(ast MalType) string {
-	return PrintStr(ast)
+	return PrintStr(ast, true)
 }
 
-func applyList(l *List, env Env) (MalType, error) {
+func applyList(l *List, env symbolTable) (MalType, error) {
 	e, err := eval_ast(l, env)
 	if err != nil {
 		return nil, err
@@ -53,24 +58,20 @@ func applyList(l *List, env Env) (MalType, error) {
 	}
 	f, ok := l0.(*Function)
 	if !ok {
-		return nil, fmt.Errorf("%s is not a function", l0.Print())
-	}
-	args, err := el.Rest()
-	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%s is not a function", l0.Print(true))
 	}
-	return f.Eval(args...)
+	return f.Eval(nil, el.Items()[1:]...)
 }
 
-var replEnv = Env{
+var replEnv = symbolTable{
 	"+": NewFunction("+", func(args ...MalType) (MalType, error) {
 		a, ok := args[0].(*Int)
 		if !ok {
-			return nil, fmt.Errorf("argument is not an Int: %s", args[0].Print())
+			return nil, fmt.Errorf("argument is not an Int: %s", args[0].Print(true))
 		}
 		b, ok := args[1].(*Int)
 		if !ok {
-			return nil, fmt.Errorf("argument is not an Int: %s", args[1].Print())
+			return nil, fmt.Errorf("argument is not an Int: %s", args[1].Print(true))
 		}
 
 		return NewIntFromInt(a.AsInt() + b.AsInt()), nil
@@ -90,11 +91,11 @@ var replEnv = Env{
 	"*": NewFunction("*", func(args ...MalType) (MalType, error) {
 		a, ok := args[0].(*Int)
 		if !ok {
-			return nil, fmt.Errorf("argument is not an Int: %s", args[0].Print())
+			return nil, fmt.Errorf("argument is not an Int: %s", args[0].Print(true))
 		}
 		b, ok := args[1].(*Int)
 		if !ok {
-			return nil, fmt.Errorf("argument is not an Int: %s", args[1].Print())
+			return nil, fmt.Errorf("argument is not an Int: %s", args[1].Print(true))
 		}
 
 		return NewIntFromInt(a.AsInt() * b.AsInt()), nil
@@ -125,39 +126,57 @@ func rep(input string) (string, error) {
 	return PRINT(ev), nil
 }
 
-func eval_ast(ast MalType, env Env) (MalType, error) {
+func eval_ast(ast MalType, env symbolTable) (MalType, error) {
 	switch v := ast.(type) {
 	case *Symbol:
-		name := v.Print()
+		name := v.Print(true)
 		r, ok := env[name]
 		if !ok {
 			return nil, fmt.Errorf("unknown symbol: %s", name)
 		}
 		return r, nil
 	case *List:
-		r, err := v.Map(EVAL, env)
+		items, err := evalAll(v.Items(), env)
 		if err != nil {
 			return nil, err
 		}
-		return r, nil
+		return NewList(items...), nil
 	case *Vector:
-		r, err := v.Map(EVAL, env)
+		items, err := evalAll(v.Items(), env)
 		if err != nil {
 			return nil, err
 		}
-		return r, nil
+		return NewVector(items...), nil
 	case *HashMap:
-		fmt.Printf(">>> %s\n", v.Print())
-		r, err := v.Map(EVAL, env)
+		keys := v.Keys()
+		vals, err := evalAll(v.Vals(), env)
 		if err != nil {
 			return nil, err
 		}
-		return r, nil
+		forms := make([]MalType, 0, 2*len(keys))
+		for i, k := range keys {
+			forms = append(forms, k, vals[i])
+		}
+		return NewHashMap(forms), nil
 	default:
 		return ast, nil
 	}
 
 }
+
+// evalAll evaluates each of forms in env.
+func evalAll(forms []MalType, env symbolTable) ([]MalType, error) {
+	r := make([]MalType, len(forms))
+	for i, form := range forms {
+		v, err := EVAL(form, env)
+		if err != nil {
+			return nil, err
+		}
+		r[i] = v
+	}
+	return r, nil
+}
+
 func main() {
 	rl, err := readline.New("user> ")
 	if err != nil {
@@ -35,7 +35,7 @@ func EVAL(ast MalType, env *Env) (MalType, error) {
 	return apply(l, env)
 }
 func PRINT(ast MalType) string {
-	return PrintStr(ast)
+	return PrintStr(ast, true)
 }
 
 func apply(l *List, env *Env) (MalType, error) {
@@ -45,38 +45,40 @@ func apply(l *List, env *Env) (MalType, error) {
 	}
 	symbol, ok := head.(*Symbol)
 	if ok {
-		value := symbol.Print()
+		value := symbol.Print(true)
 		switch value {
 		case "def!":
-			rest, err := l.Rest()
-			if err != nil {
+			rest := l.Items()[1:]
+			if len(rest) < 2 {
 				return nil, errors.New("missing args for def!")
 			}
 			key, ok := rest[0].(*Symbol)
 			if !ok {
-				return nil, fmt.Errorf("env key is not a symbol: %s", rest[0].Print())
+				return nil, fmt.Errorf("env key is not a symbol: %s", rest[0].Print(true))
 			}
 			value, err := EVAL(rest[1], env)
 			if err != nil {
-				return nil, fmt.Errorf("unable to eval arg for def! %s %s", key.Print(), rest[1].Print())
+				return nil, fmt.Errorf("unable to eval arg for def! %s %s", key.Print(true), rest[1].Print(true))
 			}
 			env.Set(key, value)
 			return value, nil
 		case "let*":
-			rest, err := l.Rest()
-			if err != nil {
-				return nil, err
+			rest := l.Items()[1:]
+			if len(rest) < 2 {
+				return nil, errors.New("missing args for let*")
 			}
 
 			bindingsObj := rest[0]
 			switch bindings := bindingsObj.(type) {
 			case *List:
 				if bindings.Length()%2 != 0 {
-					return nil, fmt.Errorf("let* bindings has an odd number of entries: %s", bindings.Print())
+					return nil, fmt.Errorf("let* bindings has an odd number of entries: %s", bindings.Print(true))
 				}
 
 				newEnv := NewEnv(env)
-				bindings.BindEnv(newEnv, EVAL)
+				if err := BindEnv(bindings, newEnv, EVAL); err != nil {
+					return nil, err
+				}
 
 				r, err := EVAL(rest[1], newEnv)
 
@@ -86,11 +88,13 @@ func apply(l *List, env *Env) (MalType, error) {
 				return r, nil
 			case *Vector:
 				if bindings.Length()%2 != 0 {
-					return nil, fmt.Errorf("let* bindings has an odd number of entries: %s", bindings.Print())
+					return nil, fmt.Errorf("let* bindings has an odd number of entries: %s", bindings.Print(true))
 				}
 
 				newEnv := NewEnv(env)
-				bindings.BindEnv(newEnv, EVAL)
+				if err := BindEnv(bindings, newEnv, EVAL); err != nil {
+					return nil, err
+				}
 
 				r, err := EVAL(rest[1], newEnv)
 
@@ -99,7 +103,7 @@ func apply(l *List, env *Env) (MalType, error) {
 				}
 				return r, nil
 			default:
-				return nil, fmt.Errorf("bindings is not a list or a vector: %s", bindingsObj.Print())
+				return nil, fmt.Errorf("bindings is not a list or a vector: %s", bindingsObj.Print(true))
 			}
 
 		}
@@ -118,13 +122,9 @@ func apply(l *List, env *Env) (MalType, error) {
 	}
 	f, ok := l0.(*Function)
 	if !ok {
-		return nil, fmt.Errorf("%s is not a function", l0.Print())
-	}
-	args, err := el.Rest()
-	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%s is not a function", l0.Print(true))
 	}
-	return f.Eval(args...)
+	return f.Eval(nil, el.Items()[1:]...)
 }
 
 var replEnv = NewEnv(nil)
@@ -178,11 +178,11 @@ func main() {
 	replEnv.Set(NewSymbol("+"), NewFunction("+", func(args ...MalType) (MalType, error) {
 		a, ok := args[0].(*Int)
 		if !ok {
-			return nil, fmt.Errorf("argument is not an Int: %s", args[0].Print())
+			return nil, fmt.Errorf("argument is not an Int: %s", args[0].Print(true))
 		}
 		b, ok := args[1].(*Int)
 		if !ok {
-			return nil, fmt.Errorf("argument is not an Int: %s", args[1].Print())
+			return nil, fmt.Errorf("argument is not an Int: %s", args[1].Print(true))
 		}
 
 		return NewIntFromInt(a.AsInt() + b.AsInt()), nil
@@ -203,11 +203,11 @@ func main() {
 	replEnv.Set(NewSymbol("*"), NewFunction("*", func(args ...MalType) (MalType, error) {
 		a, ok := args[0].(*Int)
 		if !ok {
-			return nil, fmt.Errorf("argument is not an Int: %s", args[0].Print())
+			return nil, fmt.Errorf("argument is not an Int: %s", args[0].Print(true))
 		}
 		b, ok := args[1].(*Int)
 		if !ok {
-			return nil, fmt.Errorf("argument is not an Int: %s", args[1].Print())
+			return nil, fmt.Errorf("argument is not an Int: %s", args[1].Print(true))
 		}
 
 		return NewIntFromInt(a.AsInt() * b.AsInt()), nil
@@ -227,10 +227,10 @@ func main() {
 	replEnv.Set(NewSymbol("print"), NewFunction("print", func(args ...MalType) (MalType, error) {
 		var s = ""
 		for _, v := range args {
-			s += v.Print()
+			s += v.Print(true)
 		}
 		fmt.Println(s)
-		return &Nil{}, nil
+		return NilValue, nil
 	}))
 	for {
 		input, err := rl.Readline()
@@ -0,0 +1,494 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	. "github.com/jdugan1024/jdgo/types"
+)
+
+// The bytecode compiler turns each form into code for the stack machine in
+// vm.go, the third way, after EVAL and the closure compiler, that evaluate
+// can evaluate forms.  Variables are found the way the closure compiler
+// finds them, and compiled functions are made and called in the same way,
+// but calls between compiled functions are made by the machine rather than
+// with Go calls, so that they do not grow the Go stack.
+//
+// Code is a sequence of bytes: each instruction is an opcode followed by
+// its operands, each a big endian uint16.  The operands of jumps are the
+// offsets of their targets in the code.
+
+type opcode byte
+
+const (
+	opConst       opcode = iota // k: push consts[k]
+	opLocal                     // slot: push a local variable
+	opUpval                     // depth slot: push a variable of an enclosing function
+	opSetLocal                  // slot: pop a local variable
+	opJumpIfSet                 // addr: jump if the top value is set, else pop it
+	opGlobal                    // k: push the global named consts[k]
+	opDef                       // k flags: define consts[k] as the top value
+	opDefMacro                  // k: pop a function and define consts[k] as a macro
+	opPop                       // pop
+	opJump                      // addr: jump
+	opJumpIfFalse               // addr: pop, and jump if the value is nil or false
+	opClosure                   // k: push a closure of protos[k]
+	opCall                      // n: pop a function and n arguments, and push its result
+	opTailCall                  // n: replace the current call with a call
+	opReturn                    // return the top value
+	opVector                    // n: pop n values and push a vector of them
+	opHashMap                   // n: pop n keys and values and push a map of them
+	opSet                       // n: pop n values and push a set of them
+	opMacroexpand               // k: push the expansion of consts[k]
+	opTry                       // addr: catch errors with the code at addr
+	opEndTry                    // stop catching errors with the last opTry
+	opBind                      // k: pop values for the dynamic vars consts[k] and bind them
+	opUnbind                    // undo the last opBind
+)
+
+// ops gives the name of each opcode and the number of operands it takes.
+var ops = [...]struct {
+	name     string
+	operands int
+}{
+	opConst:       {"const", 1},
+	opLocal:       {"local", 1},
+	opUpval:       {"upval", 2},
+	opSetLocal:    {"setlocal", 1},
+	opJumpIfSet:   {"jumpifset", 1},
+	opGlobal:      {"global", 1},
+	opDef:         {"def", 2},
+	opDefMacro:    {"defmacro", 1},
+	opPop:         {"pop", 0},
+	opJump:        {"jump", 1},
+	opJumpIfFalse: {"jumpiffalse", 1},
+	opClosure:     {"closure", 1},
+	opCall:        {"call", 1},
+	opTailCall:    {"tailcall", 1},
+	opReturn:      {"return", 0},
+	opVector:      {"vector", 1},
+	opHashMap:     {"hashmap", 1},
+	opSet:         {"set", 1},
+	opMacroexpand: {"macroexpand", 1},
+	opTry:         {"try", 1},
+	opEndTry:      {"endtry", 0},
+	opBind:        {"bind", 1},
+	opUnbind:      {"unbind", 0},
+}
+
+// The flags of opDef.
+const (
+	defDynamic = 1 << iota
+	defPrivate
+)
+
+// vmProto is a fn* compiled to bytecode, or a top level form, which is
+// compiled as the body of a function of no parameters.  The body of a fn*
+// is compiled the first time the function is called.
+type vmProto struct {
+	env    *Env
+	params []*Symbol
+	rest   *Symbol
+	form   MalType
+	scope  scope
+
+	once   sync.Once
+	err    error
+	code   []byte
+	consts []MalType
+	protos []*vmProto
+	nslots int
+}
+
+// compile compiles the body of p the first time it is called.
+func (p *vmProto) compile() error {
+	p.once.Do(func() {
+		sc := fnBodyScope(p.scope, p.params, p.rest)
+		p.err = p.assemble(p.form, sc)
+		p.nslots = sc.fn.nslots
+	})
+	return p.err
+}
+
+// assemble compiles form, in sc, as the body of p.
+func (p *vmProto) assemble(form MalType, sc scope) error {
+	if err := p.gen(form, sc, true); err != nil {
+		return err
+	}
+	p.emit(opReturn)
+	if len(p.code) > 0xffff || len(p.consts) > 0xffff || len(p.protos) > 0xffff {
+		return errors.New("function too large to compile")
+	}
+	return nil
+}
+
+// disassemble returns the code of p, one instruction a line: its offset,
+// the name of its opcode and its operands.
+func (p *vmProto) disassemble() string {
+	var b strings.Builder
+	for pc := 0; pc < len(p.code); {
+		op := opcode(p.code[pc])
+		if int(op) >= len(ops) {
+			fmt.Fprintf(&b, "%d bad opcode %d\n", pc, op)
+			pc++
+			continue
+		}
+		fmt.Fprintf(&b, "%d %s", pc, ops[op].name)
+		pc++
+		for i := 0; i < ops[op].operands && pc+1 < len(p.code); i++ {
+			fmt.Fprintf(&b, " %d", int(p.code[pc])<<8|int(p.code[pc+1]))
+			pc += 2
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func (p *vmProto) emit(op opcode, args ...int) {
+	p.code = append(p.code, byte(op))
+	for _, a := range args {
+		p.code = append(p.code, byte(a>>8), byte(a))
+	}
+}
+
+// emitJump emits a jump, whose target patch sets later, and returns its
+// offset.
+func (p *vmProto) emitJump(op opcode) int {
+	p.emit(op, 0)
+	return len(p.code) - 3
+}
+
+// patch makes the jump at offset at jump to the end of the code.
+func (p *vmProto) patch(at int) {
+	p.code[at+1], p.code[at+2] = byte(len(p.code)>>8), byte(len(p.code))
+}
+
+func (p *vmProto) constant(v MalType) int {
+	p.consts = append(p.consts, v)
+	return len(p.consts) - 1
+}
+
+// gen generates the code for ast.  With tail set, ast is in tail position in the
+// body of a function, and a call it makes replaces the current one.
+func (p *vmProto) gen(ast MalType, sc scope, tail bool) error {
+	switch v := ast.(type) {
+	case *Symbol:
+		p.symbol(v, sc.fn, sc.locals)
+		return nil
+	case *List:
+		return p.list(v, sc, tail)
+	case *Cons, *LazySeq:
+		l, err := listForm(v)
+		if err != nil {
+			return err
+		}
+		return p.list(l.(*List), sc, tail)
+	case *Vector:
+		if err := p.genAll(v.Items(), sc); err != nil {
+			return err
+		}
+		p.emit(opVector, v.Length())
+	case *HashMap:
+		keys, vals := v.Keys(), v.Vals()
+		for i := range keys {
+			if err := p.gen(keys[i], sc, false); err != nil {
+				return err
+			}
+			if err := p.gen(vals[i], sc, false); err != nil {
+				return err
+			}
+		}
+		p.emit(opHashMap, len(keys))
+	case *Set:
+		if err := p.genAll(v.Items(), sc); err != nil {
+			return err
+		}
+		p.emit(opSet, v.Length())
+	default:
+		p.emit(opConst, p.constant(ast))
+	}
+	return nil
+}
+
+func (p *vmProto) genAll(forms []MalType, sc scope) error {
+	for _, form := range forms {
+		if err := p.gen(form, sc, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// symbol compiles a reference to sym, as seen from the function fn with
+// the local variables locals.
+func (p *vmProto) symbol(sym *Symbol, fn *fnScope, locals *local) {
+	for l := locals; l != nil; l = l.next {
+		if l.sym != sym {
+			continue
+		}
+		depth := 0
+		for f := fn; f != l.fn; f = f.outer {
+			depth++
+		}
+		if depth == 0 {
+			p.emit(opLocal, l.slot)
+		} else {
+			p.emit(opUpval, depth, l.slot)
+		}
+		if l.maybeUnset {
+			set := p.emitJump(opJumpIfSet)
+			p.symbol(sym, fn, l.next)
+			p.patch(set)
+		}
+		return
+	}
+	p.emit(opGlobal, p.constant(sym))
+}
+
+func (p *vmProto) list(l *List, sc scope, tail bool) error {
+	expanded, err := (&compiler{p.env}).macroexpand(l, sc)
+	if err != nil {
+		return err
+	}
+	l, ok := expanded.(*List)
+	if !ok {
+		return p.gen(expanded, sc, tail)
+	}
+	if l.Length() == 0 {
+		p.emit(opConst, p.constant(l))
+		return nil
+	}
+
+	items := l.Items()
+	if symbol, ok := items[0].(*Symbol); ok {
+		switch symbol.Print(true) {
+		case "def!", "def-":
+			if len(items) != 3 {
+				return fmt.Errorf("%s expects a symbol and a value", symbol.Print(true))
+			}
+			key, dynamic, err := defName(items[1])
+			if err != nil {
+				return err
+			}
+			if err := p.gen(items[2], sc, false); err != nil {
+				return err
+			}
+			flags := 0
+			if dynamic {
+				flags |= defDynamic
+			}
+			if symbol.Print(true) == "def-" {
+				flags |= defPrivate
+			}
+			p.emit(opDef, p.constant(key), flags)
+			return nil
+		case "let*":
+			if len(items) != 3 {
+				return errors.New("let* expects bindings and a body")
+			}
+			return p.let(items, sc, tail)
+		case "do":
+			if len(items) == 1 {
+				p.emit(opConst, p.constant(NilValue))
+				return nil
+			}
+			for _, form := range items[1 : len(items)-1] {
+				if err := p.gen(form, sc, false); err != nil {
+					return err
+				}
+				p.emit(opPop)
+			}
+			return p.gen(items[len(items)-1], sc, tail)
+		case "if":
+			if len(items) != 3 && len(items) != 4 {
+				return errors.New("if expects a condition and one or two branches")
+			}
+			if err := p.gen(items[1], sc, false); err != nil {
+				return err
+			}
+			otherwise := p.emitJump(opJumpIfFalse)
+			if err := p.gen(items[2], sc, tail); err != nil {
+				return err
+			}
+			end := p.emitJump(opJump)
+			p.patch(otherwise)
+			if len(items) == 4 {
+				if err := p.gen(items[3], sc, tail); err != nil {
+					return err
+				}
+			} else {
+				p.emit(opConst, p.constant(NilValue))
+			}
+			p.patch(end)
+			return nil
+		case "fn*":
+			if len(items) != 3 {
+				return errors.New("fn* expects parameters and a body")
+			}
+			params, rest, err := parseParams(items[1])
+			if err != nil {
+				return err
+			}
+			p.protos = append(p.protos, &vmProto{env: p.env, params: params, rest: rest, form: items[2], scope: sc})
+			p.emit(opClosure, len(p.protos)-1)
+			return nil
+		case "quote":
+			if len(items) != 2 {
+				return errors.New("quote expects one argument")
+			}
+			p.emit(opConst, p.constant(items[1]))
+			return nil
+		case "quasiquoteexpand":
+			if len(items) != 2 {
+				return errors.New("quasiquoteexpand expects one argument")
+			}
+			p.emit(opConst, p.constant(quasiquote(items[1])))
+			return nil
+		case "quasiquote":
+			if len(items) != 2 {
+				return errors.New("quasiquote expects one argument")
+			}
+			return p.gen(quasiquote(items[1]), sc, tail)
+		case "defmacro!":
+			if len(items) != 3 {
+				return errors.New("defmacro! expects a symbol and a function")
+			}
+			key, ok := items[1].(*Symbol)
+			if !ok {
+				return fmt.Errorf("env key is not a symbol: %s", items[1].Print(true))
+			}
+			if err := p.gen(items[2], sc, false); err != nil {
+				return err
+			}
+			p.emit(opDefMacro, p.constant(key))
+			return nil
+		case "macroexpand":
+			if len(items) != 2 {
+				return errors.New("macroexpand expects one argument")
+			}
+			p.emit(opMacroexpand, p.constant(items[1]))
+			return nil
+		case "try*":
+			return p.try(items, sc)
+		case "binding":
+			return p.binding(items, sc)
+		}
+	}
+
+	if err := p.genAll(items, sc); err != nil {
+		return err
+	}
+	if tail {
+		p.emit(opTailCall, len(items)-1)
+	} else {
+		p.emit(opCall, len(items)-1)
+	}
+	return nil
+}
+
+func (p *vmProto) let(items []MalType, sc scope, tail bool) error {
+	switch items[1].(type) {
+	case *List, *Vector:
+	default:
+		return fmt.Errorf("bindings is not a list or a vector: %s", items[1].Print(true))
+	}
+	bindings, err := Sequence(items[1])
+	if err != nil {
+		return err
+	}
+	if len(bindings)%2 != 0 {
+		return fmt.Errorf("odd number of binding forms: %s", items[1].Print(true))
+	}
+	// As with the closure compiler, every variable is in scope for every
+	// value; see local.maybeUnset.
+	inner := sc
+	slots := make([]int, len(bindings)/2)
+	for i := 0; i < len(bindings); i += 2 {
+		sym, ok := bindings[i].(*Symbol)
+		if !ok {
+			return fmt.Errorf("attempting to bind to a non symbol: %s", bindings[i].Print(true))
+		}
+		inner, slots[i/2] = inner.bind(sym, true)
+	}
+	for i, slot := range slots {
+		if err := p.gen(bindings[2*i+1], inner, false); err != nil {
+			return err
+		}
+		p.emit(opSetLocal, slot)
+	}
+	return p.gen(items[2], inner, tail)
+}
+
+func (p *vmProto) try(items []MalType, sc scope) error {
+	if len(items) != 2 && len(items) != 3 {
+		return errors.New("try* expects a body and an optional catch* clause")
+	}
+	if len(items) == 2 {
+		return p.gen(items[1], sc, false)
+	}
+
+	clause, ok := items[2].(*List)
+	if !ok || clause.Length() != 3 {
+		return fmt.Errorf("malformed catch* clause: %s", items[2].Print(true))
+	}
+	catch := clause.Items()
+	if s, ok := catch[0].(*Symbol); !ok || s.Print(true) != "catch*" {
+		return fmt.Errorf("malformed catch* clause: %s", items[2].Print(true))
+	}
+	sym, ok := catch[1].(*Symbol)
+	if !ok {
+		return fmt.Errorf("catch* binding is not a symbol: %s", catch[1].Print(true))
+	}
+
+	handler := p.emitJump(opTry)
+	if err := p.gen(items[1], sc, false); err != nil {
+		return err
+	}
+	p.emit(opEndTry)
+	end := p.emitJump(opJump)
+	// The machine jumps here with the value thrown on the stack.
+	p.patch(handler)
+	inner, slot := sc.bind(sym, false)
+	p.emit(opSetLocal, slot)
+	if err := p.gen(catch[2], inner, false); err != nil {
+		return err
+	}
+	p.patch(end)
+	return nil
+}
+
+func (p *vmProto) binding(items []MalType, sc scope) error {
+	if len(items) < 2 {
+		return errors.New("binding expects bindings and a body")
+	}
+	bindings, err := Sequence(items[1])
+	if err != nil || len(bindings)%2 != 0 {
+		return fmt.Errorf("binding expects a vector of names and values: %s", items[1].Print(true))
+	}
+	syms := make([]MalType, len(bindings)/2)
+	for i := range syms {
+		if _, ok := bindings[2*i].(*Symbol); !ok {
+			return fmt.Errorf("binding name is not a symbol: %s", bindings[2*i].Print(true))
+		}
+		syms[i] = bindings[2*i]
+		if err := p.gen(bindings[2*i+1], sc, false); err != nil {
+			return err
+		}
+	}
+	p.emit(opBind, p.constant(NewVector(syms...)))
+	if len(items) == 2 {
+		p.emit(opConst, p.constant(NilValue))
+	}
+	for i, form := range items[2:] {
+		if i > 0 {
+			p.emit(opPop)
+		}
+		if err := p.gen(form, sc, false); err != nil {
+			return err
+		}
+	}
+	p.emit(opUnbind)
+	return nil
+}
@@ -0,0 +1,844 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	. "github.com/jdugan1024/jdgo/types"
+)
+
+// The closure compiler is the alternative to EVAL that evaluate uses by
+// default.  It analyzes each form once, turning it into a tree of Go
+// closures, nodes, that evaluate it: special forms and macros are dealt
+// with when a form is compiled rather than every time it is evaluated, and
+// local variables live in the slots of frames rather than in Envs, so that
+// each is found by a position worked out when it is compiled rather than
+// by looking up its name.
+//
+// A function call gets a frame holding its parameters and every variable
+// bound by the let* and catch* forms of its body.  The body of a fn* is
+// compiled the first time the function is called, so it can use macros
+// defined after the function is.  Globals are looked up by name, in the Env
+// the form is compiled in, when the node that uses them runs.
+
+// node evaluates a compiled form in the frame fr.
+type node func(fr *frame) (MalType, error)
+
+// frame holds the local variables of a function call, and dyn, the dynamic
+// var bindings in effect, which a binding form changes for the extent of its
+// body.  A call in tail position does not call the function but leaves it
+// and its arguments in tail and tailArgs and returns, and lambda.run makes
+// the call, so that tail calls do not grow the Go stack.
+type frame struct {
+	slots    []MalType
+	outer    *frame
+	dyn      *Bindings
+	tail     *lambda
+	tailArgs []MalType
+}
+
+// fnScope describes the frame of a function while it is compiled.
+type fnScope struct {
+	nslots int
+	outer  *fnScope
+}
+
+// local is a local variable, in the list of those visible at some point
+// of a function, innermost first.
+type local struct {
+	sym  *Symbol
+	fn   *fnScope
+	slot int
+	// maybeUnset is set for the variables of a let*, which a function made
+	// earlier in the same let* can refer to before they are set.  Until
+	// then, as with EVAL, the variable they shadow is used instead.
+	maybeUnset bool
+	next       *local
+}
+
+// scope is what a form is compiled in: the function it is part of and the
+// local variables it can see.
+type scope struct {
+	fn     *fnScope
+	locals *local
+}
+
+// bind returns sc with a new local variable sym, and sym's slot.
+func (sc scope) bind(sym *Symbol, maybeUnset bool) (scope, int) {
+	slot := sc.fn.nslots
+	sc.fn.nslots++
+	sc.locals = &local{sym, sc.fn, slot, maybeUnset, sc.locals}
+	return sc, slot
+}
+
+func (sc scope) isLocal(sym *Symbol) bool {
+	for l := sc.locals; l != nil; l = l.next {
+		if l.sym == sym {
+			return true
+		}
+	}
+	return false
+}
+
+// compiler compiles forms whose globals are looked up, and defined, in env.
+type compiler struct {
+	env *Env
+}
+
+// topLevel evaluates ast in env, with the dynamic vars bound by b, with run,
+// which compiles and runs a form.  The forms of a top level do are compiled
+// and run one at a time, so that a macro defined by one of them can be used
+// by the next.
+func topLevel(ast MalType, env *Env, b *Bindings, run func(MalType, *Env, *Bindings) (MalType, error)) (MalType, error) {
+	ast, err := macroexpand(ast, env)
+	if err != nil {
+		return nil, err
+	}
+	l, ok := isPair(ast, "do")
+	if !ok {
+		return run(ast, env, b)
+	}
+	var r MalType = NilValue
+	for _, form := range l.Items()[1:] {
+		if r, err = topLevel(form, env, b, run); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// compileAndRun evaluates ast in env, with the dynamic vars bound by b,
+// with the closure compiler.
+func compileAndRun(ast MalType, env *Env, b *Bindings) (MalType, error) {
+	return topLevel(ast, env, b, runClosures)
+}
+
+func runClosures(ast MalType, env *Env, b *Bindings) (MalType, error) {
+	fn := &fnScope{}
+	n, err := (&compiler{env}).compile(ast, scope{fn: fn}, false)
+	if err != nil {
+		return nil, err
+	}
+	return n(&frame{slots: make([]MalType, fn.nslots), dyn: b})
+}
+
+func constant(v MalType) node {
+	return func(*frame) (MalType, error) { return v, nil }
+}
+
+// compile compiles ast.  With tail set, ast is in tail position in the body
+// of a function, and a call it makes is left for lambda.run.
+func (c *compiler) compile(ast MalType, sc scope, tail bool) (node, error) {
+	switch v := ast.(type) {
+	case *Symbol:
+		return c.symbol(v, sc.fn, sc.locals), nil
+	case *List:
+		return c.list(v, sc, tail)
+	case *Cons, *LazySeq:
+		l, err := listForm(v)
+		if err != nil {
+			return nil, err
+		}
+		return c.list(l.(*List), sc, tail)
+	case *Vector:
+		items, err := c.compileAll(v.Items(), sc)
+		if err != nil {
+			return nil, err
+		}
+		return func(fr *frame) (MalType, error) {
+			r, err := runAll(items, fr)
+			if err != nil {
+				return nil, err
+			}
+			return NewVector(r...), nil
+		}, nil
+	case *HashMap:
+		keys, err := c.compileAll(v.Keys(), sc)
+		if err != nil {
+			return nil, err
+		}
+		vals, err := c.compileAll(v.Vals(), sc)
+		if err != nil {
+			return nil, err
+		}
+		return func(fr *frame) (MalType, error) {
+			forms := make([]MalType, 0, 2*len(keys))
+			for i := range keys {
+				k, err := keys[i](fr)
+				if err != nil {
+					return nil, err
+				}
+				v, err := vals[i](fr)
+				if err != nil {
+					return nil, err
+				}
+				forms = append(forms, k, v)
+			}
+			return NewHashMap(forms), nil
+		}, nil
+	case *Set:
+		items, err := c.compileAll(v.Items(), sc)
+		if err != nil {
+			return nil, err
+		}
+		return func(fr *frame) (MalType, error) {
+			r, err := runAll(items, fr)
+			if err != nil {
+				return nil, err
+			}
+			return NewSet(r...), nil
+		}, nil
+	default:
+		return constant(ast), nil
+	}
+}
+
+func (c *compiler) compileAll(forms []MalType, sc scope) ([]node, error) {
+	r := make([]node, len(forms))
+	for i, form := range forms {
+		n, err := c.compile(form, sc, false)
+		if err != nil {
+			return nil, err
+		}
+		r[i] = n
+	}
+	return r, nil
+}
+
+func runAll(nodes []node, fr *frame) ([]MalType, error) {
+	r := make([]MalType, len(nodes))
+	for i, n := range nodes {
+		v, err := n(fr)
+		if err != nil {
+			return nil, err
+		}
+		r[i] = v
+	}
+	return r, nil
+}
+
+// symbol compiles a reference to sym, as seen from the function fn with
+// the local variables locals.
+func (c *compiler) symbol(sym *Symbol, fn *fnScope, locals *local) node {
+	for l := locals; l != nil; l = l.next {
+		if l.sym != sym {
+			continue
+		}
+		depth := 0
+		for f := fn; f != l.fn; f = f.outer {
+			depth++
+		}
+		ref := localRef(depth, l.slot)
+		if !l.maybeUnset {
+			return ref
+		}
+		shadowed := c.symbol(sym, fn, l.next)
+		return func(fr *frame) (MalType, error) {
+			if v, _ := ref(fr); v != nil {
+				return v, nil
+			}
+			return shadowed(fr)
+		}
+	}
+	env := c.env
+	return func(fr *frame) (MalType, error) { return env.FindBound(sym, fr.dyn) }
+}
+
+// localRef returns the variable in the given slot of the frame depth
+// functions out from the current one.
+func localRef(depth, slot int) node {
+	switch depth {
+	case 0:
+		return func(fr *frame) (MalType, error) { return fr.slots[slot], nil }
+	case 1:
+		return func(fr *frame) (MalType, error) { return fr.outer.slots[slot], nil }
+	}
+	return func(fr *frame) (MalType, error) {
+		for i := 0; i < depth; i++ {
+			fr = fr.outer
+		}
+		return fr.slots[slot], nil
+	}
+}
+
+// macroexpand expands ast while it is a macro call.  A local variable
+// shadows a macro of the same name.
+func (c *compiler) macroexpand(ast MalType, sc scope) (MalType, error) {
+	for {
+		l, ok := ast.(*List)
+		if !ok || l.Length() == 0 {
+			return ast, nil
+		}
+		if sym, ok := l.Items()[0].(*Symbol); ok && sc.isLocal(sym) {
+			return ast, nil
+		}
+		macro, ok := macroCall(ast, c.env)
+		if !ok {
+			return ast, nil
+		}
+		var err error
+		if ast, err = macro.Eval(nil, l.Items()[1:]...); err != nil {
+			return nil, err
+		}
+		if ast, err = listForm(ast); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c *compiler) list(l *List, sc scope, tail bool) (node, error) {
+	expanded, err := c.macroexpand(l, sc)
+	if err != nil {
+		return nil, err
+	}
+	l, ok := expanded.(*List)
+	if !ok {
+		return c.compile(expanded, sc, tail)
+	}
+	if l.Length() == 0 {
+		return constant(l), nil
+	}
+
+	items := l.Items()
+	if symbol, ok := items[0].(*Symbol); ok {
+		switch symbol.Print(true) {
+		case "def!", "def-":
+			if len(items) != 3 {
+				return nil, fmt.Errorf("%s expects a symbol and a value", symbol.Print(true))
+			}
+			return c.def(items, sc, symbol.Print(true) == "def-")
+		case "let*":
+			if len(items) != 3 {
+				return nil, errors.New("let* expects bindings and a body")
+			}
+			return c.let(items, sc, tail)
+		case "do":
+			if len(items) == 1 {
+				return constant(NilValue), nil
+			}
+			forms, err := c.compileAll(items[1:len(items)-1], sc)
+			if err != nil {
+				return nil, err
+			}
+			last, err := c.compile(items[len(items)-1], sc, tail)
+			if err != nil {
+				return nil, err
+			}
+			return func(fr *frame) (MalType, error) {
+				for _, n := range forms {
+					if _, err := n(fr); err != nil {
+						return nil, err
+					}
+				}
+				return last(fr)
+			}, nil
+		case "if":
+			if len(items) != 3 && len(items) != 4 {
+				return nil, errors.New("if expects a condition and one or two branches")
+			}
+			return c.ifForm(items, sc, tail)
+		case "fn*":
+			if len(items) != 3 {
+				return nil, errors.New("fn* expects parameters and a body")
+			}
+			p, err := newProto(c, items[1], items[2], sc)
+			if err != nil {
+				return nil, err
+			}
+			return func(fr *frame) (MalType, error) {
+				return NewCompiledClosure(&lambda{p, fr}), nil
+			}, nil
+		case "quote":
+			if len(items) != 2 {
+				return nil, errors.New("quote expects one argument")
+			}
+			return constant(items[1]), nil
+		case "quasiquoteexpand":
+			if len(items) != 2 {
+				return nil, errors.New("quasiquoteexpand expects one argument")
+			}
+			return constant(quasiquote(items[1])), nil
+		case "quasiquote":
+			if len(items) != 2 {
+				return nil, errors.New("quasiquote expects one argument")
+			}
+			return c.compile(quasiquote(items[1]), sc, tail)
+		case "defmacro!":
+			if len(items) != 3 {
+				return nil, errors.New("defmacro! expects a symbol and a function")
+			}
+			return c.defmacro(items, sc)
+		case "macroexpand":
+			if len(items) != 2 {
+				return nil, errors.New("macroexpand expects one argument")
+			}
+			env := c.env
+			return func(*frame) (MalType, error) { return macroexpand(items[1], env) }, nil
+		case "try*":
+			return c.try(items, sc)
+		case "binding":
+			return c.binding(items, sc)
+		}
+	}
+	return c.call(items, sc, tail)
+}
+
+func (c *compiler) def(items []MalType, sc scope, private bool) (node, error) {
+	key, dynamic, err := defName(items[1])
+	if err != nil {
+		return nil, err
+	}
+	value, err := c.compile(items[2], sc, false)
+	if err != nil {
+		return nil, err
+	}
+	env := c.env
+	return func(fr *frame) (MalType, error) {
+		v, err := value(fr)
+		if err != nil {
+			return nil, err
+		}
+		env.Define(key, v, dynamic)
+		if ns := env.Namespace(); ns != nil && private {
+			ns.MarkPrivate(key)
+		}
+		return v, nil
+	}, nil
+}
+
+func (c *compiler) defmacro(items []MalType, sc scope) (node, error) {
+	key, ok := items[1].(*Symbol)
+	if !ok {
+		return nil, fmt.Errorf("env key is not a symbol: %s", items[1].Print(true))
+	}
+	value, err := c.compile(items[2], sc, false)
+	if err != nil {
+		return nil, err
+	}
+	env := c.env
+	return func(fr *frame) (MalType, error) {
+		v, err := value(fr)
+		if err != nil {
+			return nil, err
+		}
+		f, ok := v.(*Closure)
+		if !ok {
+			return nil, fmt.Errorf("defmacro! value is not a function: %s", v.Print(true))
+		}
+		macro := f.AsMacro()
+		env.Set(key, macro)
+		return macro, nil
+	}, nil
+}
+
+func (c *compiler) let(items []MalType, sc scope, tail bool) (node, error) {
+	switch items[1].(type) {
+	case *List, *Vector:
+	default:
+		return nil, fmt.Errorf("bindings is not a list or a vector: %s", items[1].Print(true))
+	}
+	bindings, err := Sequence(items[1])
+	if err != nil {
+		return nil, err
+	}
+	if len(bindings)%2 != 0 {
+		return nil, fmt.Errorf("odd number of binding forms: %s", items[1].Print(true))
+	}
+	// Every variable is in scope for every value, for the sake of the
+	// functions among them; see local.maybeUnset.
+	inner := sc
+	slots := make([]int, len(bindings)/2)
+	for i := 0; i < len(bindings); i += 2 {
+		sym, ok := bindings[i].(*Symbol)
+		if !ok {
+			return nil, fmt.Errorf("attempting to bind to a non symbol: %s", bindings[i].Print(true))
+		}
+		inner, slots[i/2] = inner.bind(sym, true)
+	}
+	values := make([]node, len(slots))
+	for i := range values {
+		if values[i], err = c.compile(bindings[2*i+1], inner, false); err != nil {
+			return nil, err
+		}
+	}
+	body, err := c.compile(items[2], inner, tail)
+	if err != nil {
+		return nil, err
+	}
+	return func(fr *frame) (MalType, error) {
+		for i, n := range values {
+			v, err := n(fr)
+			if err != nil {
+				return nil, err
+			}
+			fr.slots[slots[i]] = v
+		}
+		return body(fr)
+	}, nil
+}
+
+func (c *compiler) ifForm(items []MalType, sc scope, tail bool) (node, error) {
+	cond, err := c.compile(items[1], sc, false)
+	if err != nil {
+		return nil, err
+	}
+	then, err := c.compile(items[2], sc, tail)
+	if err != nil {
+		return nil, err
+	}
+	otherwise := constant(NilValue)
+	if len(items) == 4 {
+		if otherwise, err = c.compile(items[3], sc, tail); err != nil {
+			return nil, err
+		}
+	}
+	return func(fr *frame) (MalType, error) {
+		v, err := cond(fr)
+		if err != nil {
+			return nil, err
+		}
+		if Truthy(v) {
+			return then(fr)
+		}
+		return otherwise(fr)
+	}, nil
+}
+
+func (c *compiler) try(items []MalType, sc scope) (node, error) {
+	if len(items) != 2 && len(items) != 3 {
+		return nil, errors.New("try* expects a body and an optional catch* clause")
+	}
+	body, err := c.compile(items[1], sc, false)
+	if err != nil || len(items) == 2 {
+		return body, err
+	}
+
+	clause, ok := items[2].(*List)
+	if !ok || clause.Length() != 3 {
+		return nil, fmt.Errorf("malformed catch* clause: %s", items[2].Print(true))
+	}
+	catch := clause.Items()
+	if s, ok := catch[0].(*Symbol); !ok || s.Print(true) != "catch*" {
+		return nil, fmt.Errorf("malformed catch* clause: %s", items[2].Print(true))
+	}
+	sym, ok := catch[1].(*Symbol)
+	if !ok {
+		return nil, fmt.Errorf("catch* binding is not a symbol: %s", catch[1].Print(true))
+	}
+	inner, slot := sc.bind(sym, false)
+	handler, err := c.compile(catch[2], inner, false)
+	if err != nil {
+		return nil, err
+	}
+	return func(fr *frame) (MalType, error) {
+		r, err := body(fr)
+		if err == nil {
+			err = forceFirst(r)
+		}
+		if err == nil {
+			return r, nil
+		}
+		fr.slots[slot] = thrown(err)
+		return handler(fr)
+	}, nil
+}
+
+func (c *compiler) binding(items []MalType, sc scope) (node, error) {
+	if len(items) < 2 {
+		return nil, errors.New("binding expects bindings and a body")
+	}
+	bindings, err := Sequence(items[1])
+	if err != nil || len(bindings)%2 != 0 {
+		return nil, fmt.Errorf("binding expects a vector of names and values: %s", items[1].Print(true))
+	}
+	syms := make([]*Symbol, len(bindings)/2)
+	values := make([]node, len(syms))
+	for i := range syms {
+		sym, ok := bindings[2*i].(*Symbol)
+		if !ok {
+			return nil, fmt.Errorf("binding name is not a symbol: %s", bindings[2*i].Print(true))
+		}
+		syms[i] = sym
+		if values[i], err = c.compile(bindings[2*i+1], sc, false); err != nil {
+			return nil, err
+		}
+	}
+	body, err := c.compileAll(items[2:], sc)
+	if err != nil {
+		return nil, err
+	}
+	env := c.env
+	return func(fr *frame) (MalType, error) {
+		vals := make(map[*Var]MalType, len(syms))
+		for i, sym := range syms {
+			v, err := env.Var(sym)
+			if err != nil {
+				return nil, err
+			}
+			if vals[v], err = values[i](fr); err != nil {
+				return nil, err
+			}
+		}
+
+		defer func(b *Bindings) { fr.dyn = b }(fr.dyn)
+		fr.dyn = fr.dyn.Bind(vals)
+		var r MalType = NilValue
+		for _, n := range body {
+			var err error
+			if r, err = n(fr); err != nil {
+				return nil, err
+			}
+		}
+		return r, nil
+	}, nil
+}
+
+func (c *compiler) call(items []MalType, sc scope, tail bool) (node, error) {
+	fn, err := c.compile(items[0], sc, false)
+	if err != nil {
+		return nil, err
+	}
+	args, err := c.compileAll(items[1:], sc)
+	if err != nil {
+		return nil, err
+	}
+	return func(fr *frame) (MalType, error) {
+		f, err := fn(fr)
+		if err != nil {
+			return nil, err
+		}
+		vals, err := runAll(args, fr)
+		if err != nil {
+			return nil, err
+		}
+		if tail {
+			if l := lambdaOf(f); l != nil {
+				fr.tail, fr.tailArgs = l, vals
+				return nil, nil
+			}
+		}
+		return call(fr.dyn, f, vals)
+	}, nil
+}
+
+// call calls f with args and the dynamic var bindings b.
+func call(b *Bindings, f MalType, args []MalType) (MalType, error) {
+	if l := lambdaOf(f); l != nil {
+		return l.run(b, args)
+	}
+	if fn, ok := f.(Callable); ok {
+		return fn.Eval(b, args...)
+	}
+	return nil, fmt.Errorf("%s is not a function", f.Print(true))
+}
+
+// proto is a compiled fn*, from which each evaluation of it makes a lambda.
+type proto struct {
+	c      *compiler
+	params []*Symbol
+	rest   *Symbol
+	form   MalType
+	scope  scope
+
+	once   sync.Once
+	body   node
+	err    error
+	nslots int
+}
+
+func newProto(c *compiler, params, body MalType, sc scope) (*proto, error) {
+	p := &proto{c: c, form: body, scope: sc}
+	var err error
+	p.params, p.rest, err = parseParams(params)
+	return p, err
+}
+
+// parseParams returns the parameters of a fn*, and the one after &, if any.
+func parseParams(params MalType) ([]*Symbol, *Symbol, error) {
+	switch params.(type) {
+	case *List, *Vector:
+	default:
+		return nil, nil, fmt.Errorf("fn* parameters must be a list or a vector: %s", params.Print(true))
+	}
+	forms, err := Sequence(params)
+	if err != nil {
+		return nil, nil, err
+	}
+	var syms []*Symbol
+	for i := 0; i < len(forms); i++ {
+		sym, ok := forms[i].(*Symbol)
+		if !ok {
+			return nil, nil, fmt.Errorf("fn* parameter is not a symbol: %s", forms[i].Print(true))
+		}
+		if sym.Print(true) == "&" {
+			if i != len(forms)-2 {
+				return nil, nil, errors.New("fn* expects exactly one parameter after &")
+			}
+			rest, ok := forms[i+1].(*Symbol)
+			if !ok {
+				return nil, nil, fmt.Errorf("fn* parameter is not a symbol: %s", forms[i+1].Print(true))
+			}
+			return syms, rest, nil
+		}
+		syms = append(syms, sym)
+	}
+	return syms, nil, nil
+}
+
+// fnBodyScope returns the scope of the body of a function with the given
+// parameters, defined in sc.
+func fnBodyScope(sc scope, params []*Symbol, rest *Symbol) scope {
+	sc = scope{fn: &fnScope{outer: sc.fn}, locals: sc.locals}
+	for _, sym := range params {
+		sc, _ = sc.bind(sym, false)
+	}
+	if rest != nil {
+		sc, _ = sc.bind(rest, false)
+	}
+	return sc
+}
+
+// bindArgs returns the slots of a new frame, of size nslots, for a call of
+// a function with n parameters, and a rest parameter if rest is set.
+func bindArgs(args []MalType, n int, rest bool, nslots int) ([]MalType, error) {
+	if len(args) < n || (!rest && len(args) > n) {
+		return nil, fmt.Errorf("wrong number of arguments (%d instead of %d)", len(args), n)
+	}
+	slots := make([]MalType, nslots)
+	copy(slots, args[:n])
+	if rest {
+		r := make([]MalType, len(args)-n)
+		copy(r, args[n:])
+		slots[n] = NewList(r...)
+	}
+	return slots, nil
+}
+
+// thrown returns the value caught by catch* for err: the value thrown, or
+// the message of an error raised by the interpreter.
+func thrown(err error) MalType {
+	var malErr *MalError
+	if errors.As(err, &malErr) {
+		return malErr.Value()
+	}
+	return NewString(err.Error())
+}
+
+// compile compiles the body of p the first time it is called.
+func (p *proto) compile() (node, error) {
+	p.once.Do(func() {
+		sc := fnBodyScope(p.scope, p.params, p.rest)
+		p.body, p.err = p.c.compile(p.form, sc, true)
+		p.nslots = sc.fn.nslots
+	})
+	return p.body, p.err
+}
+
+// lambda is the Code of a compiled closure: its proto and the frame it was
+// made in.
+type lambda struct {
+	proto *proto
+	env   *frame
+}
+
+func lambdaOf(f MalType) *lambda {
+	if c, ok := f.(*Closure); ok {
+		l, _ := c.Code().(*lambda)
+		return l
+	}
+	return nil
+}
+
+func (l *lambda) Call(b *Bindings, args ...MalType) (MalType, error) {
+	return l.run(b, args)
+}
+
+// run calls l with args and the dynamic var bindings b, and then makes the
+// tail calls left in its frame.
+func (l *lambda) run(b *Bindings, args []MalType) (MalType, error) {
+	for {
+		body, err := l.proto.compile()
+		if err != nil {
+			return nil, err
+		}
+		fr, err := l.bind(args, b)
+		if err != nil {
+			return nil, err
+		}
+		r, err := body(fr)
+		if err != nil || fr.tail == nil {
+			return r, err
+		}
+		l, args = fr.tail, fr.tailArgs
+	}
+}
+
+// bind returns a new frame for a call of l with args and the dynamic var
+// bindings b.
+func (l *lambda) bind(args []MalType, b *Bindings) (*frame, error) {
+	p := l.proto
+	slots, err := bindArgs(args, len(p.params), p.rest != nil, p.nslots)
+	if err != nil {
+		return nil, err
+	}
+	return &frame{slots: slots, outer: l.env, dyn: b}, nil
+}
+
+func (l *lambda) Source() ClosureSource {
+	p := l.proto
+	return closureSource(p.params, p.rest, p.form, p.c.env, p.scope, l.env)
+}
+
+// closureSource describes a closure made in the frame fr from a fn* with
+// the given parameters and body, compiled in sc.  The variables it captured
+// are those of sc, but for the variables of a let* that are not set yet,
+// whose places the ones they shadow take.
+func closureSource(params []*Symbol, rest *Symbol, body MalType, env *Env, sc scope, fr *frame) ClosureSource {
+	forms := make([]MalType, 0, len(params)+2)
+	for _, sym := range params {
+		forms = append(forms, sym)
+	}
+	if rest != nil {
+		forms = append(forms, NewSymbol("&"), rest)
+	}
+	src := ClosureSource{Params: NewList(forms...), Body: body, Env: env}
+	seen := map[*Symbol]bool{}
+	for l := sc.locals; l != nil; l = l.next {
+		if seen[l.sym] {
+			continue
+		}
+		f := fr
+		for fn := sc.fn; fn != l.fn; fn = fn.outer {
+			f = f.outer
+		}
+		if v := f.slots[l.slot]; v != nil {
+			seen[l.sym] = true
+			src.Names = append(src.Names, l.sym)
+			src.Values = append(src.Values, v)
+		}
+	}
+	return src
+}
+
+// makeLambda makes a closure, as a snapshot.Maker, from a fn* compiled in
+// a scope holding only the variables names, in a frame holding only their
+// values.
+func makeLambda(params, body MalType, env *Env, names []*Symbol) (*Closure, func(int, MalType), error) {
+	sc, fr := capturedScope(names)
+	p, err := newProto(&compiler{env}, params, body, sc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewCompiledClosure(&lambda{p, fr}), func(i int, v MalType) { fr.slots[i] = v }, nil
+}
+
+// capturedScope returns a scope with the local variables names, in slots
+// in the same order, and a frame for them.
+func capturedScope(names []*Symbol) (scope, *frame) {
+	sc := scope{fn: &fnScope{}}
+	for _, sym := range names {
+		sc, _ = sc.bind(sym, false)
+	}
+	return sc, &frame{slots: make([]MalType, len(names))}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	. "github.com/jdugan1024/jdgo/types"
+)
+
+// TestCompile checks that the closure and bytecode compilers give the same
+// results as EVAL, in the cases where finding local variables by position,
+// expanding macros once and, for the bytecode machine, unwinding its own
+// stack of calls could make them differ.
+func TestCompile(t *testing.T) {
+	for _, e := range engines {
+		useEngine(e)
+		initEnv()
+		checkRep(t, e.name, []repCase{
+			// A function in a let* sees variables bound after it once
+			// they are set, and the ones they shadow until then.
+			{`(def! x "global")`, `"global"`},
+			{`(let* [f (fn* [] x) y (f) x "local"] [y (f)])`, `["global" "local"]`},
+			{`(let* [even? (fn* [n] (if (= n 0) true (odd? (- n 1))))
+			         odd? (fn* [n] (if (= n 0) false (even? (- n 1))))]
+			    (even? 10))`, "true"},
+			{`(let* [x 1 x (+ x 1)] x)`, "2"},
+			// The same holds for functions nested in them, which look
+			// through a let* variable not yet set to the variable of an
+			// enclosing let* or function, or else the global one.
+			{`(let* [f (fn* [] (fn* [] x)) y ((f)) x "local"] [y ((f))])`, `["global" "local"]`},
+			{`(let* [f (fn* [] ((fn* [] x))) y (f) x "local"] [y (f)])`, `["global" "local"]`},
+			{`(let* [x "outer"] (let* [f (fn* [] (fn* [] x)) y ((f)) x "inner"] [y ((f))]))`, `["outer" "inner"]`},
+			{`((fn* [x] (let* [f (fn* [] (fn* [] x)) y ((f)) x "inner"] [y ((f))])) "param")`, `["param" "inner"]`},
+			// A parameter of a nested function shadows the let* variable.
+			{`(let* [f (fn* [x] (fn* [] x)) y ((f "param")) x "local"] [y ((f "param"))])`, `["param" "param"]`},
+			// Variables of enclosing functions, and catch*.
+			{`(((fn* [a] (fn* [b] (fn* [c] (list a b c)))) 1) 2)`, "#<function>"},
+			{`((((fn* [a] (fn* [b] (fn* [c] (list a b c)))) 1) 2) 3)`, "(1 2 3)"},
+			{`((fn* [& xs] (try* (throw xs) (catch* e (cons (count e) e)))) 1 2)`, "(2 1 2)"},
+			// A local variable shadows a macro.
+			{`(defmacro! twice (fn* [x] (list 'do x x)))`, "#<function>"},
+			{`(let* [twice (fn* [x] (* 2 x))] (twice 4))`, "8"},
+			// A macro defined earlier in a top level do, or after a
+			// function that uses it, is expanded.
+			{`(do (defmacro! three (fn* [] 3)) (three))`, "3"},
+			{`(def! f (fn* [] (four)))`, "#<function>"},
+			{`(defmacro! four (fn* [] 4))`, "#<function>"},
+			{`(f)`, "4"},
+			// An error thrown by a function called in a try* is caught,
+			// and the dynamic bindings made since are undone.
+			{`(def! ^:dynamic *d* 0)`, "0"},
+			{`(def! g (fn* [n] (binding [*d* n] (if (= n 0) (throw *d*) (g (- n 1))))))`, "#<function>"},
+			{`[(try* (g 3) (catch* e [e *d*])) *d*]`, "[[0 0] 0]"},
+			{`(try* (map (fn* [x] (g x)) [2]) (catch* e e))`, "0"},
+			{`[(try* (binding [*d* 7] (throw 1)) (catch* e *d*)) *d*]`, "[0 0]"},
+			// Functions called back by builtins see the bindings.
+			{`(binding [*d* 5] (apply (fn* [] *d*) []))`, "5"},
+			{`(binding [*d* 5] (map (fn* [x] (+ x *d*)) [1]))`, "(6)"},
+			// map is lazy, but try* makes the first item of the lazy
+			// sequence it returns, to catch an error raised making it,
+			// and no more.
+			{`(do (map throw [1]) nil)`, "nil"},
+			{`(try* (map throw (list "my err")) (catch* e e))`, `"my err"`},
+			{`(first (try* (map (fn* [x] (* 2 x)) (range)) (catch* e e)))`, "0"},
+			{`(take 3 (map + (range) [10 20 30 40] (range)))`, "(10 22 34)"},
+			// Code built by the lazy concat and map, as quasiquote and
+			// macros build it, is evaluated as a list.
+			{`(defmacro! unless* (fn* [c & body] (concat (list 'if c nil) body)))`, "#<function>"},
+			{`(unless* false (unless* false 5))`, "5"},
+			{`(eval (map (fn* [x] (if (= x '-) '+ x)) '(- 1 (* 1 2))))`, "3"},
+			{`(eval (cons '+ (concat [1] (list 2))))`, "3"},
+			// def! in a function or let* defines a global.
+			{`(def! inner (fn* [] (def! zz 7)))`, "#<function>"},
+			{`(inner)`, "7"},
+			{`zz`, "7"},
+			{`(let* [yy 1] (def! yy 8))`, "8"},
+			{`yy`, "8"},
+			// Tail calls do not grow the stack.
+			{`(def! down (fn* [n] (if (= n 0) :done (down (- n 1)))))`, "#<function>"},
+			{`(down 100000)`, ":done"},
+		})
+		checkRepErrors(t, e.name, []repCase{
+			{`(fn* 1 2)`, "parameters must be a list or a vector"},
+			{`(fn* [a 1] a)`, "parameter is not a symbol"},
+			{`(fn* [a & 1] a)`, "parameter is not a symbol"},
+			{`(fn* [a & b c] a)`, "exactly one parameter after &"},
+			{`(fn* [& b & c] b)`, "exactly one parameter after &"},
+			{`(fn* [a &] a)`, "exactly one parameter after &"},
+			{`((fn* [a b] a) 1)`, "wrong number of arguments"},
+			{`((fn* [] 1) 2)`, "wrong number of arguments"},
+			{`((fn* [a & r] a))`, "wrong number of arguments"},
+		})
+		// An error that is not caught undoes the bindings too.
+		if _, err := rep(`(g 2)`); err == nil {
+			t.Errorf("%s: (g 2) did not throw", e.name)
+		}
+		if got, err := rep(`*d*`); err != nil || got != "0" {
+			t.Errorf("%s: *d* after an uncaught throw: got %s, %v, want 0", e.name, got, err)
+		}
+	}
+	useEngine(engines[1])
+}
+
+// TestBindingConcurrently evaluates binding forms on several goroutines at
+// once, with each engine, and checks that each sees only its own bindings,
+// in the functions it calls and those called back by builtins.
+func TestBindingConcurrently(t *testing.T) {
+	for _, e := range engines {
+		useEngine(e)
+		initEnv()
+		for _, form := range []string{
+			`(def! ^:dynamic *d* 0)`,
+			`(def! get-d (fn* [] *d*))`,
+		} {
+			if _, err := rep(form); err != nil {
+				t.Fatalf("%s: %s: %v", e.name, form, err)
+			}
+		}
+		env := CurrentNamespace().Env()
+		const n = 8
+		var started, done sync.WaitGroup
+		started.Add(n)
+		done.Add(n)
+		for i := 0; i < n; i++ {
+			form := fmt.Sprintf(`(binding [*d* %d] [(get-d) (map (fn* [_] (get-d)) [1 2]) *d*])`, i)
+			want := fmt.Sprintf("[%d (%d %d) %d]", i, i, i, i)
+			ast, err := READ(form)
+			if err != nil {
+				t.Fatal(err)
+			}
+			go func() {
+				defer done.Done()
+				started.Done()
+				started.Wait()
+				for j := 0; j < 100; j++ {
+					r, err := evaluate(ast, env, nil)
+					if err == nil {
+						err = Realize(r)
+					}
+					if err != nil {
+						t.Errorf("%s: %s: %v", e.name, form, err)
+						return
+					}
+					if got := PRINT(r); got != want {
+						t.Errorf("%s: %s: got %s, want %s", e.name, form, got, want)
+						return
+					}
+				}
+			}()
+		}
+		done.Wait()
+		if got, err := rep(`*d*`); err != nil || got != "0" {
+			t.Errorf("%s: *d* afterwards: got %s, %v, want 0", e.name, got, err)
+		}
+	}
+	useEngine(engines[1])
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jdugan1024/jdgo/conformance"
+	. "github.com/jdugan1024/jdgo/types"
+)
+
+var (
+	deferrable = flag.Bool("deferrable", true, "run the deferrable tests of the step files")
+	optional   = flag.Bool("optional", true, "run the optional tests of the step files")
+	hard       = flag.Bool("hard", false, "fail on soft failures of the step files")
+)
+
+// TestConformance runs the tests of mal's step files, from step 2 on, with
+// each engine, as runtest.py does with the REPL.  Steps 0 and 1 test REPLs
+// that print what they read rather than evaluating it.  As with runtest.py,
+// the deferrable and optional tests can be left out, with -deferrable=false
+// and -optional=false, and soft failures are only logged unless -hard is
+// given.
+func TestConformance(t *testing.T) {
+	files, err := filepath.Glob("../../tests/step*.mal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir("../../tests"); err != nil {
+		t.Fatal(err)
+	}
+
+	defer useEngine(engines[1])
+	for _, e := range engines {
+		t.Run(e.name, func(t *testing.T) {
+			useEngine(e)
+			for _, file := range files {
+				step := strings.TrimSuffix(filepath.Base(file), ".mal")
+				if step < "step2" {
+					continue
+				}
+				t.Run(step, func(t *testing.T) {
+					runStepFile(t, e.name, filepath.Base(file))
+				})
+			}
+		})
+	}
+}
+
+// runStepFile runs the tests of the step file name, from a namespace of its
+// own, since runtest.py runs each in a new interpreter.  The namespace is
+// removed afterwards, so that the images other tests save do not hold it.
+func runStepFile(t *testing.T, engine, name string) {
+	src, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases, err := conformance.Parse(name, string(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	initEnv()
+	ns := "conformance." + engine + "." + strings.TrimSuffix(name, ".mal")
+	SetCurrentNamespace(CreateNamespace(ns))
+	defer RemoveNamespace(ns)
+	coreEnv.Set(NewSymbol("*ARGV*"), NewList())
+	// The input readline reads is the next line of the file, which is a
+	// case whose expected result is that of the form calling readline.
+	var input *conformance.Case
+	next := 0
+	coreEnv.Set(NewSymbol("readline"), NewFunction("readline", func(args ...MalType) (MalType, error) {
+		if next >= len(cases) {
+			return NilValue, nil
+		}
+		input = &cases[next]
+		next++
+		return NewString(input.Form), nil
+	}))
+
+	// Cases whose patterns Go cannot compile are skipped, and not counted
+	// in total.
+	passed := map[conformance.Section]int{}
+	skipped := map[conformance.Section]int{}
+	total := map[conformance.Section]int{}
+	for next < len(cases) {
+		c := cases[next]
+		next++
+		if c.Section == conformance.Deferrable && !*deferrable || c.Section == conformance.Optional && !*optional {
+			break
+		}
+		input = nil
+		output := captureStdout(t, func() string {
+			r, err := rep(c.Form)
+			if err != nil {
+				return errorString(err)
+			}
+			return r
+		})
+		if input != nil {
+			c = *input
+		}
+		ok, err := c.Match(output)
+		if err != nil {
+			skipped[c.Section]++
+			t.Logf("skipped: %v", err)
+			continue
+		}
+		total[c.Section]++
+		switch {
+		case ok:
+			passed[c.Section]++
+		case c.Soft && !*hard:
+			t.Logf("%s:%d: soft failure: %s: got %q, want %q", c.File, c.Line, c.Form, output, c.Out+c.Ret)
+		default:
+			t.Errorf("%s:%d: %s: got %q, want %q", c.File, c.Line, c.Form, output, c.Out+c.Ret)
+		}
+	}
+	for s := conformance.Required; s <= conformance.Optional; s++ {
+		if total[s] > 0 || skipped[s] > 0 {
+			t.Logf("%s: %d of %d passed, %d skipped", s, passed[s], total[s], skipped[s])
+		}
+	}
+}
+
+// captureStdout returns what f prints to os.Stdout followed by the line f
+// returns, as the REPL would print them.
+func captureStdout(t *testing.T, f func() string) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	printed := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		printed <- string(b)
+	}()
+	line := f()
+	os.Stdout = stdout
+	w.Close()
+	return <-printed + line
+}
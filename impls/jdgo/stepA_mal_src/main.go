@@ -0,0 +1,765 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/chzyer/readline"
+
+	"github.com/jdugan1024/jdgo/core"
+	. "github.com/jdugan1024/jdgo/printer"
+	. "github.com/jdugan1024/jdgo/reader"
+	"github.com/jdugan1024/jdgo/snapshot"
+	"github.com/jdugan1024/jdgo/stdlib"
+	. "github.com/jdugan1024/jdgo/types"
+)
+
+func READ(input string) (MalType, error) {
+	reader := NewReader(Tokenize(input))
+	ast, err := reader.ReadForm()
+	if err != nil {
+		return nil, err
+	}
+	return ast, nil
+}
+
+// EVAL evaluates ast in env, with the dynamic vars bound by b.
+func EVAL(ast MalType, env *Env, b *Bindings) (MalType, error) {
+	for {
+		var err error
+		if ast, err = listForm(ast); err != nil {
+			return nil, err
+		}
+		l, ok := ast.(*List)
+		if !ok {
+			return eval_ast(ast, env, b)
+		}
+
+		expanded, err := macroexpand(l, env)
+		if err != nil {
+			return nil, err
+		}
+		l, ok = expanded.(*List)
+		if !ok {
+			return eval_ast(expanded, env, b)
+		}
+		if l.Length() == 0 {
+			return l, nil
+		}
+
+		items := l.Items()
+		if symbol, ok := items[0].(*Symbol); ok {
+			switch symbol.Print(true) {
+			case "def!", "def-":
+				if len(items) != 3 {
+					return nil, fmt.Errorf("%s expects a symbol and a value", symbol.Print(true))
+				}
+				key, dynamic, err := defName(items[1])
+				if err != nil {
+					return nil, err
+				}
+				value, err := EVAL(items[2], env, b)
+				if err != nil {
+					return nil, err
+				}
+				// As when compiled, def! in a function or let* defines a
+				// global.
+				env.Global().Define(key, value, dynamic)
+				if ns := env.Namespace(); ns != nil && symbol.Print(true) == "def-" {
+					ns.MarkPrivate(key)
+				}
+				return value, nil
+			case "let*":
+				if len(items) != 3 {
+					return nil, errors.New("let* expects bindings and a body")
+				}
+				var bindings Seq
+				switch b := items[1].(type) {
+				case *List, *Vector:
+					bindings = b.(Seq)
+				default:
+					return nil, fmt.Errorf("bindings is not a list or a vector: %s", items[1].Print(true))
+				}
+				newEnv := NewEnv(env)
+				if err := BindEnv(bindings, newEnv, evalWith(b)); err != nil {
+					return nil, err
+				}
+				ast = items[2]
+				env = newEnv
+				continue
+			case "do":
+				if len(items) == 1 {
+					return NilValue, nil
+				}
+				for _, form := range items[1 : len(items)-1] {
+					if _, err := EVAL(form, env, b); err != nil {
+						return nil, err
+					}
+				}
+				ast = items[len(items)-1]
+				continue
+			case "if":
+				if len(items) != 3 && len(items) != 4 {
+					return nil, errors.New("if expects a condition and one or two branches")
+				}
+				cond, err := EVAL(items[1], env, b)
+				if err != nil {
+					return nil, err
+				}
+				if Truthy(cond) {
+					ast = items[2]
+				} else if len(items) == 4 {
+					ast = items[3]
+				} else {
+					return NilValue, nil
+				}
+				continue
+			case "fn*":
+				if len(items) != 3 {
+					return nil, errors.New("fn* expects parameters and a body")
+				}
+				return NewClosure(items[1], items[2], env, EVAL)
+			case "quote":
+				if len(items) != 2 {
+					return nil, errors.New("quote expects one argument")
+				}
+				return items[1], nil
+			case "quasiquoteexpand":
+				if len(items) != 2 {
+					return nil, errors.New("quasiquoteexpand expects one argument")
+				}
+				return quasiquote(items[1]), nil
+			case "quasiquote":
+				if len(items) != 2 {
+					return nil, errors.New("quasiquote expects one argument")
+				}
+				ast = quasiquote(items[1])
+				continue
+			case "defmacro!":
+				if len(items) != 3 {
+					return nil, errors.New("defmacro! expects a symbol and a function")
+				}
+				key, ok := items[1].(*Symbol)
+				if !ok {
+					return nil, fmt.Errorf("env key is not a symbol: %s", items[1].Print(true))
+				}
+				value, err := EVAL(items[2], env, b)
+				if err != nil {
+					return nil, err
+				}
+				f, ok := value.(*Closure)
+				if !ok {
+					return nil, fmt.Errorf("defmacro! value is not a function: %s", value.Print(true))
+				}
+				macro := f.AsMacro()
+				env.Global().Set(key, macro)
+				return macro, nil
+			case "macroexpand":
+				if len(items) != 2 {
+					return nil, errors.New("macroexpand expects one argument")
+				}
+				return macroexpand(items[1], env)
+			case "try*":
+				return evalTry(items, env, b)
+			case "binding":
+				return evalBinding(items, env, b)
+			}
+		}
+
+		e, err := eval_ast(l, env, b)
+		if err != nil {
+			return nil, err
+		}
+		el := e.(*List).Items()
+		switch f := el[0].(type) {
+		case *Closure:
+			if f.Code() != nil {
+				return f.Eval(b, el[1:]...)
+			}
+			env, err = f.Bind(el[1:]...)
+			if err != nil {
+				return nil, err
+			}
+			ast = f.Body()
+		case Callable:
+			return f.Eval(b, el[1:]...)
+		default:
+			return nil, fmt.Errorf("%s is not a function", el[0].Print(true))
+		}
+	}
+}
+
+var kwDynamic = NewKeyword("dynamic")
+
+// defName returns the symbol that def! defines, given as name or with
+// metadata as ^:dynamic name or ^{:dynamic true} name, and whether that
+// metadata makes it a dynamic var.
+func defName(form MalType) (*Symbol, bool, error) {
+	dynamic := false
+	if l, ok := form.(*List); ok && l.Length() == 3 {
+		items := l.Items()
+		if s, ok := items[0].(*Symbol); ok && s.Print(true) == "with-meta" {
+			if meta, ok := items[2].(*HashMap); ok {
+				v, ok := meta.Get(kwDynamic)
+				dynamic = ok && Truthy(v)
+			} else {
+				dynamic = items[2] == kwDynamic
+			}
+			form = items[1]
+		}
+	}
+	key, ok := form.(*Symbol)
+	if !ok {
+		return nil, false, fmt.Errorf("env key is not a symbol: %s", form.Print(true))
+	}
+	return key, dynamic, nil
+}
+
+// evalBinding evaluates (binding [name value ...] body ...), which binds
+// each dynamic var name to its value while the body is evaluated.
+func evalBinding(items []MalType, env *Env, b *Bindings) (MalType, error) {
+	if len(items) < 2 {
+		return nil, errors.New("binding expects bindings and a body")
+	}
+	bindings, err := Sequence(items[1])
+	if err != nil || len(bindings)%2 != 0 {
+		return nil, fmt.Errorf("binding expects a vector of names and values: %s", items[1].Print(true))
+	}
+	vals := make(map[*Var]MalType, len(bindings)/2)
+	for i := 0; i < len(bindings); i += 2 {
+		sym, ok := bindings[i].(*Symbol)
+		if !ok {
+			return nil, fmt.Errorf("binding name is not a symbol: %s", bindings[i].Print(true))
+		}
+		v, err := env.Var(sym)
+		if err != nil {
+			return nil, err
+		}
+		if vals[v], err = EVAL(bindings[i+1], env, b); err != nil {
+			return nil, err
+		}
+	}
+
+	b = b.Bind(vals)
+	var r MalType = NilValue
+	for _, form := range items[2:] {
+		if r, err = EVAL(form, env, b); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func evalTry(items []MalType, env *Env, b *Bindings) (MalType, error) {
+	if len(items) != 2 && len(items) != 3 {
+		return nil, errors.New("try* expects a body and an optional catch* clause")
+	}
+	r, err := EVAL(items[1], env, b)
+	if len(items) == 2 {
+		return r, err
+	}
+	if err == nil {
+		err = forceFirst(r)
+	}
+	if err == nil {
+		return r, nil
+	}
+
+	clause, ok := items[2].(*List)
+	if !ok || clause.Length() != 3 {
+		return nil, fmt.Errorf("malformed catch* clause: %s", items[2].Print(true))
+	}
+	catch := clause.Items()
+	if s, ok := catch[0].(*Symbol); !ok || s.Print(true) != "catch*" {
+		return nil, fmt.Errorf("malformed catch* clause: %s", items[2].Print(true))
+	}
+	sym, ok := catch[1].(*Symbol)
+	if !ok {
+		return nil, fmt.Errorf("catch* binding is not a symbol: %s", catch[1].Print(true))
+	}
+
+	catchEnv := NewEnv(env)
+	catchEnv.Set(sym, thrown(err))
+	return EVAL(catch[2], catchEnv, b)
+}
+
+// forceFirst makes the first step of v, if it is a lazy sequence, so that
+// an error raised making it is caught by the try* whose value v is, as in
+// (try* (map throw xs) (catch* e e)).  The rest of v is left to be made as
+// it is needed.
+func forceFirst(v MalType) error {
+	if s, ok := v.(*LazySeq); ok {
+		_, err := s.IsEmpty()
+		return err
+	}
+	return nil
+}
+
+// listForm returns ast as a List if it is a Cons or a LazySeq, as cons,
+// concat and map return, so that code built with them, as quasiquote and
+// macros do, is evaluated as a list would be.  Other forms are returned as
+// they are.
+func listForm(ast MalType) (MalType, error) {
+	switch ast.(type) {
+	case *Cons, *LazySeq:
+		items, err := Sequence(ast)
+		if err != nil {
+			return nil, err
+		}
+		return NewList(items...), nil
+	}
+	return ast, nil
+}
+
+func isPair(ast MalType, name string) (*List, bool) {
+	l, ok := ast.(*List)
+	if !ok || l.Length() == 0 {
+		return nil, false
+	}
+	s, ok := l.Items()[0].(*Symbol)
+	return l, ok && s.Print(true) == name
+}
+
+func quasiquote(ast MalType) MalType {
+	switch v := ast.(type) {
+	case *List:
+		if l, ok := isPair(v, "unquote"); ok && l.Length() == 2 {
+			return l.Items()[1]
+		}
+		return quasiquoteItems(v.Items())
+	case *Vector:
+		return NewList(NewSymbol("vec"), quasiquoteItems(v.Items()))
+	case *HashMap, *Set, *Symbol:
+		return NewList(NewSymbol("quote"), ast)
+	default:
+		return ast
+	}
+}
+
+func quasiquoteItems(items []MalType) MalType {
+	var r MalType = NewList()
+	for i := len(items) - 1; i >= 0; i-- {
+		item := items[i]
+		if l, ok := isPair(item, "splice-unquote"); ok && l.Length() == 2 {
+			r = NewList(NewSymbol("concat"), l.Items()[1], r)
+		} else {
+			r = NewList(NewSymbol("cons"), quasiquote(item), r)
+		}
+	}
+	return r
+}
+
+func macroCall(ast MalType, env *Env) (*Closure, bool) {
+	l, ok := ast.(*List)
+	if !ok || l.Length() == 0 {
+		return nil, false
+	}
+	sym, ok := l.Items()[0].(*Symbol)
+	if !ok {
+		return nil, false
+	}
+	v, ok := env.Lookup(sym)
+	if !ok {
+		return nil, false
+	}
+	f, ok := v.(*Closure)
+	return f, ok && f.IsMacro()
+}
+
+func macroexpand(ast MalType, env *Env) (MalType, error) {
+	for {
+		macro, ok := macroCall(ast, env)
+		if !ok {
+			return ast, nil
+		}
+		var err error
+		ast, err = macro.Eval(nil, ast.(*List).Items()[1:]...)
+		if err != nil {
+			return nil, err
+		}
+		if ast, err = listForm(ast); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func PRINT(ast MalType) string {
+	return PrintStr(ast, true)
+}
+
+// engine is a way of evaluating forms: eval evaluates a top level form with
+// the dynamic vars bound by the given Bindings, and makeClosure makes the
+// closures of an image loaded by load-image.
+type engine struct {
+	name        string
+	eval        func(MalType, *Env, *Bindings) (MalType, error)
+	makeClosure snapshot.Maker
+}
+
+// engines are the engines JDGO_ENGINE can select by name: EVAL, the tree
+// walking interpreter, the closure compiler, which is the default, and the
+// bytecode compiler and machine.
+var engines = []engine{
+	{"tree", EVAL, makeTreeClosure},
+	{"closure", compileAndRun, makeLambda},
+	{"vm", runVM, makeVMLambda},
+}
+
+// evaluate and makeClosure are those of the engine in use.
+var (
+	evaluate    = compileAndRun
+	makeClosure = makeLambda
+)
+
+// useEngine makes e the engine in use.
+func useEngine(e engine) {
+	evaluate, makeClosure = e.eval, e.makeClosure
+}
+
+// makeTreeClosure makes a closure for EVAL, as a snapshot.Maker, in an Env
+// holding the variables names.
+func makeTreeClosure(params, body MalType, env *Env, names []*Symbol) (*Closure, func(int, MalType), error) {
+	locals := NewEnv(env)
+	c, err := NewClosure(params, body, locals, EVAL)
+	return c, func(i int, v MalType) { locals.Set(names[i], v) }, err
+}
+
+// coreEnv holds the builtins.  Top level forms are evaluated in the Env of
+// the current namespace, whose outer Env it is.
+var coreEnv = CoreNamespace().Env()
+
+func rep(input string) (string, error) {
+	ast, err := READ(input)
+	if err != nil {
+		return "", err
+	}
+	ev, err := evaluate(ast, CurrentNamespace().Env(), nil)
+	if err != nil {
+		return "", err
+	}
+	if err := Realize(ev); err != nil {
+		return "", err
+	}
+	return PRINT(ev), nil
+}
+
+func eval_ast(ast MalType, env *Env, b *Bindings) (MalType, error) {
+	switch v := ast.(type) {
+	case *Symbol:
+		return env.FindBound(v, b)
+	case *List:
+		return v.Map(evalWith(b), env)
+	case *Vector:
+		return v.Map(evalWith(b), env)
+	case *HashMap:
+		return v.Map(evalWith(b), env)
+	case *Set:
+		return v.Map(evalWith(b), env)
+	default:
+		return ast, nil
+	}
+}
+
+// evalWith returns EVAL with the dynamic vars bound by b, for the helpers
+// that evaluate forms in an Env.
+func evalWith(b *Bindings) func(MalType, *Env) (MalType, error) {
+	return func(ast MalType, env *Env) (MalType, error) { return EVAL(ast, env, b) }
+}
+
+// loadFile reads and evaluates every form in filename with the dynamic vars
+// bound by b, returning nil.
+func loadFile(filename string, b *Bindings) (MalType, error) {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return loadSource(string(src), b)
+}
+
+// loadSource evaluates every form in src with the dynamic vars bound by b,
+// returning nil.  The forms are evaluated in the current namespace, which
+// they can change with in-ns or ns; it is restored once they are loaded.
+func loadSource(src string, b *Bindings) (MalType, error) {
+	defer SetCurrentNamespace(CurrentNamespace())
+	reader := NewReader(Tokenize(src))
+	for {
+		if _, err := reader.Peek(); err != nil {
+			break
+		}
+		form, err := reader.ReadForm()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := evaluate(form, CurrentNamespace().Env(), b); err != nil {
+			return nil, err
+		}
+	}
+	return NilValue, nil
+}
+
+// imageFile returns the file name that is the argument of save-image or
+// load-image.
+func imageFile(name string, args []MalType) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("%s: wrong number of arguments (%d instead of 1)", name, len(args))
+	}
+	filename, ok := args[0].(*String)
+	if !ok {
+		return "", fmt.Errorf("argument is not a String: %s", args[0].Print(true))
+	}
+	return filename.Value(), nil
+}
+
+// prettyWidth is the line width pprint tries to keep its output within.
+const prettyWidth = 80
+
+// printLimit returns the value under the bindings b of the *print-length*
+// or *print-level* var named by name, or NoLimit when it is nil or not an
+// Int.
+func printLimit(name string, b *Bindings) int {
+	v, err := CurrentNamespace().Env().FindBound(NewSymbol(name), b)
+	if err != nil {
+		return NoLimit
+	}
+	i, ok := v.(*Int)
+	if !ok {
+		return NoLimit
+	}
+	return i.AsInt()
+}
+
+func errorString(err error) string {
+	var malErr *MalError
+	if errors.As(err, &malErr) {
+		return "Error: " + PrintStr(malErr.Value(), true)
+	}
+	return "Error: " + err.Error()
+}
+
+// imageWarnings is where save-image says which definitions it leaves out.
+var imageWarnings io.Writer = os.Stderr
+
+// initEnv defines the builtins and the functions and macros written in mal
+// in the core namespace and the set algebra in the set namespace, and then
+// switches to the user namespace.
+func initEnv() {
+	SetCurrentNamespace(CoreNamespace())
+	for name, f := range core.NS {
+		coreEnv.Set(NewSymbol(name), NewFunction(name, f))
+	}
+	for name, f := range core.DynamicNS {
+		coreEnv.Set(NewSymbol(name), NewDynamicFunction(name, f))
+	}
+	for name, f := range core.LazyNS {
+		coreEnv.Set(NewSymbol(name), NewFunction(name, f))
+	}
+	for name, f := range core.LazyDynamicNS {
+		coreEnv.Set(NewSymbol(name), NewDynamicFunction(name, f))
+	}
+	for name, f := range core.ReduceNS {
+		coreEnv.Set(NewSymbol(name), NewFunction(name, f))
+	}
+	for name, f := range core.ReduceDynamicNS {
+		coreEnv.Set(NewSymbol(name), NewDynamicFunction(name, f))
+	}
+	for name, f := range core.RecordNS {
+		coreEnv.Set(NewSymbol(name), NewFunction(name, f))
+	}
+	for name, f := range core.ProtocolNS {
+		coreEnv.Set(NewSymbol(name), NewFunction(name, f))
+	}
+	for name, f := range core.NamespaceNS {
+		coreEnv.Set(NewSymbol(name), NewFunction(name, f))
+	}
+	for name, f := range core.MultiNS {
+		coreEnv.Set(NewSymbol(name), NewFunction(name, f))
+	}
+	for _, name := range core.TypeNames {
+		coreEnv.Set(NewSymbol(name), NewBuiltinType(name))
+	}
+	setNS := CreateNamespace("set")
+	for name, f := range core.SetNS {
+		setNS.Env().Set(NewSymbol(name), NewFunction("set/"+name, f))
+	}
+	for name, f := range core.SetDynamicNS {
+		setNS.Env().Set(NewSymbol(name), NewDynamicFunction("set/"+name, f))
+	}
+	coreEnv.Set(NewSymbol("eval"), NewDynamicFunction("eval", func(b *Bindings, args ...MalType) (MalType, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("eval: wrong number of arguments (%d instead of 1)", len(args))
+		}
+		return evaluate(args[0], CurrentNamespace().Env(), b)
+	}))
+	coreEnv.Set(NewSymbol("load-file"), NewDynamicFunction("load-file", func(b *Bindings, args ...MalType) (MalType, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("load-file: wrong number of arguments (%d instead of 1)", len(args))
+		}
+		filename, ok := args[0].(*String)
+		if !ok {
+			return nil, fmt.Errorf("argument is not a String: %s", args[0].Print(true))
+		}
+		return loadFile(filename.Value(), b)
+	}))
+	coreEnv.Set(NewSymbol("save-image"), NewFunction("save-image", func(args ...MalType) (MalType, error) {
+		filename, err := imageFile("save-image", args)
+		if err != nil {
+			return nil, err
+		}
+		f, err := os.Create(filename)
+		if err != nil {
+			return nil, err
+		}
+		if err := snapshot.Save(f, imageWarnings); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return NilValue, f.Close()
+	}))
+	coreEnv.Set(NewSymbol("load-image"), NewFunction("load-image", func(args ...MalType) (MalType, error) {
+		filename, err := imageFile("load-image", args)
+		if err != nil {
+			return nil, err
+		}
+		f, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if err := snapshot.Load(f, makeClosure); err != nil {
+			return nil, fmt.Errorf("%s: %s", filename, err)
+		}
+		return NilValue, nil
+	}))
+	coreEnv.Set(NewSymbol("pprint"), NewDynamicFunction("pprint", func(b *Bindings, args ...MalType) (MalType, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("pprint: wrong number of arguments (%d instead of 1)", len(args))
+		}
+		limits := Limits{Length: printLimit("*print-length*", b), Level: printLimit("*print-level*", b)}
+		if err := PrettyPrintLimits(os.Stdout, args[0], prettyWidth, limits); err != nil {
+			return nil, err
+		}
+		return NilValue, nil
+	}))
+	coreEnv.Define(NewSymbol("*print-length*"), NilValue, true)
+	coreEnv.Define(NewSymbol("*print-level*"), NilValue, true)
+	coreEnv.Set(NewSymbol("*host-language*"), NewString("jdgo"))
+
+	rep("(def! not (fn* (a) (if a false true)))")
+	rep("(defmacro! lazy-seq (fn* (& body) (list 'lazy-seq* (list 'fn* [] (cons 'do body)))))")
+	rep(`(defmacro! defrecord (fn* (name fields)
+	       (let* [s (fn* (& xs) (symbol (apply str xs)))]
+	         (quasiquote
+	           (do (def! ~name (record-type '~name '~fields))
+	               (def! ~(s "->" name) (fn* (& values) (apply record ~name values)))
+	               (def! ~(s name ".") ~(s "->" name))
+	               (def! ~(s "map->" name) (fn* (m) (map->record ~name m)))
+	               (def! ~(s name "?") (fn* (x) (instance? ~name x)))
+	               ~name)))))`)
+	rep(`(defmacro! deftype (fn* (name fields)
+	       (let* [s (fn* (& xs) (symbol (apply str xs)))]
+	         (quasiquote
+	           (do (def! ~name (deftype-type '~name '~fields))
+	               (def! ~(s "->" name) (fn* (& values) (apply deftype-instance ~name values)))
+	               (def! ~(s name ".") ~(s "->" name))
+	               (def! ~(s name "?") (fn* (x) (instance? ~name x)))
+	               ~name)))))`)
+	rep("(defmacro! defprotocol (fn* (& forms) (apply defprotocol-form forms)))")
+	rep("(defmacro! extend-type (fn* (& forms) (apply extend-type-form forms)))")
+	rep("(defmacro! extend-protocol (fn* (& forms) (apply extend-protocol-form forms)))")
+	rep("(defmacro! defmulti (fn* (name & args) (list 'def! name (cons 'multi-fn (cons (list 'quote name) args)))))")
+	rep("(defmacro! defmethod (fn* (name dv params & body) (list 'add-method name dv (list 'fn* params (cons 'do body)))))")
+	rep("(defmacro! ns (fn* (& forms) (apply ns-form forms)))")
+	rep("(defmacro! cond (fn* (& xs) (if (> (count xs) 0) (list 'if (first xs) (if (> (count xs) 1) (nth xs 1) (throw \"odd number of forms to cond\")) (cons 'cond (rest (rest xs)))))))")
+
+	core.ModuleLoader = core.NewLoader(func(filename string, src []byte) error {
+		_, err := loadSource(string(src), nil)
+		return err
+	}, stdlib.FS)
+	SetCurrentNamespace(CreateNamespace("user"))
+}
+
+// prompt returns the REPL prompt, which names the current namespace.
+func prompt() string {
+	return CurrentNamespace().Name() + "> "
+}
+
+func main() {
+	rl, err := readline.New(prompt())
+	if err != nil {
+		panic(err)
+	}
+	defer rl.Close()
+
+	switch os.Getenv("JDGO_MAP_ORDER") {
+	case "", "insertion":
+	case "sorted":
+		SetMapOrder(SortedOrder)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown JDGO_MAP_ORDER %q, using insertion order\n", os.Getenv("JDGO_MAP_ORDER"))
+	}
+	if name := os.Getenv("JDGO_ENGINE"); name != "" {
+		found := false
+		for _, e := range engines {
+			if e.name == name {
+				useEngine(e)
+				found = true
+			}
+		}
+		if !found {
+			fmt.Fprintf(os.Stderr, "unknown JDGO_ENGINE %q, using the closure compiler\n", name)
+		}
+	}
+
+	initEnv()
+	coreEnv.Set(NewSymbol("readline"), NewFunction("readline", func(args ...MalType) (MalType, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("readline: wrong number of arguments (%d instead of 1)", len(args))
+		}
+		text, ok := args[0].(*String)
+		if !ok {
+			return nil, fmt.Errorf("argument is not a String: %s", args[0].Print(true))
+		}
+		rl.SetPrompt(text.Value())
+		defer rl.SetPrompt(prompt())
+		line, err := rl.Readline()
+		if err != nil {
+			return NilValue, nil
+		}
+		return NewString(line), nil
+	}))
+
+	if len(os.Args) > 1 {
+		argv := []MalType{}
+		for _, a := range os.Args[2:] {
+			argv = append(argv, NewString(a))
+		}
+		coreEnv.Set(NewSymbol("*ARGV*"), NewList(argv...))
+		if _, err := loadFile(os.Args[1], nil); err != nil {
+			fmt.Println(errorString(err))
+			os.Exit(1)
+		}
+		return
+	}
+	coreEnv.Set(NewSymbol("*ARGV*"), NewList())
+
+	for {
+		rl.SetPrompt(prompt())
+		input, err := rl.Readline()
+		if err != nil {
+			break
+		}
+		if strings.TrimSpace(input) == "" {
+			continue
+		}
+
+		r, err := rep(input)
+		if err != nil {
+			fmt.Println(errorString(err))
+			continue
+		}
+		fmt.Println(r)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+
+	. "github.com/jdugan1024/jdgo/types"
+)
+
+// TestMetadata checks with-meta, meta and vary-meta on collections and
+// functions, and the ^ reader form.
+func TestMetadata(t *testing.T) {
+	initEnv()
+	SetCurrentNamespace(CreateNamespace("test.meta"))
+	defer RemoveNamespace("test.meta")
+	checkRep(t, "", []repCase{
+		// Giving a value metadata makes a new value, equal to the old one,
+		// which keeps its own metadata.
+		{`(def! v [1 2])`, "[1 2]"},
+		{`(def! mv (with-meta v {:a 1}))`, "[1 2]"},
+		{`[(meta v) (meta mv) (= v mv)]`, "[nil {:a 1} true]"},
+		{`(meta (with-meta (list 1) {:l 1}))`, "{:l 1}"},
+		{`(meta (with-meta {:k 1} {:m 1}))`, "{:m 1}"},
+		{`(meta (with-meta #{1} {:s 1}))`, "{:s 1}"},
+		{`(meta (conj mv 3))`, "{:a 1}"},
+		{`(meta (vary-meta mv assoc :b 2))`, "{:a 1 :b 2}"},
+		{`(meta (meta (with-meta [] (with-meta {} {:x 1}))))`, "{:x 1}"},
+		// Functions, builtin or not, still work with metadata.
+		{`(def! f (fn* [x] (* 2 x)))`, "#<function>"},
+		{`(def! mf (with-meta f {:doc "doubles"}))`, "#<function>"},
+		{`[(meta f) (meta mf) (mf 4)]`, `[nil {:doc "doubles"} 8]`},
+		{`(def! plus (with-meta + {:doc "adds"}))`, "+"},
+		{`[(meta +) (meta plus) (plus 1 2)]`, `[nil {:doc "adds"} 3]`},
+		// ^ gives the next form metadata: a map, or a keyword, a symbol
+		// or a string as shorthand.
+		{`(meta ^{:doc "d"} [1])`, `{:doc "d"}`},
+		{`(meta ^:private [1])`, "{:private true}"},
+		{`(meta ^String {:k 1})`, "{:tag String}"},
+		{`(meta ^"s" #{})`, `{:tag "s"}`},
+		{`(meta ^:a ^:b [1])`, "{:a true}"},
+		{`(meta ^{:doc "g"} (fn* [] 1))`, `{:doc "g"}`},
+		{`'^:a [1]`, "(with-meta [1] {:a true})"},
+	})
+	checkRepErrors(t, "", []repCase{
+		{`(with-meta 1 {})`, "does not support metadata"},
+		{`(meta ^1 [1])`, "metadata must be a map, keyword, symbol or string"},
+	})
+}
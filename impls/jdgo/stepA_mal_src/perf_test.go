@@ -0,0 +1,142 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/jdugan1024/jdgo/reader"
+	. "github.com/jdugan1024/jdgo/types"
+)
+
+// The benchmarks run the work that the programs in tests time, perf1.mal,
+// perf2.mal, perf3.mal, fib.mal and busywork.mal, once per iteration and
+// with each engine.  Each program is read from its file and its top level
+// forms evaluated, but for the one that times the work, with time,
+// benchmark or run-fn-for, for as long as ten seconds.  The work is what
+// that form times.
+
+// BenchmarkPerf1 evaluates the macros of tests/perf1.mal: or, cond and ->.
+func BenchmarkPerf1(b *testing.B) {
+	benchmarkEngines(b, "perf1.mal")
+}
+
+// BenchmarkPerf2 runs the arithmetic and recursion of tests/perf2.mal.
+func BenchmarkPerf2(b *testing.B) {
+	benchmarkEngines(b, "perf2.mal")
+}
+
+// BenchmarkPerf3 runs one iteration of the loop that tests/perf3.mal runs
+// for ten seconds: macros, atoms and list functions, which spend most of
+// their time looking symbols up.
+func BenchmarkPerf3(b *testing.B) {
+	benchmarkEngines(b, "perf3.mal")
+}
+
+// BenchmarkFib computes (fib 20) with the function of tests/fib.mal, which
+// is all function calls and arithmetic.  The program reads n from its
+// command line.
+func BenchmarkFib(b *testing.B) {
+	benchmarkEngines(b, "fib.mal", `(def! n 20)`)
+}
+
+// BenchmarkBusywork runs 100 of the iterations that tests/busywork.mal
+// runs 10000 of: the work of perf3, called by a recursive function.
+func BenchmarkBusywork(b *testing.B) {
+	benchmarkEngines(b, "busywork.mal", `(def! num-iterations 100)`)
+}
+
+// timed returns the work that form times with time, benchmark or
+// run-fn-for, which takes a function rather than a form, or nil if it
+// times nothing.
+func timed(form MalType) MalType {
+	l, ok := form.(*List)
+	if !ok || l.Length() == 0 {
+		return nil
+	}
+	items := l.Items()
+	if sym, ok := items[0].(*Symbol); ok && len(items) > 1 {
+		switch sym.Print(true) {
+		case "time", "benchmark":
+			return items[1]
+		case "run-fn-for":
+			return NewList(items[1])
+		}
+	}
+	for _, item := range items[1:] {
+		if work := timed(item); work != nil {
+			return work
+		}
+	}
+	return nil
+}
+
+// benchmarkEngines runs benchmarkProgram with each engine.
+func benchmarkEngines(b *testing.B, file string, defs ...string) {
+	for _, e := range engines {
+		b.Run(e.name, func(b *testing.B) {
+			defer useEngine(engines[1])
+			useEngine(e)
+			benchmarkProgram(b, e.name, file, defs)
+		})
+	}
+}
+
+// benchmarkProgram evaluates the forms of the program in file, from the
+// tests directory as the programs there are run, then the definitions
+// defs, which take the place of its command line arguments or make it do
+// less work, and then times evaluating the work the program times.  The
+// forms are evaluated in a namespace of the engine's own, since
+// load-file-once does not load a file again once it is defined in a
+// namespace, and the functions a file defines should be those made by the
+// engine.
+func benchmarkProgram(b *testing.B, engine, file string, defs []string) {
+	wd, err := os.Getwd()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir("../../tests"); err != nil {
+		b.Fatal(err)
+	}
+	src, err := os.ReadFile(file)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	initEnv()
+	SetCurrentNamespace(CreateNamespace("bench." + engine))
+	var work MalType
+	reader := NewReader(Tokenize(string(src)))
+	for {
+		if _, err := reader.Peek(); err != nil {
+			break
+		}
+		form, err := reader.ReadForm()
+		if err != nil {
+			b.Fatal(err)
+		}
+		if w := timed(form); w != nil {
+			work = w
+			continue
+		}
+		if _, err := evaluate(form, CurrentNamespace().Env(), nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if work == nil {
+		b.Fatalf("%s times nothing", file)
+	}
+	for _, d := range defs {
+		if _, err := rep(d); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := evaluate(work, CurrentNamespace().Env(), nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+
+	. "github.com/jdugan1024/jdgo/types"
+)
+
+// TestRecords checks that a record defined with defrecord behaves as a map
+// of its fields, keeps its type through assoc of other keys and is only
+// equal to records of the same type.
+func TestRecords(t *testing.T) {
+	initEnv()
+	SetCurrentNamespace(CreateNamespace("test.records"))
+	defer RemoveNamespace("test.records")
+	checkRep(t, "", []repCase{
+		{`(defrecord Point [x y])`, "test.records.Point"},
+		{`(def! p (->Point 1 2))`, "#test.records.Point{:x 1 :y 2}"},
+		// Keyword lookup, and the other ways of getting a field.
+		{`[(:x p) (:y p) (:z p) (:z p :none)]`, "[1 2 nil :none]"},
+		{`[(get p :y) (contains? p :x) (contains? p :z)]`, "[2 true false]"},
+		{`[(keys p) (vals p) (count p)]`, "[(:x :y) (1 2) 2]"},
+		// assoc of a field and of a key that is not one.
+		{`(assoc p :x 10)`, "#test.records.Point{:x 10 :y 2}"},
+		{`(def! q (assoc p :z 3))`, "#test.records.Point{:x 1 :y 2 :z 3}"},
+		{`[(:z q) (record? q) (Point? q) (count q)]`, "[3 true true 3]"},
+		{`(dissoc q :z)`, "#test.records.Point{:x 1 :y 2}"},
+		{`[(dissoc p :x) (record? (dissoc p :x))]`, "[{:y 2} false]"},
+		// Equality.
+		{`(= p (->Point 1 2))`, "true"},
+		{`(= p (Point. 1 2))`, "true"},
+		{`(= p (->Point 2 1))`, "false"},
+		{`(= p {:x 1 :y 2})`, "false"},
+		{`(= p (dissoc q :z))`, "true"},
+		{`(get {p :found} (->Point 1 2))`, ":found"},
+		{`(defrecord Other [x y])`, "test.records.Other"},
+		{`(= p (->Other 1 2))`, "false"},
+		{`(Point? (->Other 1 2))`, "false"},
+		// map->record fills missing fields with nil and keeps extra keys.
+		{`(map->Point {:y 2 :x 1})`, "#test.records.Point{:x 1 :y 2}"},
+		{`(= p (map->Point {:y 2 :x 1}))`, "true"},
+		{`(map->Point {:x 1})`, "#test.records.Point{:x 1 :y nil}"},
+		{`(map->Point {:x 1 :y 2 :w 0})`, "#test.records.Point{:x 1 :y 2 :w 0}"},
+		{`(map->Point {})`, "#test.records.Point{:x nil :y nil}"},
+	})
+
+	checkRepErrors(t, "", []repCase{
+		{`(->Point 1)`, "wrong number of arguments"},
+		{`(map->Point [1 2])`, "HashMap"},
+	})
+}
+
+// TestDeftype checks that a value of a type defined with deftype is not a
+// map, has its fields read with .-field and is only equal to itself.
+func TestDeftype(t *testing.T) {
+	initEnv()
+	SetCurrentNamespace(CreateNamespace("test.records"))
+	defer RemoveNamespace("test.records")
+	checkRep(t, "", []repCase{
+		{`(deftype Pt [x y])`, "test.records.Pt"},
+		{`(def! p (->Pt 1 2))`, "#test.records.Pt[1 2]"},
+		{`(Pt. 1 2)`, "#test.records.Pt[1 2]"},
+		{`[(.-x p) (.-y p) (map .-x [p (->Pt 3 4)])]`, "[1 2 (1 3)]"},
+		{`[(Pt? p) (instance? Pt p) (record? p) (map? p)]`, "[true true false false]"},
+		{`[(= p p) (= p (->Pt 1 2)) (get {p :found} p)]`, "[true false :found]"},
+		// .-field reads the fields of records too, and a defined .-field
+		// comes first.
+		{`(defrecord Point [x y])`, "test.records.Point"},
+		{`[(.-y (->Point 1 2)) (Pt? (->Point 1 2)) (Point? p)]`, "[2 false false]"},
+		// Protocols extend to it like any other type.
+		{`(defprotocol Norm (norm [v]))`, "#<protocol Norm>"},
+		{`(extend-type Pt Norm (norm [v] (+ (.-x v) (.-y v))))`, "nil"},
+		{`(norm p)`, "3"},
+		{`(def! .-y (fn* [v] :mine))`, "#<function>"},
+		{`(.-y p)`, ":mine"},
+	})
+
+	checkRepErrors(t, "", []repCase{
+		{`(->Pt 1)`, "wrong number of arguments"},
+		{`(.-z p)`, "no field z"},
+		{`(.-x 1)`, "no field x"},
+		{`(record Pt 1 2)`, "not a record type"},
+		{`(map->record Pt {:x 1})`, "not a record type"},
+		{`(deftype-instance Point 1 2)`, "not a deftype"},
+	})
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// repCase is a form for rep and what it should print, or for
+// checkRepErrors a part of the error it should give.
+type repCase struct{ form, want string }
+
+// checkRep evaluates the forms of cases in turn with rep and reports each
+// that fails or prints something else, after name unless it is empty.  It
+// returns whether every form printed what it should.
+func checkRep(t *testing.T, name string, cases []repCase) bool {
+	t.Helper()
+	if name != "" {
+		name += ": "
+	}
+	ok := true
+	for _, c := range cases {
+		got, err := rep(c.form)
+		if err != nil {
+			t.Errorf("%s%s: %v", name, c.form, err)
+			ok = false
+		} else if got != c.want {
+			t.Errorf("%s%s: got %s, want %s", name, c.form, got, c.want)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// checkRepErrors evaluates the forms of cases in turn with rep and reports
+// each that does not fail with an error mentioning its want, after name
+// unless it is empty.
+func checkRepErrors(t *testing.T, name string, cases []repCase) {
+	t.Helper()
+	if name != "" {
+		name += ": "
+	}
+	for _, c := range cases {
+		if _, err := rep(c.form); err == nil || !strings.Contains(errorString(err), c.want) {
+			t.Errorf("%s%s: got %v, want an error mentioning %s", name, c.form, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+
+	. "github.com/jdugan1024/jdgo/types"
+)
+
+// TestSets checks that sets compare and hash by their members, and that the
+// set algebra is in the set namespace, where require can find it.
+func TestSets(t *testing.T) {
+	initEnv()
+	SetCurrentNamespace(CreateNamespace("test.sets"))
+	defer RemoveNamespace("test.sets")
+	checkRep(t, "", []repCase{
+		{`(= #{1 2 3} #{3 2 1})`, "true"},
+		{`(= #{1 2} #{1 2 3})`, "false"},
+		{`(= #{1} [1])`, "false"},
+		{`(= #{[1 2]} #{'(1 2)})`, "true"},
+		{`(get {#{1 2 3} :v} (set [3 1 2]))`, ":v"},
+		{`(get {#{:a :b} "found"} #{:b :a})`, `"found"`},
+		{`(contains? #{#{1 2}} #{2 1})`, "true"},
+		{`(count (conj #{1 2} 2 1))`, "2"},
+		{`(disj #{1 2 3} 2)`, "#{1 3}"},
+
+		{`(set/union #{1 2} #{2 3} nil #{4})`, "#{1 2 3 4}"},
+		{`(set/union)`, "#{}"},
+		{`(set/intersection #{1 2 3} #{2 3 4} #{3 2})`, "#{2 3}"},
+		{`(set/intersection #{1 2} nil)`, "#{}"},
+		{`(set/difference #{1 2 3} #{2} #{3 4})`, "#{1}"},
+		{`(set/subset? #{1 2} #{1 2 3})`, "true"},
+		{`(set/subset? #{1 4} #{1 2 3})`, "false"},
+		{`(set/superset? #{1 2 3} #{})`, "true"},
+		{`(set/select (fn* [x] (> x 1)) #{1 2 3})`, "#{2 3}"},
+
+		{`(require '[set :as s])`, "nil"},
+		{`(s/union #{1} #{2})`, "#{1 2}"},
+		{`(require '[set :refer [difference]])`, "nil"},
+		{`(difference #{1 2} #{1})`, "#{2}"},
+	})
+
+	checkRepErrors(t, "", []repCase{
+		{`(union #{1} #{2})`, "'union' not found"},
+		{`(set/intersection)`, "set/intersection"},
+		{`(set/union #{1} [2])`, "set/union"},
+	})
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/jdugan1024/jdgo/types"
+)
+
+// imageTestNamespace makes a new namespace current, for a test of images,
+// which hold every namespace, to define what it saves in.  Calling the
+// function it returns removes the namespace, so that later images do not
+// hold it.
+func imageTestNamespace() func() {
+	RemoveNamespace("image.test")
+	SetCurrentNamespace(CreateNamespace("image.test"))
+	return func() { RemoveNamespace("image.test") }
+}
+
+// TestImage saves an image with each engine and loads it with another,
+// after changing the definitions it holds.
+func TestImage(t *testing.T) {
+	defer useEngine(engines[1])
+	for i, e := range engines {
+		other := engines[(i+1)%len(engines)]
+		image := filepath.Join(t.TempDir(), "user.image")
+		useEngine(e)
+		initEnv()
+		defer imageTestNamespace()()
+		if !checkRep(t, e.name, []repCase{
+			{`(def! ^:dynamic *d* 1)`, "1"},
+			{`(def- secret 42)`, "42"},
+			{`(def! counter (atom 0))`, "(atom 0)"},
+			{`(def! count! (fn* [] (swap! counter + 1)))`, "#<function>"},
+			{`(def! odd (let* [even? (fn* [n] (if (= n 0) true (odd? (- n 1))))
+			                   odd? (fn* [n] (if (= n 0) false (even? (- n 1))))]
+			              odd?))`, "#<function>"},
+			{`(def! adder (let* [n 10] (fn* [x] (+ x n secret))))`, "#<function>"},
+			{`(def! plus (with-meta + {:doc "adds"}))`, "+"},
+			{`(defmacro! unless (fn* [c x] (list 'if c nil x)))`, "#<function>"},
+			{`(deftype Cell [v])`, "image.test.Cell"},
+			{`(def! cell (->Cell 5))`, "#image.test.Cell[5]"},
+			{`(count!)`, "1"},
+			{`(save-image "` + image + `")`, "nil"},
+			{`(do (def! *d* 2) (def! counter nil) (def! odd nil) (def! adder nil) (def! unless nil) (def! Cell nil))`, "nil"},
+		}) {
+			t.FailNow()
+		}
+
+		useEngine(other)
+		checkRep(t, e.name+" to "+other.name, []repCase{
+			{`(load-image "` + image + `")`, "nil"},
+			{`(count!)`, "2"},
+			{`@counter`, "2"},
+			{`(odd 7)`, "true"},
+			{`(adder 1)`, "53"},
+			{`[(plus 1 2) (meta plus)]`, `[3 {:doc "adds"}]`},
+			{`(unless false 7)`, "7"},
+			{`[(.-v cell) (Cell? cell) (= cell cell) (= cell (->Cell 5))]`, "[5 true true false]"},
+			{`[*d* (binding [*d* 3] *d*)]`, "[1 3]"},
+		})
+	}
+}
+
+// TestImageMissingBuiltin checks that an image using a builtin the
+// interpreter does not have is not loaded.
+func TestImageMissingBuiltin(t *testing.T) {
+	initEnv()
+	defer imageTestNamespace()()
+	image := filepath.Join(t.TempDir(), "user.image")
+	for _, form := range []string{
+		`(def! head first)`,
+		`(save-image "` + image + `")`,
+		`(def! head nil)`,
+	} {
+		if _, err := rep(form); err != nil {
+			t.Fatalf("%s: %v", form, err)
+		}
+	}
+
+	first, _ := coreEnv.Lookup(NewSymbol("first"))
+	coreEnv.Set(NewSymbol("first"), NilValue)
+	defer coreEnv.Set(NewSymbol("first"), first)
+	_, err := rep(`(load-image "` + image + `")`)
+	if err == nil || !strings.Contains(err.Error(), "builtins that this interpreter does not have: [first]") {
+		t.Fatalf("got %v, want an error naming first", err)
+	}
+	if got, _ := rep(`head`); got != "nil" {
+		t.Errorf("head is %s after a failed load, want nil", got)
+	}
+}
+
+// TestImageLeavesOutUnsavable checks that definitions of protocols,
+// multimethods and lazy sequences, or of values holding them, are left out
+// of an image with a warning each, and that the rest of the image, and the
+// refers to what it holds, load.
+func TestImageLeavesOutUnsavable(t *testing.T) {
+	initEnv()
+	defer imageTestNamespace()()
+	defer RemoveNamespace("image.test2")
+	var warnings strings.Builder
+	imageWarnings = &warnings
+	defer func() { imageWarnings = os.Stderr }()
+	image := filepath.Join(t.TempDir(), "user.image")
+	for _, form := range []string{
+		`(defprotocol Shape (area [this]))`,
+		`(defmulti kind (fn* [x] x))`,
+		// rep prints what it evaluates, which would realize (range).
+		`(do (def! naturals (range)) nil)`,
+		`(do (def! holder (atom (range))) nil)`,
+		`(def! inner [1 2])`,
+		// inner is saved for b-bad first, which is then left out.
+		`(def! b-bad [inner Shape])`,
+		`(def! c-good [inner])`,
+		`(def! kept 1)`,
+		`(in-ns 'image.test2)`,
+		`(refer 'image.test :only '[area kept])`,
+		`(save-image "` + image + `")`,
+	} {
+		if _, err := rep(form); err != nil {
+			t.Fatalf("%s: %v", form, err)
+		}
+	}
+	want := []string{
+		"not saving image.test/Shape: cannot save protocol Shape",
+		"not saving image.test/area: cannot save area, which is not a builtin",
+		"not saving image.test/b-bad: cannot save protocol Shape",
+		"not saving image.test/holder: cannot save a lazy sequence, which may be infinite",
+		"not saving image.test/kind: cannot save a multimethod",
+		"not saving image.test/naturals: cannot save a lazy sequence, which may be infinite",
+	}
+	if got := strings.Split(strings.TrimSpace(warnings.String()), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got warnings\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	RemoveNamespace("image.test")
+	RemoveNamespace("image.test2")
+	SetCurrentNamespace(CreateNamespace("user"))
+	checkRep(t, "", []repCase{
+		{`(load-image "` + image + `")`, "nil"},
+		{`[image.test/kept image.test/inner image.test/c-good]`, "[1 [1 2] [[1 2]]]"},
+		{`(in-ns 'image.test2)`, "#<namespace image.test2>"},
+		{`kept`, "1"},
+	})
+	for _, form := range []string{`area`, `image.test/naturals`, `image.test/Shape`} {
+		if _, err := rep(form); err == nil {
+			t.Errorf("%s was loaded", form)
+		}
+	}
+}
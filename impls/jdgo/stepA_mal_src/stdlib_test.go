@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/jdugan1024/jdgo/core"
+)
+
+// TestStdlib requires each namespace of the library built into the binary,
+// with nothing on the load path, and uses a definition from it.
+func TestStdlib(t *testing.T) {
+	initEnv()
+	core.ModuleLoader.SetPath()
+	checkRep(t, "", []repCase{
+		{`(require '[mal.threading :refer :all])`, "nil"},
+		{`(-> 1 (+ 2) (* 3))`, "9"},
+		{`(require '[mal.trivial :as t])`, "nil"},
+		{`(t/inc 1)`, "2"},
+		{`(require '[mal.memoize :refer [memoize]])`, "nil"},
+		{`((memoize (fn* [x] (* x x))) 4)`, "16"},
+		{`(require 'mal.equality)`, "nil"},
+		{`(loaded-libs)`, "#{mal.equality mal.memoize mal.threading mal.trivial}"},
+	})
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"fmt"
+
+	. "github.com/jdugan1024/jdgo/types"
+)
+
+// runVM evaluates ast in env, with the dynamic vars bound by b, with the
+// bytecode compiler and machine.
+func runVM(ast MalType, env *Env, b *Bindings) (MalType, error) {
+	return topLevel(ast, env, b, runBytecode)
+}
+
+func runBytecode(ast MalType, env *Env, b *Bindings) (MalType, error) {
+	fn := &fnScope{}
+	p := &vmProto{env: env}
+	if err := p.assemble(ast, scope{fn: fn}); err != nil {
+		return nil, err
+	}
+	p.nslots = fn.nslots
+	m := &machine{cur: activation{proto: p, fr: &frame{slots: make([]MalType, p.nslots)}}, dyn: b}
+	return m.execute()
+}
+
+// vmLambda is the Code of a closure compiled to bytecode: its proto and the
+// frame it was made in.  The machine only uses the slots of frames.
+type vmLambda struct {
+	proto *vmProto
+	env   *frame
+}
+
+func vmLambdaOf(f MalType) *vmLambda {
+	if c, ok := f.(*Closure); ok {
+		l, _ := c.Code().(*vmLambda)
+		return l
+	}
+	return nil
+}
+
+// Call runs l on a machine of its own, with the dynamic var bindings b.
+// Calls between compiled functions are made by the machine running them, so
+// this is only used when a function is called from Go, by map for example.
+func (l *vmLambda) Call(b *Bindings, args ...MalType) (MalType, error) {
+	m := &machine{dyn: b}
+	if err := m.enter(l, args, 0); err != nil {
+		return nil, err
+	}
+	return m.execute()
+}
+
+// activation is a call being run by a machine: the code, the next
+// instruction in it, the frame of the call and the position on the stack of
+// the function called, where its result goes.
+type activation struct {
+	proto *vmProto
+	pc    int
+	fr    *frame
+	base  int
+}
+
+// handler is the catch* clause of a try* being run: where its code is, and
+// the state of the machine to go back to before running it.
+type handler struct {
+	catch    int
+	sp       int
+	calls    int
+	bindings int
+}
+
+// machine runs bytecode.  It holds a stack of values, the calls suspended
+// by the current one, cur, the try* forms being run and the dynamic var
+// bindings in effect, dyn, which the functions it calls are called with.
+type machine struct {
+	stack    []MalType
+	calls    []activation
+	cur      activation
+	handlers []handler
+	dyn      *Bindings
+	// unbound holds, for each opBind that has not been undone, the
+	// dynamic bindings it replaced.
+	unbound []*Bindings
+}
+
+// enter starts a call of l with args, whose result goes to the stack at
+// base.
+func (m *machine) enter(l *vmLambda, args []MalType, base int) error {
+	p := l.proto
+	if err := p.compile(); err != nil {
+		return err
+	}
+	slots, err := bindArgs(args, len(p.params), p.rest != nil, p.nslots)
+	if err != nil {
+		return err
+	}
+	m.cur = activation{proto: p, fr: &frame{slots: slots, outer: l.env}, base: base}
+	return nil
+}
+
+// execute runs the current call to the end, and returns its result.  An
+// error is caught by the innermost try* being run, if any.
+func (m *machine) execute() (MalType, error) {
+	for {
+		r, err := m.run()
+		if err == nil {
+			return r, nil
+		}
+		if !m.unwind(err) {
+			m.unbind(0)
+			return nil, err
+		}
+	}
+}
+
+// unwind goes back to the state of the machine when the innermost try*
+// began, and jumps to its catch* clause with the value err throws.  It
+// reports false if there is no try*.
+func (m *machine) unwind(err error) bool {
+	if len(m.handlers) == 0 {
+		return false
+	}
+	h := m.handlers[len(m.handlers)-1]
+	m.handlers = m.handlers[:len(m.handlers)-1]
+	m.unbind(h.bindings)
+	if len(m.calls) > h.calls {
+		m.cur = m.calls[h.calls]
+		m.calls = m.calls[:h.calls]
+	}
+	m.stack = append(m.stack[:h.sp], thrown(err))
+	m.cur.pc = h.catch
+	return true
+}
+
+// unbind undoes the opBinds made since n of them were in effect.
+func (m *machine) unbind(n int) {
+	if len(m.unbound) > n {
+		m.dyn = m.unbound[n]
+		m.unbound = m.unbound[:n]
+	}
+}
+
+func (m *machine) push(v MalType) {
+	m.stack = append(m.stack, v)
+}
+
+func (m *machine) pop() MalType {
+	v := m.stack[len(m.stack)-1]
+	m.stack = m.stack[:len(m.stack)-1]
+	return v
+}
+
+// popN pops n values into a new slice.
+func (m *machine) popN(n int) []MalType {
+	r := make([]MalType, n)
+	copy(r, m.stack[len(m.stack)-n:])
+	m.stack = m.stack[:len(m.stack)-n]
+	return r
+}
+
+// ret ends the current call with the result r, and reports whether it was
+// the outermost one.
+func (m *machine) ret(r MalType) (MalType, bool) {
+	m.stack = m.stack[:m.cur.base]
+	if len(m.calls) == 0 {
+		return r, true
+	}
+	m.cur = m.calls[len(m.calls)-1]
+	m.calls = m.calls[:len(m.calls)-1]
+	m.push(r)
+	return nil, false
+}
+
+// run runs instructions until the outermost call returns or there is an
+// error.
+func (m *machine) run() (MalType, error) {
+	p, code, pc, fr := m.cur.proto, m.cur.proto.code, m.cur.pc, m.cur.fr
+	// The state of the call changes when one starts or returns.
+	reload := func() {
+		p, code, pc, fr = m.cur.proto, m.cur.proto.code, m.cur.pc, m.cur.fr
+	}
+	for {
+		op := opcode(code[pc])
+		arg := 0
+		if pc+2 < len(code) {
+			arg = int(code[pc+1])<<8 | int(code[pc+2])
+		}
+		pc++
+		switch op {
+		case opConst:
+			pc += 2
+			m.push(p.consts[arg])
+		case opLocal:
+			pc += 2
+			m.push(fr.slots[arg])
+		case opUpval:
+			slot := int(code[pc+2])<<8 | int(code[pc+3])
+			pc += 4
+			outer := fr
+			for i := 0; i < arg; i++ {
+				outer = outer.outer
+			}
+			m.push(outer.slots[slot])
+		case opSetLocal:
+			pc += 2
+			fr.slots[arg] = m.pop()
+		case opJumpIfSet:
+			pc += 2
+			if m.stack[len(m.stack)-1] != nil {
+				pc = arg
+			} else {
+				m.pop()
+			}
+		case opGlobal:
+			pc += 2
+			v, err := p.env.FindBound(p.consts[arg].(*Symbol), m.dyn)
+			if err != nil {
+				return nil, err
+			}
+			m.push(v)
+		case opDef:
+			flags := int(code[pc+2])<<8 | int(code[pc+3])
+			pc += 4
+			key := p.consts[arg].(*Symbol)
+			p.env.Define(key, m.stack[len(m.stack)-1], flags&defDynamic != 0)
+			if ns := p.env.Namespace(); ns != nil && flags&defPrivate != 0 {
+				ns.MarkPrivate(key)
+			}
+		case opDefMacro:
+			pc += 2
+			v := m.pop()
+			f, ok := v.(*Closure)
+			if !ok {
+				return nil, fmt.Errorf("defmacro! value is not a function: %s", v.Print(true))
+			}
+			macro := f.AsMacro()
+			p.env.Set(p.consts[arg].(*Symbol), macro)
+			m.push(macro)
+		case opPop:
+			m.pop()
+		case opJump:
+			pc = arg
+		case opJumpIfFalse:
+			pc += 2
+			if !Truthy(m.pop()) {
+				pc = arg
+			}
+		case opClosure:
+			pc += 2
+			m.push(NewCompiledClosure(&vmLambda{p.protos[arg], fr}))
+		case opCall, opTailCall:
+			pc += 2
+			fi := len(m.stack) - arg - 1
+			f := m.stack[fi]
+			if l := vmLambdaOf(f); l != nil {
+				m.cur.pc = pc
+				base := fi
+				if op == opTailCall {
+					base = m.cur.base
+				} else {
+					m.calls = append(m.calls, m.cur)
+				}
+				if err := m.enter(l, m.stack[fi+1:], base); err != nil {
+					return nil, err
+				}
+				m.stack = m.stack[:base]
+				reload()
+				continue
+			}
+			args := m.popN(arg)
+			m.pop()
+			r, err := call(m.dyn, f, args)
+			if err != nil {
+				return nil, err
+			}
+			if op == opCall {
+				m.push(r)
+				continue
+			}
+			if r, done := m.ret(r); done {
+				return r, nil
+			}
+			reload()
+		case opReturn:
+			if r, done := m.ret(m.pop()); done {
+				return r, nil
+			}
+			reload()
+		case opVector:
+			pc += 2
+			m.push(NewVector(m.popN(arg)...))
+		case opHashMap:
+			pc += 2
+			m.push(NewHashMap(m.popN(2 * arg)))
+		case opSet:
+			pc += 2
+			m.push(NewSet(m.popN(arg)...))
+		case opMacroexpand:
+			pc += 2
+			v, err := macroexpand(p.consts[arg], p.env)
+			if err != nil {
+				return nil, err
+			}
+			m.push(v)
+		case opTry:
+			pc += 2
+			m.handlers = append(m.handlers, handler{arg, len(m.stack), len(m.calls), len(m.unbound)})
+		case opEndTry:
+			if err := forceFirst(m.stack[len(m.stack)-1]); err != nil {
+				return nil, err
+			}
+			m.handlers = m.handlers[:len(m.handlers)-1]
+		case opBind:
+			pc += 2
+			syms := p.consts[arg].(*Vector).Items()
+			vals := make(map[*Var]MalType, len(syms))
+			for i, v := range m.popN(len(syms)) {
+				dv, err := p.env.Var(syms[i].(*Symbol))
+				if err != nil {
+					return nil, err
+				}
+				vals[dv] = v
+			}
+			m.unbound = append(m.unbound, m.dyn)
+			m.dyn = m.dyn.Bind(vals)
+		case opUnbind:
+			m.unbind(len(m.unbound) - 1)
+		default:
+			return nil, fmt.Errorf("bad opcode %d", op)
+		}
+	}
+}
+
+func (l *vmLambda) Source() ClosureSource {
+	p := l.proto
+	return closureSource(p.params, p.rest, p.form, p.env, p.scope, l.env)
+}
+
+// makeVMLambda is makeLambda for the bytecode machine.
+func makeVMLambda(params, body MalType, env *Env, names []*Symbol) (*Closure, func(int, MalType), error) {
+	p := &vmProto{env: env, form: body}
+	var err error
+	if p.params, p.rest, err = parseParams(params); err != nil {
+		return nil, nil, err
+	}
+	var fr *frame
+	p.scope, fr = capturedScope(names)
+	return NewCompiledClosure(&vmLambda{p, fr}), func(i int, v MalType) { fr.slots[i] = v }, nil
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	. "github.com/jdugan1024/jdgo/types"
+)
+
+// assembleIn compiles src to bytecode in env, as runBytecode does.
+func assembleIn(t *testing.T, env *Env, src string) *vmProto {
+	t.Helper()
+	ast, err := READ(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := &fnScope{}
+	p := &vmProto{env: env}
+	if err := p.assemble(ast, scope{fn: fn}); err != nil {
+		t.Fatalf("%s: %v", src, err)
+	}
+	p.nslots = fn.nslots
+	return p
+}
+
+// newMachine returns a machine about to run p, as runBytecode makes one.
+func newMachine(p *vmProto) *machine {
+	return &machine{cur: activation{proto: p, fr: &frame{slots: make([]MalType, p.nslots)}}}
+}
+
+// reassemble turns a listing made by disassemble back into code.
+func reassemble(t *testing.T, listing string) []byte {
+	t.Helper()
+	var code []byte
+	for _, line := range strings.Split(strings.TrimSpace(listing), "\n") {
+		fields := strings.Fields(line)
+		if at, err := strconv.Atoi(fields[0]); err != nil || at != len(code) {
+			t.Fatalf("%q: offset is not %d", line, len(code))
+		}
+		op := -1
+		for i, o := range ops {
+			if o.name == fields[1] {
+				op = i
+			}
+		}
+		if op < 0 || len(fields) != 2+ops[op].operands {
+			t.Fatalf("%q: bad instruction", line)
+		}
+		code = append(code, byte(op))
+		for _, f := range fields[2:] {
+			n, err := strconv.Atoi(f)
+			if err != nil {
+				t.Fatalf("%q: %v", line, err)
+			}
+			code = append(code, byte(n>>8), byte(n))
+		}
+	}
+	return code
+}
+
+// TestDisassemble checks the code generated for each kind of form, and that
+// disassembling it loses nothing.  A path of indexes into protos picks the
+// function whose code is checked, each compiled as it would be when first
+// called.
+func TestDisassemble(t *testing.T) {
+	initEnv()
+	for _, c := range []struct {
+		form string
+		path []int
+		want string
+	}{
+		{`(if x 1 2)`, nil, `
+0 global 0
+3 jumpiffalse 12
+6 const 1
+9 jump 15
+12 const 2
+15 return`},
+		// The call in tail position replaces the current one; the call
+		// to = does not.
+		{`(fn* [n] (if (= n 0) n (f (- n 1))))`, []int{0}, `
+0 global 0
+3 local 0
+6 const 1
+9 call 2
+12 jumpiffalse 21
+15 local 0
+18 jump 39
+21 global 2
+24 global 3
+27 local 0
+30 const 4
+33 call 2
+36 tailcall 1
+39 return`},
+		// A parameter of an enclosing function is an upvalue.
+		{`(fn* [a] (fn* [b] (+ a b)))`, nil, `
+0 closure 0
+3 return`},
+		{`(fn* [a] (fn* [b] (+ a b)))`, []int{0, 0}, `
+0 global 0
+3 upval 1 0
+8 local 0
+11 tailcall 2
+14 return`},
+		// The catch* clause starts with the value thrown on the stack.
+		{`(try* (throw 1) (catch* e e))`, nil, `
+0 try 16
+3 global 0
+6 const 1
+9 call 1
+12 endtry
+13 jump 22
+16 setlocal 0
+19 local 0
+22 return`},
+		{`(binding [*d* 1] *d*)`, nil, `
+0 const 0
+3 bind 1
+6 global 2
+9 unbind
+10 return`},
+		// A function in a let* made before x is set uses the global x
+		// until then.
+		{`(let* [f (fn* [] x) x 1] (f))`, []int{0}, `
+0 upval 1 1
+5 jumpifset 11
+8 global 0
+11 return`},
+	} {
+		p := assembleIn(t, coreEnv, c.form)
+		for _, i := range c.path {
+			p = p.protos[i]
+			if err := p.compile(); err != nil {
+				t.Fatalf("%s: %v", c.form, err)
+			}
+		}
+		got := p.disassemble()
+		if want := strings.TrimPrefix(c.want, "\n") + "\n"; got != want {
+			t.Errorf("%s %v: got\n%swant\n%s", c.form, c.path, got, want)
+		}
+		if code := reassemble(t, got); string(code) != string(p.code) {
+			t.Errorf("%s %v: reassembled to %v, want %v", c.form, c.path, code, p.code)
+		}
+	}
+}
+
+// TestVMTailCalls checks that a call in tail position jumps to the function
+// called instead of suspending the current call, and that other calls do
+// suspend it.
+func TestVMTailCalls(t *testing.T) {
+	useEngine(engines[2])
+	defer useEngine(engines[1])
+	initEnv()
+	for _, form := range []string{
+		`(def! down (fn* [n] (if (= n 0) n (down (- n 1)))))`,
+		`(def! sum (fn* [n] (if (= n 0) n (+ n (sum (- n 1))))))`,
+	} {
+		if _, err := rep(form); err != nil {
+			t.Fatalf("%s: %v", form, err)
+		}
+	}
+	run := func(form, want string) *machine {
+		t.Helper()
+		m := newMachine(assembleIn(t, CurrentNamespace().Env(), form))
+		r, err := m.execute()
+		if err != nil {
+			t.Fatalf("%s: %v", form, err)
+		}
+		if got := r.Print(true); got != want {
+			t.Errorf("%s: got %s, want %s", form, got, want)
+		}
+		return m
+	}
+	// Only the call from the top level is suspended.
+	if m := run(`[(down 10000)]`, "[0]"); cap(m.calls) > 1 {
+		t.Errorf("(down 10000) suspended %d calls at once, want 1", cap(m.calls))
+	}
+	if m := run(`[(sum 100)]`, "[5050]"); cap(m.calls) < 101 {
+		t.Errorf("(sum 100) suspended %d calls at once, want 101", cap(m.calls))
+	}
+}
+
+// TestVMUpvalues checks that each closure keeps the frames it was made in,
+// after the calls that made them have returned.
+func TestVMUpvalues(t *testing.T) {
+	useEngine(engines[2])
+	defer useEngine(engines[1])
+	initEnv()
+	checkRep(t, "", []repCase{
+		{`(let* [mk (fn* [a] (fn* [b] (fn* [] [a b]))) f ((mk 1) 2) g ((mk 3) 4)] [(f) (g)])`, "[[1 2] [3 4]]"},
+		{`((try* (throw 5) (catch* e (fn* [] e))))`, "5"},
+		{`(((fn* [& xs] (fn* [] (count xs))) 1 2 3))`, "3"},
+		// A closure defined as a global from inside a call keeps it.
+		{`(do ((fn* [a] (def! from-call (fn* [] a))) 4) (from-call))`, "4"},
+	})
+}
+
+// TestVMStackAfterTry checks that catching an error leaves the machine's
+// stack as deep as it was when the try* began, whether the error was
+// thrown in the try* itself or by a function it called, and undoes the
+// calls and bindings made since.
+func TestVMStackAfterTry(t *testing.T) {
+	useEngine(engines[2])
+	defer useEngine(engines[1])
+	initEnv()
+	SetCurrentNamespace(CreateNamespace("test.vm"))
+	defer RemoveNamespace("test.vm")
+	env := CurrentNamespace().Env()
+	if _, err := rep(`(def! ^:dynamic *d* 0)`); err != nil {
+		t.Fatal(err)
+	}
+	// depth gives the depth of the stack of the machine being tested.
+	var m *machine
+	env.Set(NewSymbol("depth"), NewFunction("depth", func(args ...MalType) (MalType, error) {
+		return NewIntFromInt(len(m.stack)), nil
+	}))
+	for _, c := range []struct{ form, want string }{
+		{`[(depth) (depth)]`, "[0 1]"},
+		{`[(try* (+ 1 2 (throw 3)) (catch* e e)) (depth)]`, "[3 1]"},
+		{`[(try* ((fn* [] (+ 1 ((fn* [] (throw 2)))))) (catch* e e)) (depth)]`, "[2 1]"},
+		{`[(try* (binding [*d* 5] (throw *d*)) (catch* e [e *d*])) (depth)]`, "[[5 0] 1]"},
+		{`[(try* [1 (try* (throw 2) (catch* e (throw (+ e 1))))] (catch* e e)) (depth)]`, "[3 1]"},
+		{`[1 (+ 1 ((fn* [] (try* (+ 5 (throw 2)) (catch* e (depth)))))) (depth)]`, "[1 4 2]"},
+	} {
+		m = newMachine(assembleIn(t, env, c.form))
+		r, err := m.execute()
+		if err != nil {
+			t.Errorf("%s: %v", c.form, err)
+			continue
+		}
+		if got := r.Print(true); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.form, got, c.want)
+		}
+		if len(m.stack) != 0 || len(m.calls) != 0 || len(m.handlers) != 0 || len(m.unbound) != 0 {
+			t.Errorf("%s: left %d values, %d calls, %d handlers and %d bindings", c.form,
+				len(m.stack), len(m.calls), len(m.handlers), len(m.unbound))
+		}
+	}
+}
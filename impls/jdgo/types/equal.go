@@ -0,0 +1,95 @@
+package types
+
+import (
+	"hash/fnv"
+	"math"
+	"reflect"
+)
+
+// Every MalType has an Equal and a Hash method, and hash maps and sets rely
+// on the contract between them: values that are Equal must have the same
+// Hash.  Equality is structural and ignores metadata:
+//
+//   - lists, vectors and lazy sequences with equal items are equal to each
+//     other, whatever their types;
+//   - an Int and a Float are equal when they hold the same number;
+//   - maps and sets are equal when they hold equal entries, in any order;
+//   - symbols, keywords, nil and booleans are unique, and functions, atoms
+//     and reduced values are only equal to themselves.
+
+// Equal reports whether a and b are equal; it is a.Equal(b).
+func Equal(a, b MalType) bool {
+	return a.Equal(b)
+}
+
+// Hash returns the hash of v; it is v.Hash().
+func Hash(v MalType) uint64 {
+	return v.Hash()
+}
+
+// isSequential reports whether v is one of the sequential types, which can
+// all be equal to each other.
+func isSequential(v MalType) bool {
+	switch v.(type) {
+	case *List, *Vector, *Cons, *LazySeq:
+		return true
+	}
+	return false
+}
+
+func seqEqual(a, b MalType) bool {
+	if !isSequential(b) {
+		return false
+	}
+	as, _ := Sequence(a)
+	bs, _ := Sequence(b)
+	if len(as) != len(bs) {
+		return false
+	}
+	for i := range as {
+		if !as[i].Equal(bs[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func seqHash(v MalType) uint64 {
+	items, _ := Sequence(v)
+	h := uint64(17)
+	for _, item := range items {
+		h = h*31 + item.Hash()
+	}
+	return mix(h)
+}
+
+// identityHash hashes v, which is compared by identity, by its address.
+func identityHash(v MalType) uint64 {
+	return mix(uint64(reflect.ValueOf(v).Pointer()))
+}
+
+// floatHash hashes f the same as the Int holding the same number, if there
+// is one.
+func floatHash(f float64) uint64 {
+	if f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+		return mix(uint64(int64(f)))
+	}
+	return mix(math.Float64bits(f))
+}
+
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// mix scrambles the bits of h so that small integers spread over the
+// buckets.
+func mix(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
@@ -0,0 +1,153 @@
+package types
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// randValue returns a random value nested at most depth levels deep.
+func randValue(r *rand.Rand, depth int) MalType {
+	n := 7
+	if depth > 0 {
+		n = 11
+	}
+	switch r.Intn(n) {
+	case 0:
+		return NilValue
+	case 1:
+		return NewBoolean(r.Intn(2) == 0)
+	case 2:
+		return NewIntFromInt(r.Intn(20) - 10)
+	case 3:
+		return NewFloat(float64(r.Intn(40)-20) / 4)
+	case 4:
+		return NewString(string(rune('a' + r.Intn(5))))
+	case 5:
+		return NewKeyword(string(rune('a' + r.Intn(5))))
+	case 6:
+		return NewSymbol(string(rune('a' + r.Intn(5))))
+	case 7:
+		return NewList(randItems(r, depth-1)...)
+	case 8:
+		return NewVector(randItems(r, depth-1)...)
+	case 9:
+		return NewHashMap(randItems(r, depth-1))
+	}
+	return NewSet(randItems(r, depth-1)...)
+}
+
+func randItems(r *rand.Rand, depth int) []MalType {
+	items := make([]MalType, 2*r.Intn(4))
+	for i := range items {
+		items[i] = randValue(r, depth)
+	}
+	return items
+}
+
+// rebuild returns a copy of v that is built differently but must be equal
+// to it: lists and vectors are swapped, integral numbers change between Int
+// and Float, maps and sets are filled in a shuffled order and everything
+// gets metadata.
+func rebuild(r *rand.Rand, v MalType) MalType {
+	meta := NewString("meta")
+	switch t := v.(type) {
+	case *Int:
+		return NewFloat(float64(t.AsInt()))
+	case *Float:
+		if f := t.Value(); f == float64(int(f)) {
+			return NewIntFromInt(int(f))
+		}
+	case *String:
+		if !t.IsKeyword() {
+			return NewString(t.value)
+		}
+	case *List:
+		return NewVector(rebuildItems(r, t.Items())...).WithMeta(meta)
+	case *Vector:
+		return NewList(rebuildItems(r, t.Items())...).WithMeta(meta)
+	case *HashMap:
+		entries := append([]*mapEntry(nil), t.entries()...)
+		r.Shuffle(len(entries), func(i, j int) { entries[i], entries[j] = entries[j], entries[i] })
+		forms := []MalType{}
+		for _, e := range entries {
+			forms = append(forms, rebuild(r, e.key), rebuild(r, e.value))
+		}
+		return NewHashMap(forms).WithMeta(meta)
+	case *Set:
+		items := rebuildItems(r, t.Items())
+		r.Shuffle(len(items), func(i, j int) { items[i], items[j] = items[j], items[i] })
+		return NewSet(items...).WithMeta(meta)
+	}
+	return v
+}
+
+func rebuildItems(r *rand.Rand, items []MalType) []MalType {
+	copied := make([]MalType, len(items))
+	for i, v := range items {
+		copied[i] = rebuild(r, v)
+	}
+	return copied
+}
+
+func TestEqualValuesHashEqually(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		a := randValue(r, 3)
+		b := rebuild(r, a)
+		if !a.Equal(a) {
+			t.Fatalf("%s is not equal to itself", a.Print(true))
+		}
+		if !a.Equal(b) || !b.Equal(a) {
+			t.Fatalf("%s and %s are not equal", a.Print(true), b.Print(true))
+		}
+		if a.Hash() != b.Hash() {
+			t.Fatalf("%s and %s are equal but hash differently", a.Print(true), b.Print(true))
+		}
+	}
+}
+
+func TestEqualIsSymmetric(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	for i := 0; i < 5000; i++ {
+		a, b := randValue(r, 2), randValue(r, 2)
+		if a.Equal(b) != b.Equal(a) {
+			t.Fatalf("%s and %s: Equal is not symmetric", a.Print(true), b.Print(true))
+		}
+		if a.Equal(b) && a.Hash() != b.Hash() {
+			t.Fatalf("%s and %s are equal but hash differently", a.Print(true), b.Print(true))
+		}
+	}
+}
+
+func TestEqual(t *testing.T) {
+	point := NewRecordType("user", "Point", []*String{NewKeyword("x")})
+	p1, _ := NewRecord(point, NewIntFromInt(1))
+	p2, _ := NewRecord(point, NewFloat(1))
+	lazy := NewLazySeq(func() (MalType, error) {
+		return NewCons(NewIntFromInt(1), NewList(NewIntFromInt(2))), nil
+	})
+	for _, c := range []struct {
+		a, b  MalType
+		equal bool
+	}{
+		{NewIntFromInt(1), NewFloat(1), true},
+		{NewIntFromInt(1), NewFloat(1.5), false},
+		{NewString("a"), NewKeyword("a"), false},
+		{NewString("a"), NewSymbol("a"), false},
+		{NilValue, FalseValue, false},
+		{NilValue, NewList(), false},
+		{NewList(), NewVector(), true},
+		{lazy, NewVector(NewIntFromInt(1), NewIntFromInt(2)), true},
+		{NewList(NewIntFromInt(1)), NewSet(NewIntFromInt(1)), false},
+		{NewHashMap(nil), NewSet(), false},
+		{NewSet(NewIntFromInt(1)), NewSet(NewFloat(1)), true},
+		{p1, p2, true},
+		{p1, p1.Assoc(NewKeyword("y"), NilValue), false},
+		{p1, NewHashMap([]MalType{NewKeyword("x"), NewIntFromInt(1)}), false},
+		{p1, NewRecordFromMap(NewRecordType("user", "Point", point.Fields()), p1.Map()), false},
+	} {
+		if got := c.a.Equal(c.b); got != c.equal {
+			t.Errorf("%s = %s: got %v, want %v", c.a.Print(true), c.b.Print(true), got, c.equal)
+		}
+	}
+}
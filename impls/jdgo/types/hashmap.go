@@ -0,0 +1,370 @@
+package types
+
+import (
+	"math/bits"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+type mapEntry struct {
+	key   MalType
+	value MalType
+	seq   int
+}
+
+// HashMap is a persistent hash array mapped trie.  Keys are placed by Hash
+// and matched with Equal, so any value can be used as a key.  Assoc and
+// Dissoc copy only the nodes on the path to the changed entry and share the
+// rest with the original, which is never modified.  Each entry remembers
+// when its key was first added so that printing and iteration are stable;
+// see SetMapOrder.  The entries in that order are worked out the first time
+// they are needed and kept, so that walking a map with First and Rest does
+// not sort it again at each step.
+type HashMap struct {
+	root    *hnode
+	count   int
+	nextSeq int
+	meta    MalType
+	ordered atomic.Pointer[orderedEntries]
+}
+
+// orderedEntries holds the entries of a map in the given order.
+type orderedEntries struct {
+	order   MapOrder
+	entries []*mapEntry
+}
+
+const (
+	hbits = 5
+	hmask = 1<<hbits - 1
+)
+
+// hnode is a bitmap indexed trie node.  Bit i of bitmap is set when slot i,
+// selected by the next five bits of the hash, is in use; slots holds the
+// used slots in order, each either an entry or a child node.  Entries whose
+// hashes are identical end up together in a collision node, which holds
+// them in a plain list.
+type hnode struct {
+	bitmap    uint32
+	slots     []hslot
+	collision []*mapEntry
+}
+
+type hslot struct {
+	entry *mapEntry
+	child *hnode
+}
+
+func NewHashMap(forms []MalType) *HashMap {
+	hm := &HashMap{}
+
+	for i := 0; i+1 < len(forms); i += 2 {
+		hm = hm.Assoc(forms[i], forms[i+1])
+	}
+	return hm
+}
+
+func (hm *HashMap) TypeName() string { return "HashMap" }
+func (hm *HashMap) Print(readably bool) string {
+	str := []string{}
+	for _, e := range hm.entries() {
+		str = append(str, e.key.Print(readably))
+		str = append(str, e.value.Print(readably))
+	}
+	return "{" + strings.Join(str, " ") + "}"
+}
+
+func (hm *HashMap) Meta() MalType { return metaOrNil(hm.meta) }
+func (hm *HashMap) WithMeta(meta MalType) MalType {
+	r := &HashMap{root: hm.root, count: hm.count, nextSeq: hm.nextSeq, meta: meta}
+	r.ordered.Store(hm.ordered.Load())
+	return r
+}
+
+func (hm *HashMap) Length() int { return hm.count }
+
+// Equal reports whether other is a map with equal keys mapped to equal
+// values, in any order.
+func (hm *HashMap) Equal(other MalType) bool {
+	o, ok := other.(*HashMap)
+	if !ok || hm.count != o.count {
+		return false
+	}
+	equal := true
+	hm.root.each(func(e *mapEntry) {
+		if w, ok := o.Get(e.key); !ok || !e.value.Equal(w) {
+			equal = false
+		}
+	})
+	return equal
+}
+
+// Hash sums the hashes of the entries so that it does not depend on their
+// order.
+func (hm *HashMap) Hash() uint64 {
+	var h uint64
+	hm.root.each(func(e *mapEntry) {
+		h += e.key.Hash() ^ mix(e.value.Hash())
+	})
+	return mix(h)
+}
+
+func (hm *HashMap) Get(key MalType) (MalType, bool) {
+	h := Hash(key)
+	node := hm.root
+	for shift := uint(0); node != nil; shift += hbits {
+		if node.collision != nil {
+			for _, e := range node.collision {
+				if Equal(e.key, key) {
+					return e.value, true
+				}
+			}
+			return nil, false
+		}
+		bit := uint32(1) << ((h >> shift) & hmask)
+		if node.bitmap&bit == 0 {
+			return nil, false
+		}
+		slot := node.slots[node.index(bit)]
+		if slot.entry != nil {
+			if Equal(slot.entry.key, key) {
+				return slot.entry.value, true
+			}
+			return nil, false
+		}
+		node = slot.child
+	}
+	return nil, false
+}
+
+func (node *hnode) index(bit uint32) int {
+	return bits.OnesCount32(node.bitmap & (bit - 1))
+}
+
+// Assoc returns a new HashMap with key mapped to value.
+func (hm *HashMap) Assoc(key MalType, value MalType) *HashMap {
+	entry := &mapEntry{key, value, hm.nextSeq}
+	root, added := assocEntry(hm.root, 0, Hash(key), entry)
+	r := &HashMap{root: root, count: hm.count, nextSeq: hm.nextSeq, meta: hm.meta}
+	if added {
+		r.count++
+		r.nextSeq++
+	}
+	return r
+}
+
+// assocEntry returns a copy of node with entry added, or replacing the
+// entry with an equal key, and whether the entry was added.
+func assocEntry(node *hnode, shift uint, h uint64, entry *mapEntry) (*hnode, bool) {
+	if node == nil {
+		node = &hnode{}
+	}
+	if node.collision != nil {
+		r := &hnode{collision: make([]*mapEntry, len(node.collision), len(node.collision)+1)}
+		copy(r.collision, node.collision)
+		for i, e := range r.collision {
+			if Equal(e.key, entry.key) {
+				r.collision[i] = &mapEntry{e.key, entry.value, e.seq}
+				return r, false
+			}
+		}
+		r.collision = append(r.collision, entry)
+		return r, true
+	}
+
+	bit := uint32(1) << ((h >> shift) & hmask)
+	i := node.index(bit)
+	r := &hnode{bitmap: node.bitmap | bit}
+	if node.bitmap&bit == 0 {
+		r.slots = make([]hslot, 0, len(node.slots)+1)
+		r.slots = append(r.slots, node.slots[:i]...)
+		r.slots = append(r.slots, hslot{entry: entry})
+		r.slots = append(r.slots, node.slots[i:]...)
+		return r, true
+	}
+
+	r.slots = make([]hslot, len(node.slots))
+	copy(r.slots, node.slots)
+	slot := node.slots[i]
+	added := true
+	switch {
+	case slot.child != nil:
+		r.slots[i].child, added = assocEntry(slot.child, shift+hbits, h, entry)
+	case Equal(slot.entry.key, entry.key):
+		r.slots[i].entry = &mapEntry{slot.entry.key, entry.value, slot.entry.seq}
+		added = false
+	default:
+		r.slots[i] = hslot{child: splitEntries(shift+hbits, slot.entry, entry, h)}
+	}
+	return r, added
+}
+
+// splitEntries returns a node holding existing and entry, whose keys differ
+// but share the hash bits used so far.
+func splitEntries(shift uint, existing, entry *mapEntry, h uint64) *hnode {
+	eh := Hash(existing.key)
+	if shift >= 64 {
+		return &hnode{collision: []*mapEntry{existing, entry}}
+	}
+	child, _ := assocEntry(nil, shift, eh, existing)
+	child, _ = assocEntry(child, shift, h, entry)
+	return child
+}
+
+// Dissoc returns a new HashMap without key.
+func (hm *HashMap) Dissoc(key MalType) *HashMap {
+	root, removed := dissocEntry(hm.root, 0, Hash(key), key)
+	if !removed {
+		return hm
+	}
+	return &HashMap{root: root, count: hm.count - 1, nextSeq: hm.nextSeq, meta: hm.meta}
+}
+
+// dissocEntry returns a copy of node without key, or nil if it ends up
+// empty, and whether key was found.
+func dissocEntry(node *hnode, shift uint, h uint64, key MalType) (*hnode, bool) {
+	if node == nil {
+		return nil, false
+	}
+	if node.collision != nil {
+		for i, e := range node.collision {
+			if Equal(e.key, key) {
+				if len(node.collision) == 1 {
+					return nil, true
+				}
+				r := &hnode{collision: make([]*mapEntry, 0, len(node.collision)-1)}
+				r.collision = append(r.collision, node.collision[:i]...)
+				r.collision = append(r.collision, node.collision[i+1:]...)
+				return r, true
+			}
+		}
+		return node, false
+	}
+
+	bit := uint32(1) << ((h >> shift) & hmask)
+	if node.bitmap&bit == 0 {
+		return node, false
+	}
+	i := node.index(bit)
+	slot := node.slots[i]
+	var child *hnode
+	if slot.child != nil {
+		var removed bool
+		child, removed = dissocEntry(slot.child, shift+hbits, h, key)
+		if !removed {
+			return node, false
+		}
+	} else if !Equal(slot.entry.key, key) {
+		return node, false
+	}
+
+	if child != nil {
+		r := &hnode{bitmap: node.bitmap, slots: make([]hslot, len(node.slots))}
+		copy(r.slots, node.slots)
+		r.slots[i] = hslot{child: child}
+		return r, true
+	}
+	if len(node.slots) == 1 {
+		return nil, true
+	}
+	r := &hnode{bitmap: node.bitmap &^ bit, slots: make([]hslot, 0, len(node.slots)-1)}
+	r.slots = append(r.slots, node.slots[:i]...)
+	r.slots = append(r.slots, node.slots[i+1:]...)
+	return r, true
+}
+
+func (node *hnode) each(f func(e *mapEntry)) {
+	if node == nil {
+		return
+	}
+	for _, e := range node.collision {
+		f(e)
+	}
+	for _, slot := range node.slots {
+		if slot.entry != nil {
+			f(slot.entry)
+		} else {
+			slot.child.each(f)
+		}
+	}
+}
+
+// entries returns the entries in the order selected with SetMapOrder.  The
+// slice is shared and must not be modified.
+func (hm *HashMap) entries() []*mapEntry {
+	order := GetMapOrder()
+	if o := hm.ordered.Load(); o != nil && o.order == order {
+		return o.entries
+	}
+	r := make([]*mapEntry, 0, hm.count)
+	hm.root.each(func(e *mapEntry) { r = append(r, e) })
+	if order == InsertionOrder {
+		sort.Slice(r, func(i, j int) bool { return r[i].seq < r[j].seq })
+	} else {
+		sort.Slice(r, func(i, j int) bool { return Compare(r[i].key, r[j].key) < 0 })
+	}
+	hm.ordered.Store(&orderedEntries{order, r})
+	return r
+}
+
+// Keys returns the keys of the map in the order selected with SetMapOrder.
+func (hm *HashMap) Keys() []MalType {
+	entries := hm.entries()
+	r := make([]MalType, 0, len(entries))
+	for _, e := range entries {
+		r = append(r, e.key)
+	}
+	return r
+}
+
+// Vals returns the values of the map in the same order as Keys.
+func (hm *HashMap) Vals() []MalType {
+	entries := hm.entries()
+	r := make([]MalType, 0, len(entries))
+	for _, e := range entries {
+		r = append(r, e.value)
+	}
+	return r
+}
+
+// A map is a sequence of [key value] vectors.
+func (hm *HashMap) IsEmpty() (bool, error) { return hm.count == 0, nil }
+func (hm *HashMap) First() (MalType, error) {
+	entries := hm.entries()
+	if len(entries) == 0 {
+		return NilValue, nil
+	}
+	return NewVector(entries[0].key, entries[0].value), nil
+}
+func (hm *HashMap) Rest() (Seq, error) {
+	entries := hm.entries()
+	if len(entries) == 0 {
+		return NewList(), nil
+	}
+	r := make([]MalType, 0, len(entries)-1)
+	for _, e := range entries[1:] {
+		r = append(r, NewVector(e.key, e.value))
+	}
+	return NewList(r...), nil
+}
+
+// Map returns a new HashMap with f applied to every key and value, so that
+// evaluating a map literal evaluates the keys as well.
+func (hm *HashMap) Map(f func(arg MalType, env *Env) (MalType, error), env *Env) (MalType, error) {
+	r := &HashMap{}
+
+	for _, e := range hm.entries() {
+		key, err := f(e.key, env)
+		if err != nil {
+			return nil, err
+		}
+		item, err := f(e.value, env)
+		if err != nil {
+			return nil, err
+		}
+		r = r.Assoc(key, item)
+	}
+
+	return r, nil
+}
@@ -0,0 +1,222 @@
+package types
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestHashMapKeys(t *testing.T) {
+	n := NewIntFromInt
+	for _, c := range []struct {
+		name     string
+		key      MalType
+		lookup   MalType
+		distinct MalType
+	}{
+		{"int", n(1), NewFloat(1), n(2)},
+		{"string and keyword", NewString("a"), NewString("a"), NewKeyword("a")},
+		{"symbol", NewSymbol("a"), NewSymbol("a"), NewString("a")},
+		{"nil", NilValue, NilValue, FalseValue},
+		{"boolean", TrueValue, TrueValue, FalseValue},
+		{"list and vector", NewList(n(1), n(2)), NewVector(n(1), n(2)), NewList(n(2), n(1))},
+		{"map", NewHashMap([]MalType{n(1), n(2)}), NewHashMap([]MalType{n(1), n(2)}), NewHashMap([]MalType{n(1), n(3)})},
+		{"set", NewSet(n(1), n(2)), NewSet(n(2), n(1)), NewSet(n(1))},
+	} {
+		m := NewHashMap([]MalType{c.key, NewString("found"), c.distinct, NewString("other")})
+		if m.Length() != 2 {
+			t.Errorf("%s: %s and %s are the same key", c.name, c.key.Print(true), c.distinct.Print(true))
+		}
+		if v, ok := m.Get(c.lookup); !ok || !v.Equal(NewString("found")) {
+			t.Errorf("%s: looking up %s: got %v, %v", c.name, c.lookup.Print(true), v, ok)
+		}
+		if m.Assoc(c.lookup, NilValue).Length() != 2 {
+			t.Errorf("%s: assoc of %s added a key", c.name, c.lookup.Print(true))
+		}
+		if d := m.Dissoc(c.lookup); d.Length() != 1 {
+			t.Errorf("%s: dissoc of %s left %s", c.name, c.lookup.Print(true), d.Print(true))
+		}
+	}
+}
+
+// clash is a map key whose hash is set by the test, so that different keys
+// can be given the same hash or hashes that share their low bits.
+type clash struct {
+	id   int
+	hash uint64
+}
+
+func (c *clash) TypeName() string           { return "clash" }
+func (c *clash) Print(readably bool) string { return fmt.Sprintf("#clash %d", c.id) }
+func (c *clash) Hash() uint64               { return c.hash }
+func (c *clash) Equal(other MalType) bool {
+	o, ok := other.(*clash)
+	return ok && o.id == c.id
+}
+
+// checkHashMap fails t unless hm maps exactly the keys of model to the ints
+// they map to.
+func checkHashMap(t *testing.T, what string, hm *HashMap, model map[int]int, key func(int) MalType) {
+	t.Helper()
+	if hm.Length() != len(model) {
+		t.Fatalf("%s: length %d, want %d", what, hm.Length(), len(model))
+	}
+	for k, want := range model {
+		if got, ok := hm.Get(key(k)); !ok || !got.Equal(NewIntFromInt(want)) {
+			t.Fatalf("%s: key %d maps to %v, %v, want %d", what, k, got, ok, want)
+		}
+	}
+	seen := 0
+	hm.root.each(func(e *mapEntry) { seen++ })
+	if seen != len(model) || len(hm.Keys()) != len(model) {
+		t.Fatalf("%s: the trie holds %d entries, want %d", what, seen, len(model))
+	}
+	if len(model) == 0 && hm.root != nil {
+		t.Fatalf("%s: an empty map has a root node", what)
+	}
+}
+
+// TestHashMapModel applies random assocs and dissocs to maps and to Go maps,
+// keeping every version of both, and checks that they agree.  The keys are
+// given few distinct hashes, so the maps are full of collision nodes and of
+// nodes deep in the trie, whose hashes differ only in their high bits.
+func TestHashMapModel(t *testing.T) {
+	hashes := []uint64{0, 1, 1 << 40, 1<<40 | 1, 1 << 63, 0x20, 0x21, 0xffff_ffff_ffff_ffff}
+	keys := map[int]MalType{}
+	key := func(k int) MalType {
+		if keys[k] == nil {
+			keys[k] = &clash{k, hashes[k%len(hashes)]}
+		}
+		return keys[k]
+	}
+
+	r := rand.New(rand.NewSource(1))
+	maps := []*HashMap{NewHashMap(nil)}
+	models := []map[int]int{{}}
+	for step := 0; step < 2000; step++ {
+		i := r.Intn(len(maps))
+		hm, model := maps[i], copyModel(models[i])
+		for n := r.Intn(8); n >= 0; n-- {
+			k := r.Intn(40)
+			if r.Intn(3) == 0 {
+				hm = hm.Dissoc(key(k))
+				delete(model, k)
+			} else {
+				hm = hm.Assoc(key(k), NewIntFromInt(step))
+				model[k] = step
+			}
+		}
+		maps = append(maps, hm)
+		models = append(models, model)
+	}
+	for i := range maps {
+		checkHashMap(t, "version", maps[i], models[i], key)
+	}
+}
+
+// copyModel returns a copy of m.
+func copyModel(m map[int]int) map[int]int {
+	r := make(map[int]int, len(m))
+	for k, v := range m {
+		r[k] = v
+	}
+	return r
+}
+
+// TestHashMapCollisions fills a collision node and empties it again,
+// checking that the earlier versions are left as they were.
+func TestHashMapCollisions(t *testing.T) {
+	key := func(k int) MalType { return &clash{k, 42} }
+	model := map[int]int{}
+	hm := NewHashMap(nil)
+	var versions []*HashMap
+	for k := 0; k < 5; k++ {
+		versions = append(versions, hm)
+		hm = hm.Assoc(key(k), NewIntFromInt(k))
+		model[k] = k
+	}
+	checkHashMap(t, "full", hm, model, key)
+	// The keys share all 64 bits, so the node holding them is below the
+	// last level of the trie.
+	node := hm.root
+	for node.collision == nil {
+		if len(node.slots) != 1 || node.slots[0].child == nil {
+			t.Fatal("keys with the same hash are not in a collision node")
+		}
+		node = node.slots[0].child
+	}
+	if len(node.collision) != 5 {
+		t.Fatalf("the collision node holds %d entries, want 5", len(node.collision))
+	}
+
+	replaced := hm.Assoc(key(2), NilValue)
+	if v, _ := hm.Get(key(2)); !v.Equal(NewIntFromInt(2)) {
+		t.Error("assoc changed the map it was applied to")
+	}
+	if v, _ := replaced.Get(key(2)); v != NilValue || replaced.Length() != 5 {
+		t.Error("assoc of a key in a collision node did not replace its value")
+	}
+
+	for k := 4; k >= 0; k-- {
+		hm = hm.Dissoc(key(k))
+		delete(model, k)
+		checkHashMap(t, "dissoc", hm, model, key)
+		checkHashMap(t, "earlier version", versions[k], model, key)
+	}
+	if hm.Dissoc(key(0)) != hm {
+		t.Error("dissoc of a missing key made a new map")
+	}
+}
+
+// TestHashMapSharing checks that assoc copies only the path to the entry it
+// changes: the other children of the root are shared with the old version.
+func TestHashMapSharing(t *testing.T) {
+	forms := []MalType{}
+	for i := 0; i < 1000; i++ {
+		forms = append(forms, NewIntFromInt(i), NewIntFromInt(i))
+	}
+	old := NewHashMap(forms)
+	hm := old.Assoc(NewIntFromInt(0), NilValue)
+	if hm.root == old.root {
+		t.Fatal("assoc did not copy the root")
+	}
+	shared := 0
+	for i, slot := range hm.root.slots {
+		if slot.child != nil && slot.child == old.root.slots[i].child {
+			shared++
+		}
+	}
+	if shared != len(old.root.slots)-1 {
+		t.Errorf("assoc shared %d of the %d children of the root, want all but one", shared, len(old.root.slots))
+	}
+	if v, _ := old.Get(NewIntFromInt(0)); !v.Equal(NewIntFromInt(0)) {
+		t.Error("assoc changed the map it was applied to")
+	}
+}
+
+// TestHashMapWalk checks that walking a map with First and Rest visits its
+// entries in the order Keys lists them.
+func TestHashMapWalk(t *testing.T) {
+	forms := []MalType{}
+	for i := 0; i < 100; i++ {
+		forms = append(forms, NewIntFromInt((i*37)%100), NewIntFromInt(i))
+	}
+	hm := NewHashMap(forms)
+	var s Seq = hm
+	for i, k := range hm.Keys() {
+		first, err := s.First()
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, _ := hm.Get(k)
+		if !first.Equal(NewVector(k, v)) {
+			t.Fatalf("entry %d: got %s, want [%s %s]", i, first.Print(true), k.Print(true), v.Print(true))
+		}
+		if s, err = s.Rest(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if empty, _ := s.IsEmpty(); !empty {
+		t.Error("the walk did not end with the last entry")
+	}
+}
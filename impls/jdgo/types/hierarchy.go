@@ -0,0 +1,107 @@
+package types
+
+import "fmt"
+
+// Hierarchy records which values derive from which, as set up with derive,
+// so that multimethods can dispatch on a parent's method.  Any value can
+// be part of a hierarchy, but keywords, symbols and types are the usual
+// ones.
+type Hierarchy struct {
+	parents *HashMap // from each child to the Set of its parents
+	// version changes whenever the hierarchy does, so that multimethods
+	// know when the methods they have cached are out of date.
+	version int
+}
+
+func NewHierarchy() *Hierarchy {
+	return &Hierarchy{parents: &HashMap{}}
+}
+
+// GlobalHierarchy is the hierarchy used by derive, isa? and multimethods.
+var GlobalHierarchy = NewHierarchy()
+
+// Derive makes parent a parent of child.  It fails if that would make a
+// value its own ancestor.
+func (h *Hierarchy) Derive(child, parent MalType) error {
+	if h.Isa(parent, child) {
+		return fmt.Errorf("cyclic derivation: %s already derives from %s", parent.Print(true), child.Print(true))
+	}
+	h.parents = h.parents.Assoc(child, h.Parents(child).Conj(parent))
+	h.version++
+	return nil
+}
+
+// Underive removes parent from the parents of child.
+func (h *Hierarchy) Underive(child, parent MalType) {
+	parents := h.Parents(child).Disj(parent)
+	if parents.Length() == 0 {
+		h.parents = h.parents.Dissoc(child)
+	} else {
+		h.parents = h.parents.Assoc(child, parents)
+	}
+	h.version++
+}
+
+// Parents returns the values child derives from directly.
+func (h *Hierarchy) Parents(child MalType) *Set {
+	if s, ok := h.parents.Get(child); ok {
+		return s.(*Set)
+	}
+	return NewSet()
+}
+
+// Ancestors returns the values child derives from, directly or not.
+func (h *Hierarchy) Ancestors(child MalType) *Set {
+	r := NewSet()
+	todo := h.Parents(child).Items()
+	for len(todo) > 0 {
+		v := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		if !r.Contains(v) {
+			r = r.Conj(v)
+			todo = append(todo, h.Parents(v).Items()...)
+		}
+	}
+	return r
+}
+
+// Descendants returns the values that derive from parent, directly or not.
+func (h *Hierarchy) Descendants(parent MalType) *Set {
+	r := NewSet()
+	for _, child := range h.parents.Keys() {
+		if h.Ancestors(child).Contains(parent) {
+			r = r.Conj(child)
+		}
+	}
+	return r
+}
+
+// Isa reports whether child is equal to parent or derives from it.  Every
+// type derives from Object, as it does for protocols.  Vectors are compared
+// item by item, so that multimethods can dispatch on several values at
+// once.
+func (h *Hierarchy) Isa(child, parent MalType) bool {
+	if child.Equal(parent) {
+		return true
+	}
+	if parent == ObjectType {
+		switch child.(type) {
+		case *BuiltinType, *RecordType:
+			return true
+		}
+	}
+	cv, ok := child.(*Vector)
+	pv, ok2 := parent.(*Vector)
+	if ok && ok2 {
+		if cv.Length() != pv.Length() {
+			return false
+		}
+		for i := 0; i < cv.Length(); i++ {
+			if !h.Isa(cv.Nth(i), pv.Nth(i)) {
+				return false
+			}
+		}
+		return true
+	}
+	return h.Ancestors(child).Contains(parent)
+}
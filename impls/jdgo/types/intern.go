@@ -0,0 +1,65 @@
+package types
+
+import (
+	"runtime"
+	"strings"
+	"sync"
+	"weak"
+)
+
+// Symbols and keywords are interned: NewSymbol and NewKeyword return the same
+// value for the same name as long as that value is in use, so they can be
+// compared by identity and used as map keys directly.  The tables only hold
+// weak pointers, so that names made at run time, such as those from gensym,
+// are freed once nothing refers to them.
+var (
+	symbols  = newInternTable[Symbol]()
+	keywords = newInternTable[String]()
+)
+
+type internTable[T any] struct {
+	mu sync.Mutex
+	m  map[string]weak.Pointer[T]
+}
+
+func newInternTable[T any]() *internTable[T] {
+	return &internTable[T]{m: map[string]weak.Pointer[T]{}}
+}
+
+// get returns the value interned under name, creating it with create if
+// there is none.
+func (t *internTable[T]) get(name string, create func() *T) *T {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if v := t.m[name].Value(); v != nil {
+		return v
+	}
+	v := create()
+	t.m[name] = weak.Make(v)
+	runtime.AddCleanup(v, t.remove, name)
+	return v
+}
+
+// remove drops name once its value has been freed, unless it has been
+// interned again since.
+func (t *internTable[T]) remove(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.m[name].Value() == nil {
+		delete(t.m, name)
+	}
+}
+
+func NewSymbol(value string) *Symbol {
+	return symbols.get(value, func() *Symbol {
+		return &Symbol{value, hashString("'" + value)}
+	})
+}
+
+// NewKeyword returns the keyword named value; a leading colon is optional.
+func NewKeyword(value string) *String {
+	value = strings.TrimPrefix(value, ":")
+	return keywords.get(value, func() *String {
+		return &String{value, true}
+	})
+}
@@ -0,0 +1,24 @@
+package types
+
+import "testing"
+
+// TestNewKeyword checks that NewKeyword drops one leading colon, and only
+// one, and returns the same keyword for the same name.
+func TestNewKeyword(t *testing.T) {
+	for _, c := range []struct{ value, want string }{
+		{"a", ":a"},
+		{":a", ":a"},
+		{"::a", "::a"},
+		{":", ":"},
+	} {
+		if got := NewKeyword(c.value).Print(true); got != c.want {
+			t.Errorf("NewKeyword(%q): got %s, want %s", c.value, got, c.want)
+		}
+	}
+	if NewKeyword("a") != NewKeyword(":a") {
+		t.Error("NewKeyword(\"a\") and NewKeyword(\":a\") are different keywords")
+	}
+	if NewKeyword("a") == NewKeyword("::a") {
+		t.Error("NewKeyword(\"a\") and NewKeyword(\"::a\") are the same keyword")
+	}
+}
@@ -0,0 +1,152 @@
+package types
+
+import "fmt"
+
+// MultiFn is a function defined with defmulti.  Calling it applies the
+// dispatch function to the arguments and then calls the method added for
+// the resulting dispatch value, or for a value it derives from in the
+// hierarchy, or else the default method.
+type MultiFn struct {
+	name       string
+	dispatch   MalType
+	defaultVal MalType
+	hierarchy  *Hierarchy
+	methods    *HashMap // from dispatch values to functions
+	prefers    *HashMap // from dispatch values to the Set of values they are preferred over
+	// cache maps the dispatch values seen so far to the method chosen for
+	// them.  It is cleared when the methods or preferences change, or when
+	// the hierarchy is no longer at cacheVersion.
+	cache        *HashMap
+	cacheVersion int
+}
+
+func NewMultiFn(name string, dispatch, defaultVal MalType, h *Hierarchy) *MultiFn {
+	return &MultiFn{
+		name:         name,
+		dispatch:     dispatch,
+		defaultVal:   defaultVal,
+		hierarchy:    h,
+		methods:      &HashMap{},
+		prefers:      &HashMap{},
+		cache:        &HashMap{},
+		cacheVersion: h.version,
+	}
+}
+
+func (mf *MultiFn) TypeName() string           { return "MultiFn" }
+func (mf *MultiFn) Print(readably bool) string { return "#<multifn " + mf.name + ">" }
+func (mf *MultiFn) Equal(other MalType) bool   { return mf == other }
+func (mf *MultiFn) Hash() uint64               { return identityHash(mf) }
+
+func (mf *MultiFn) Eval(b *Bindings, args ...MalType) (MalType, error) {
+	dv, err := Apply(b, mf.dispatch, args...)
+	if err != nil {
+		return nil, err
+	}
+	f, err := mf.Method(dv)
+	if err != nil {
+		return nil, err
+	}
+	if f == nil {
+		return nil, fmt.Errorf("no method in multimethod %s for dispatch value %s", mf.name, dv.Print(true))
+	}
+	return Apply(b, f, args...)
+}
+
+func (mf *MultiFn) AddMethod(dv, f MalType) {
+	mf.methods = mf.methods.Assoc(dv, f)
+	mf.cache = &HashMap{}
+}
+
+func (mf *MultiFn) RemoveMethod(dv MalType) {
+	mf.methods = mf.methods.Dissoc(dv)
+	mf.cache = &HashMap{}
+}
+
+func (mf *MultiFn) RemoveAllMethods() {
+	mf.methods = &HashMap{}
+	mf.cache = &HashMap{}
+}
+
+// Methods returns the map from dispatch values to methods.
+func (mf *MultiFn) Methods() *HashMap { return mf.methods }
+
+// Prefers returns the map from dispatch values to the Set of values they
+// are preferred over.
+func (mf *MultiFn) Prefers() *HashMap { return mf.prefers }
+
+// PreferMethod makes the method for x win over the one for y when both
+// match a dispatch value.
+func (mf *MultiFn) PreferMethod(x, y MalType) error {
+	if mf.prefer(y, x) {
+		return fmt.Errorf("preference conflict in multimethod %s: %s is already preferred to %s",
+			mf.name, y.Print(true), x.Print(true))
+	}
+	s := NewSet()
+	if v, ok := mf.prefers.Get(x); ok {
+		s = v.(*Set)
+	}
+	mf.prefers = mf.prefers.Assoc(x, s.Conj(y))
+	mf.cache = &HashMap{}
+	return nil
+}
+
+// prefer reports whether x is preferred over y, directly or because of a
+// preference between their ancestors.
+func (mf *MultiFn) prefer(x, y MalType) bool {
+	if v, ok := mf.prefers.Get(x); ok && v.(*Set).Contains(y) {
+		return true
+	}
+	for _, p := range mf.hierarchy.Parents(y).Items() {
+		if mf.prefer(x, p) {
+			return true
+		}
+	}
+	for _, p := range mf.hierarchy.Parents(x).Items() {
+		if mf.prefer(p, y) {
+			return true
+		}
+	}
+	return false
+}
+
+// dominates reports whether the method for x should be chosen over the one
+// for y.
+func (mf *MultiFn) dominates(x, y MalType) bool {
+	return mf.prefer(x, y) || mf.hierarchy.Isa(x, y)
+}
+
+// Method returns the method to call for dispatch value dv, or nil if there
+// is none.  It fails if several methods match and none dominates the
+// others.
+func (mf *MultiFn) Method(dv MalType) (MalType, error) {
+	if mf.cacheVersion != mf.hierarchy.version {
+		mf.cache = &HashMap{}
+		mf.cacheVersion = mf.hierarchy.version
+	}
+	if f, ok := mf.cache.Get(dv); ok {
+		return f, nil
+	}
+	var best MalType
+	for _, k := range mf.methods.Keys() {
+		if !mf.hierarchy.Isa(dv, k) {
+			continue
+		}
+		if best == nil || mf.dominates(k, best) {
+			best = k
+		}
+		if !mf.dominates(best, k) {
+			return nil, fmt.Errorf("multiple methods in multimethod %s match dispatch value %s: %s and %s, and neither is preferred",
+				mf.name, dv.Print(true), best.Print(true), k.Print(true))
+		}
+	}
+	if best == nil {
+		best = mf.defaultVal
+	}
+	f, ok := mf.methods.Get(best)
+	if !ok {
+		return nil, nil
+	}
+	mf.cache = mf.cache.Assoc(dv, f)
+	return f, nil
+}
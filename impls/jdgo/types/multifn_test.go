@@ -0,0 +1,75 @@
+package types
+
+import "testing"
+
+func TestMultiFnDispatch(t *testing.T) {
+	kw := NewKeyword
+	h := NewHierarchy()
+	identity := NewFunction("identity", func(args ...MalType) (MalType, error) { return args[0], nil })
+	mf := NewMultiFn("describe", identity, kw("default"), h)
+	call := func(dv MalType) (string, error) {
+		v, err := mf.Eval(nil, dv)
+		if err != nil {
+			return "", err
+		}
+		return v.(*String).Value(), nil
+	}
+
+	mf.AddMethod(kw("pet"), constant(NewString("pet")))
+	mf.AddMethod(kw("animal"), constant(NewString("animal")))
+	if _, err := call(kw("dog")); err == nil {
+		t.Error("calling a multimethod with no matching method did not fail")
+	}
+
+	h.Derive(kw("dog"), kw("pet"))
+	if got, err := call(kw("dog")); err != nil || got != "pet" {
+		t.Errorf("dog: got %q, %v, want pet", got, err)
+	}
+
+	// The :pet method is now cached for :dog, which must not hide the
+	// ambiguity the next derive introduces.
+	h.Derive(kw("dog"), kw("animal"))
+	if _, err := call(kw("dog")); err == nil {
+		t.Error("an ambiguous dispatch did not fail")
+	}
+	if err := mf.PreferMethod(kw("animal"), kw("pet")); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := call(kw("dog")); err != nil || got != "animal" {
+		t.Errorf("dog with animal preferred: got %q, %v, want animal", got, err)
+	}
+	if err := mf.PreferMethod(kw("pet"), kw("animal")); err == nil {
+		t.Error("a conflicting preference was accepted")
+	}
+
+	mf.AddMethod(kw("default"), constant(NewString("default")))
+	mf.RemoveMethod(kw("animal"))
+	for dv, want := range map[string]string{"dog": "pet", "rock": "default"} {
+		if got, err := call(kw(dv)); err != nil || got != want {
+			t.Errorf("%s: got %q, %v, want %s", dv, got, err, want)
+		}
+	}
+}
+
+func TestHierarchy(t *testing.T) {
+	kw := NewKeyword
+	h := NewHierarchy()
+	h.Derive(kw("square"), kw("rect"))
+	h.Derive(kw("rect"), kw("shape"))
+	if !h.Isa(kw("square"), kw("shape")) {
+		t.Error("square does not derive from shape")
+	}
+	if !h.Isa(NewVector(kw("square"), NewBuiltinType("Int")), NewVector(kw("rect"), ObjectType)) {
+		t.Error("vectors are not compared item by item")
+	}
+	if err := h.Derive(kw("shape"), kw("square")); err == nil {
+		t.Error("a cyclic derivation was accepted")
+	}
+	if got := h.Descendants(kw("shape")); !got.Equal(NewSet(kw("square"), kw("rect"))) {
+		t.Errorf("descendants of shape: got %s", got.Print(true))
+	}
+	h.Underive(kw("rect"), kw("shape"))
+	if h.Isa(kw("square"), kw("shape")) {
+		t.Error("square still derives from shape after underive")
+	}
+}
@@ -0,0 +1,202 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Namespace is a named Env holding the definitions made while it is the
+// current namespace.  Every namespace's Env has the core namespace's Env as
+// its outer Env, so the builtins are visible everywhere.  A namespace can
+// also refer to definitions of other namespaces by their plain names, and
+// give other namespaces aliases to qualify symbols with.
+type Namespace struct {
+	name    string
+	env     *Env
+	aliases map[*Symbol]*Namespace
+	refers  map[*Symbol]*Namespace
+	private map[*Symbol]bool
+}
+
+// CoreNamespaceName is the name of the namespace holding the builtins.
+const CoreNamespaceName = "mal.core"
+
+var (
+	namespaces = map[string]*Namespace{}
+	coreNS     = newNamespace(CoreNamespaceName, nil)
+	currentNS  *Namespace
+	symNS      = NewSymbol("*ns*")
+)
+
+func init() {
+	SetCurrentNamespace(CreateNamespace("user"))
+}
+
+func newNamespace(name string, outer *Env) *Namespace {
+	ns := &Namespace{
+		name:    name,
+		aliases: map[*Symbol]*Namespace{},
+		refers:  map[*Symbol]*Namespace{},
+		private: map[*Symbol]bool{},
+	}
+	ns.env = &Env{outer, map[*Symbol]MalType{}, ns}
+	namespaces[name] = ns
+	return ns
+}
+
+// CreateNamespace returns the namespace called name, creating it if it does
+// not exist yet.
+func CreateNamespace(name string) *Namespace {
+	if ns, ok := namespaces[name]; ok {
+		return ns
+	}
+	return newNamespace(name, coreNS.env)
+}
+
+// FindNamespace returns the namespace called name, or nil if there is none.
+func FindNamespace(name string) *Namespace {
+	return namespaces[name]
+}
+
+// RemoveNamespace forgets the namespace called name, which then is not
+// found by name or saved in images, and returns it, or nil if there is
+// none.  Definitions that refer to it, by an alias for example, keep it.
+func RemoveNamespace(name string) *Namespace {
+	ns := namespaces[name]
+	delete(namespaces, name)
+	return ns
+}
+
+// AllNamespaces returns every namespace, sorted by name.
+func AllNamespaces() []*Namespace {
+	r := make([]*Namespace, 0, len(namespaces))
+	for _, ns := range namespaces {
+		r = append(r, ns)
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i].name < r[j].name })
+	return r
+}
+
+// CoreNamespace returns the namespace holding the builtins.
+func CoreNamespace() *Namespace { return coreNS }
+
+// CurrentNamespace returns the namespace top level forms are evaluated in.
+func CurrentNamespace() *Namespace { return currentNS }
+
+// SetCurrentNamespace makes ns the current namespace and binds *ns* to it.
+func SetCurrentNamespace(ns *Namespace) {
+	currentNS = ns
+	coreNS.env.Set(symNS, ns)
+}
+
+func (ns *Namespace) TypeName() string           { return "Namespace" }
+func (ns *Namespace) Print(readably bool) string { return "#<namespace " + ns.name + ">" }
+func (ns *Namespace) Equal(other MalType) bool   { return ns == other }
+func (ns *Namespace) Hash() uint64               { return identityHash(ns) }
+
+func (ns *Namespace) Name() string { return ns.name }
+func (ns *Namespace) Env() *Env    { return ns.env }
+
+// MarkPrivate hides the definition of sym from other namespaces.
+func (ns *Namespace) MarkPrivate(sym *Symbol) {
+	ns.private[sym] = true
+}
+
+// Publics returns the definitions of ns that other namespaces can use.  The
+// value of a dynamic var is its root value.
+func (ns *Namespace) Publics() map[*Symbol]MalType {
+	r := map[*Symbol]MalType{}
+	for k, v := range ns.env.items {
+		if dv, ok := v.(*Var); ok {
+			v = dv.Root()
+		}
+		if !ns.private[k] {
+			r[k] = v
+		}
+	}
+	return r
+}
+
+// Definitions returns everything defined in ns, private or not.  The value
+// of a dynamic var is the Var itself.
+func (ns *Namespace) Definitions() map[*Symbol]MalType {
+	r := make(map[*Symbol]MalType, len(ns.env.items))
+	for k, v := range ns.env.items {
+		r[k] = v
+	}
+	return r
+}
+
+// IsPrivate reports whether the definition of sym in ns is private.
+func (ns *Namespace) IsPrivate(sym *Symbol) bool { return ns.private[sym] }
+
+// Aliases returns the aliases ns gives other namespaces.
+func (ns *Namespace) Aliases() map[*Symbol]*Namespace {
+	r := make(map[*Symbol]*Namespace, len(ns.aliases))
+	for k, v := range ns.aliases {
+		r[k] = v
+	}
+	return r
+}
+
+// Refers returns the definitions ns refers to, and the namespaces that
+// define them.
+func (ns *Namespace) Refers() map[*Symbol]*Namespace {
+	r := make(map[*Symbol]*Namespace, len(ns.refers))
+	for k, v := range ns.refers {
+		r[k] = v
+	}
+	return r
+}
+
+// Alias lets symbols in ns be qualified with alias to refer to target.
+func (ns *Namespace) Alias(alias *Symbol, target *Namespace) {
+	ns.aliases[alias] = target
+}
+
+// Refer lets ns use the definition of sym in from by its plain name.  The
+// definition is looked up when it is used, so later changes to it are seen.
+func (ns *Namespace) Refer(sym *Symbol, from *Namespace) error {
+	if from.private[sym] {
+		return fmt.Errorf("%s/%s is private", from.name, sym.value)
+	}
+	if _, ok := from.env.items[sym]; !ok {
+		return fmt.Errorf("%s/%s does not exist", from.name, sym.value)
+	}
+	ns.refers[sym] = from
+	return nil
+}
+
+// public returns the definition of sym in ns if it is visible from the
+// namespace from.
+func (ns *Namespace) public(sym *Symbol, from *Namespace) (MalType, bool) {
+	if ns != from && ns.private[sym] {
+		return nil, false
+	}
+	v, ok := ns.env.items[sym]
+	return v, ok
+}
+
+// resolve returns the namespace that the qualifier name stands for in ns:
+// one of its aliases, or else the namespace called name.
+func (ns *Namespace) resolve(name string) *Namespace {
+	if target, ok := ns.aliases[NewSymbol(name)]; ok {
+		return target
+	}
+	return FindNamespace(name)
+}
+
+// lookupQualified looks up a symbol of the form ns/name, such as str/join,
+// on behalf of the namespace from.
+func lookupQualified(k *Symbol, from *Namespace) (MalType, bool) {
+	qualifier, name, ok := strings.Cut(k.value, "/")
+	if !ok || qualifier == "" || name == "" {
+		return nil, false
+	}
+	target := from.resolve(qualifier)
+	if target == nil {
+		return nil, false
+	}
+	return target.public(NewSymbol(name), from)
+}
@@ -0,0 +1,80 @@
+package types
+
+import "testing"
+
+func TestNamespaceLookup(t *testing.T) {
+	lib := CreateNamespace("test.lib")
+	lib.Env().Set(NewSymbol("join"), NewString("lib join"))
+	lib.Env().Set(NewSymbol("sep"), NewString("lib sep"))
+	lib.MarkPrivate(NewSymbol("sep"))
+	CoreNamespace().Env().Set(NewSymbol("test-builtin"), NewString("builtin"))
+
+	app := CreateNamespace("test.app")
+	app.Alias(NewSymbol("l"), lib)
+	if err := app.Refer(NewSymbol("sep"), lib); err == nil {
+		t.Error("referring to a private definition did not fail")
+	}
+	if err := app.Refer(NewSymbol("join"), lib); err != nil {
+		t.Fatal(err)
+	}
+	local := NewEnv(app.Env())
+
+	for _, c := range []struct {
+		sym, want string
+	}{
+		{"join", "lib join"},
+		{"l/join", "lib join"},
+		{"test.lib/join", "lib join"},
+		{"test-builtin", "builtin"},
+	} {
+		v, ok := local.Lookup(NewSymbol(c.sym))
+		if !ok || v.(*String).Value() != c.want {
+			t.Errorf("%s: got %v, %v, want %s", c.sym, v, ok, c.want)
+		}
+	}
+	for _, sym := range []string{"l/sep", "sep", "x/join", "/"} {
+		if v, ok := local.Lookup(NewSymbol(sym)); ok {
+			t.Errorf("%s: got %s, want no value", sym, v.Print(true))
+		}
+	}
+	if v, ok := lib.Env().Lookup(NewSymbol("test.lib/sep")); !ok || v.(*String).Value() != "lib sep" {
+		t.Error("a namespace cannot see its own private definition")
+	}
+
+	// Referred definitions are looked up when used, and local definitions
+	// take precedence over them.
+	lib.Env().Set(NewSymbol("join"), NewString("new join"))
+	if v, _ := local.Lookup(NewSymbol("join")); v.(*String).Value() != "new join" {
+		t.Errorf("join after redefinition: got %s", v.Print(true))
+	}
+	app.Env().Set(NewSymbol("join"), NewString("app join"))
+	if v, _ := local.Lookup(NewSymbol("join")); v.(*String).Value() != "app join" {
+		t.Errorf("join after local definition: got %s", v.Print(true))
+	}
+}
+
+// BenchmarkEnvFind looks symbols up from the Env of a call nested in two
+// others, as EVAL does: a parameter, a definition of the namespace, one it
+// refers to, and a builtin.
+func BenchmarkEnvFind(b *testing.B) {
+	lib := CreateNamespace("bench.lib")
+	lib.Env().Set(NewSymbol("helper"), NewString("helper"))
+	CoreNamespace().Env().Set(NewSymbol("bench-builtin"), NewString("builtin"))
+	app := CreateNamespace("bench.app")
+	app.Env().Set(NewSymbol("f"), NewString("f"))
+	if err := app.Refer(NewSymbol("helper"), lib); err != nil {
+		b.Fatal(err)
+	}
+	env := NewEnv(NewEnv(NewEnv(app.Env())))
+	env.Set(NewSymbol("n"), NewIntFromInt(1))
+	syms := []*Symbol{NewSymbol("n"), NewSymbol("f"), NewSymbol("helper"), NewSymbol("bench-builtin")}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		v, err := env.Find(syms[i%len(syms)])
+		if err != nil {
+			b.Fatal(err)
+		}
+		sink = v
+	}
+}
@@ -0,0 +1,169 @@
+package types
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// MapOrder selects the order hash map entries are printed and iterated in.
+type MapOrder int
+
+const (
+	// InsertionOrder lists entries in the order their keys were first
+	// added to the map.
+	InsertionOrder MapOrder = iota
+	// SortedOrder lists entries sorted by key according to Compare.
+	SortedOrder
+)
+
+// mapOrder holds the MapOrder in use.  It is atomic because maps are read
+// on every goroutine, while it can be changed at any time.
+var mapOrder atomic.Int32
+
+// SetMapOrder selects the order used by every hash map from now on.
+func SetMapOrder(order MapOrder) {
+	mapOrder.Store(int32(order))
+}
+
+// GetMapOrder returns the order selected with SetMapOrder.
+func GetMapOrder() MapOrder {
+	return MapOrder(mapOrder.Load())
+}
+
+// typeRank orders values of different types: nil sorts first, then
+// booleans, numbers, keywords, strings, symbols, sequences, maps, sets and
+// finally everything else.
+func typeRank(v MalType) int {
+	switch t := v.(type) {
+	case *Nil:
+		return 0
+	case *Boolean:
+		return 1
+	case *Int, *Float:
+		return 2
+	case *String:
+		if t.keyword {
+			return 3
+		}
+		return 4
+	case *Symbol:
+		return 5
+	case *List, *Vector, *Cons, *LazySeq:
+		return 6
+	case *HashMap:
+		return 7
+	case *Set:
+		return 8
+	}
+	return 9
+}
+
+// Compare defines a total order over values, returning a negative number
+// when a sorts before b, zero when they are equal and a positive number
+// otherwise.  Values of different types are ordered by type; values that
+// have no natural order, like functions, are ordered by type name and
+// printed representation.
+func Compare(a, b MalType) int {
+	ra, rb := typeRank(a), typeRank(b)
+	if ra != rb {
+		return ra - rb
+	}
+	switch av := a.(type) {
+	case *Nil:
+		return 0
+	case *Boolean:
+		bv := b.(*Boolean)
+		if av.value == bv.value {
+			return 0
+		}
+		if !av.value {
+			return -1
+		}
+		return 1
+	case *Int:
+		if bv, ok := b.(*Int); ok {
+			return compareInts(av.value, bv.value)
+		}
+		return compareNumbers(float64(av.value), b.(*Float).value)
+	case *Float:
+		if bv, ok := b.(*Float); ok {
+			return compareNumbers(av.value, bv.value)
+		}
+		return compareNumbers(av.value, float64(b.(*Int).value))
+	case *String:
+		return strings.Compare(av.value, b.(*String).value)
+	case *Symbol:
+		return strings.Compare(av.value, b.(*Symbol).value)
+	case *List, *Vector, *Cons, *LazySeq:
+		as, _ := Sequence(a)
+		bs, _ := Sequence(b)
+		for i := 0; i < len(as) && i < len(bs); i++ {
+			if c := Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+		return compareInts(len(as), len(bs))
+	case *HashMap:
+		bv := b.(*HashMap)
+		ak, bk := av.Keys(), bv.Keys()
+		sortedKeys(ak)
+		sortedKeys(bk)
+		for i := 0; i < len(ak) && i < len(bk); i++ {
+			if c := Compare(ak[i], bk[i]); c != 0 {
+				return c
+			}
+			va, _ := av.Get(ak[i])
+			vb, _ := bv.Get(bk[i])
+			if c := Compare(va, vb); c != 0 {
+				return c
+			}
+		}
+		return compareInts(len(ak), len(bk))
+	case *Set:
+		as, bs := av.Items(), b.(*Set).Items()
+		sortedKeys(as)
+		sortedKeys(bs)
+		for i := 0; i < len(as) && i < len(bs); i++ {
+			if c := Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+		return compareInts(len(as), len(bs))
+	}
+	if c := strings.Compare(a.TypeName(), b.TypeName()); c != 0 {
+		return c
+	}
+	return strings.Compare(a.Print(true), b.Print(true))
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareNumbers compares a and b by value, with NaN before every other
+// number.  An Int and a Float of the same value are equal, as Equal has it.
+func compareNumbers(a, b float64) int {
+	switch {
+	case math.IsNaN(a) && math.IsNaN(b):
+		return 0
+	case math.IsNaN(a) || a < b:
+		return -1
+	case math.IsNaN(b) || a > b:
+		return 1
+	}
+	return 0
+}
+
+func sortedKeys(keys []MalType) {
+	sort.Slice(keys, func(i, j int) bool {
+		return Compare(keys[i], keys[j]) < 0
+	})
+}
@@ -0,0 +1,103 @@
+package types
+
+import (
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestMapOrder(t *testing.T) {
+	kw, n := NewKeyword, NewIntFromInt
+	m := NewHashMap([]MalType{kw("c"), n(1), kw("a"), n(2), kw("b"), n(3)})
+	defer SetMapOrder(GetMapOrder())
+	for _, c := range []struct {
+		name  string
+		order MapOrder
+		m     MalType
+		want  string
+	}{
+		{"insertion", InsertionOrder, m, "{:c 1 :a 2 :b 3}"},
+		{"replaced value keeps its place", InsertionOrder, m.Assoc(kw("c"), n(4)), "{:c 4 :a 2 :b 3}"},
+		{"re-added key goes last", InsertionOrder, m.Dissoc(kw("c")).Assoc(kw("c"), n(4)), "{:a 2 :b 3 :c 4}"},
+		{"sorted", SortedOrder, m, "{:a 2 :b 3 :c 1}"},
+		{"sorted by type, then value", SortedOrder,
+			NewHashMap([]MalType{NewString("s"), n(1), kw("k"), n(2), n(10), n(3), n(2), n(4), NilValue, n(5)}),
+			`{nil 5 2 4 10 3 :k 2 "s" 1}`},
+		{"numbers by value", SortedOrder,
+			NewSet(NewFloat(9.5), n(10), NewFloat(-1), n(2), NewFloat(10.25)),
+			"#{-1.000000 2 9.500000 10 10.250000}"},
+		{"set insertion", InsertionOrder, NewSet(n(3), n(1), n(2)), "#{3 1 2}"},
+		{"set sorted", SortedOrder, NewSet(n(3), n(1), n(2)), "#{1 2 3}"},
+	} {
+		SetMapOrder(c.order)
+		if got := c.m.Print(true); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.name, got, c.want)
+		}
+	}
+}
+
+func TestMapOrderIsStable(t *testing.T) {
+	defer SetMapOrder(GetMapOrder())
+	SetMapOrder(InsertionOrder)
+	var forms []MalType
+	for i := 0; i < 100; i++ {
+		forms = append(forms, NewIntFromInt((i*37)%100), NilValue)
+	}
+	want := NewHashMap(forms).Print(true)
+	for i := 0; i < 10; i++ {
+		if got := NewHashMap(forms).Print(true); got != want {
+			t.Fatalf("printing the same map twice gave %s and %s", want, got)
+		}
+	}
+	keys := NewHashMap(forms).Keys()
+	for i, k := range keys {
+		if !k.Equal(forms[2*i]) {
+			t.Fatalf("key %d: got %s, want %s", i, k.Print(true), forms[2*i].Print(true))
+		}
+	}
+}
+
+func TestCompareNumbers(t *testing.T) {
+	n, f := NewIntFromInt, NewFloat
+	for _, c := range []struct {
+		a, b MalType
+		want int
+	}{
+		{n(10), f(9.5), 1},
+		{f(9.5), n(10), -1},
+		{f(10), f(9.5), 1},
+		{f(9.5), f(9.5), 0},
+		{n(2), f(2), 0},
+		{f(2), n(2), 0},
+		{f(math.NaN()), f(-1), -1},
+		{n(-1), f(math.NaN()), 1},
+	} {
+		if got := Compare(c.a, c.b); got != c.want {
+			t.Errorf("Compare(%s, %s): got %d, want %d", c.a.Print(true), c.b.Print(true), got, c.want)
+		}
+	}
+}
+
+// TestSetMapOrderConcurrently changes the map order while maps are printed
+// on other goroutines, which -race checks.
+func TestSetMapOrderConcurrently(t *testing.T) {
+	defer SetMapOrder(GetMapOrder())
+	m := NewHashMap([]MalType{NewKeyword("b"), NilValue, NewKeyword("a"), NilValue})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if got := m.Print(true); got != "{:b nil :a nil}" && got != "{:a nil :b nil}" {
+					t.Errorf("got %s", got)
+					return
+				}
+			}
+		}()
+	}
+	for j := 0; j < 100; j++ {
+		SetMapOrder(MapOrder(j % 2))
+	}
+	wg.Wait()
+}
@@ -0,0 +1,184 @@
+package types
+
+import (
+	"fmt"
+	"sync"
+)
+
+// BuiltinType names one of the types implemented in Go, so that protocols
+// can be extended to it.  There is one BuiltinType per name; Object stands
+// for every type that a protocol is not extended to explicitly.
+type BuiltinType struct {
+	name string
+}
+
+var (
+	builtinTypes   = map[string]*BuiltinType{}
+	builtinTypesMu sync.RWMutex
+)
+
+// ObjectType is the type protocols fall back on.
+var ObjectType = NewBuiltinType("Object")
+
+// NewBuiltinType returns the BuiltinType with the given name.
+func NewBuiltinType(name string) *BuiltinType {
+	builtinTypesMu.RLock()
+	t, ok := builtinTypes[name]
+	builtinTypesMu.RUnlock()
+	if ok {
+		return t
+	}
+	builtinTypesMu.Lock()
+	defer builtinTypesMu.Unlock()
+	if t, ok := builtinTypes[name]; ok {
+		return t
+	}
+	t = &BuiltinType{name}
+	builtinTypes[name] = t
+	return t
+}
+
+func (t *BuiltinType) TypeName() string           { return "Type" }
+func (t *BuiltinType) Print(readably bool) string { return t.name }
+func (t *BuiltinType) Equal(other MalType) bool   { return t == other }
+func (t *BuiltinType) Hash() uint64               { return identityHash(t) }
+
+// TypeOf returns the type of v: its RecordType if it is a record, otherwise
+// the BuiltinType named by its TypeName.  Keywords are given a type of their
+// own, apart from strings.
+func TypeOf(v MalType) MalType {
+	switch t := v.(type) {
+	case *Record:
+		return t.rtype
+	case *Instance:
+		return t.itype
+	case *String:
+		if t.keyword {
+			return NewBuiltinType("Keyword")
+		}
+	}
+	return NewBuiltinType(v.TypeName())
+}
+
+// Protocol is a named set of methods that types can be extended to
+// implement.  Calling a method looks up the implementation for the type of
+// its first argument, falling back on the one for Object.  Methods can be
+// called, and the protocol extended, from several goroutines at once.
+type Protocol struct {
+	name    string
+	methods []string
+	// mu guards impls and cache.  The maps of methods they hold are
+	// replaced rather than changed, so they can be used once mu is
+	// released.
+	mu    sync.RWMutex
+	impls map[MalType]map[string]MalType
+	// cache holds the implementations found for each type the methods
+	// have been called on, with those for Object filling in the methods
+	// the type lacks.  It is cleared whenever the protocol is extended.
+	cache map[MalType]map[string]MalType
+}
+
+func NewProtocol(name string, methods []string) *Protocol {
+	return &Protocol{
+		name:    name,
+		methods: methods,
+		impls:   map[MalType]map[string]MalType{},
+		cache:   map[MalType]map[string]MalType{},
+	}
+}
+
+func (p *Protocol) TypeName() string           { return "Protocol" }
+func (p *Protocol) Print(readably bool) string { return "#<protocol " + p.name + ">" }
+func (p *Protocol) Equal(other MalType) bool   { return p == other }
+func (p *Protocol) Hash() uint64               { return identityHash(p) }
+
+func (p *Protocol) Name() string { return p.name }
+
+// Extend sets the implementations of the methods of p for type t, given as
+// a map from method names to functions.  Methods left out keep any
+// implementation they already had for t.
+func (p *Protocol) Extend(t MalType, impls map[string]MalType) error {
+	for name := range impls {
+		if !p.hasMethod(name) {
+			return fmt.Errorf("%s is not a method of protocol %s", name, p.name)
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	m := map[string]MalType{}
+	for name, f := range p.impls[t] {
+		m[name] = f
+	}
+	for name, f := range impls {
+		m[name] = f
+	}
+	p.impls[t] = m
+	clear(p.cache)
+	return nil
+}
+
+// Extends reports whether p has been extended to type t itself.
+func (p *Protocol) Extends(t MalType) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	_, ok := p.impls[t]
+	return ok
+}
+
+// Satisfies reports whether the methods of p can be called on v.
+func (p *Protocol) Satisfies(v MalType) bool {
+	return p.lookup(TypeOf(v)) != nil
+}
+
+func (p *Protocol) hasMethod(name string) bool {
+	for _, m := range p.methods {
+		if m == name {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup returns the implementations for type t, taking each method t
+// lacks from Object, or nil if p is not extended to t nor to Object.
+func (p *Protocol) lookup(t MalType) map[string]MalType {
+	p.mu.RLock()
+	m, ok := p.cache[t]
+	p.mu.RUnlock()
+	if ok {
+		return m
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	m, ok = p.impls[t]
+	if object, found := p.impls[ObjectType]; !ok {
+		m = object
+	} else if found && len(m) < len(p.methods) {
+		merged := map[string]MalType{}
+		for name, f := range object {
+			merged[name] = f
+		}
+		for name, f := range m {
+			merged[name] = f
+		}
+		m = merged
+	}
+	p.cache[t] = m
+	return m
+}
+
+// Method returns the function implementing method name for the value the
+// method is called on, its first argument.
+func (p *Protocol) Method(name string) *Function {
+	return NewDynamicFunction(name, func(b *Bindings, args ...MalType) (MalType, error) {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("%s: wrong number of arguments (0, expected at least 1)", name)
+		}
+		f, ok := p.lookup(TypeOf(args[0]))[name]
+		if !ok {
+			return nil, fmt.Errorf("no implementation of method %s of protocol %s for type %s",
+				name, p.name, TypeOf(args[0]).Print(true))
+		}
+		return Apply(b, f, args...)
+	})
+}
@@ -0,0 +1,134 @@
+package types
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func constant(v MalType) MalType {
+	return NewFunction("constant", func(args ...MalType) (MalType, error) { return v, nil })
+}
+
+func TestProtocolDispatch(t *testing.T) {
+	p := NewProtocol("Describe", []string{"describe"})
+	describe := p.Method("describe")
+	point := NewRecordType("user", "Point", nil)
+	pt, _ := NewRecord(point)
+
+	if _, err := describe.Eval(nil, NewIntFromInt(1)); err == nil {
+		t.Fatal("calling a method of an unextended protocol did not fail")
+	}
+	p.Extend(ObjectType, map[string]MalType{"describe": constant(NewString("object"))})
+	p.Extend(point, map[string]MalType{"describe": constant(NewString("point"))})
+	p.Extend(NewBuiltinType("Keyword"), map[string]MalType{"describe": constant(NewString("keyword"))})
+
+	for _, c := range []struct {
+		arg  MalType
+		want string
+	}{
+		{NewIntFromInt(1), "object"},
+		{pt, "point"},
+		{NewKeyword("a"), "keyword"},
+		{NewString("a"), "object"},
+	} {
+		got, err := describe.Eval(nil, c.arg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.(*String).Value() != c.want {
+			t.Errorf("describe %s: got %s, want %s", c.arg.Print(true), got.Print(true), c.want)
+		}
+	}
+
+	// Int fell back on Object above; extending the protocol to Int must
+	// not leave that choice in the cache.
+	p.Extend(NewBuiltinType("Int"), map[string]MalType{"describe": constant(NewString("int"))})
+	if got, _ := describe.Eval(nil, NewIntFromInt(1)); got.(*String).Value() != "int" {
+		t.Errorf("describe 1 after extending Int: got %s", got.Print(true))
+	}
+}
+
+// TestProtocolPartialExtend checks that a type extended with only some of
+// the methods of a protocol takes the others from Object, and fails for
+// those Object lacks too.
+func TestProtocolPartialExtend(t *testing.T) {
+	p := NewProtocol("Shape", []string{"area", "name", "corners"})
+	area, name, corners := p.Method("area"), p.Method("name"), p.Method("corners")
+	p.Extend(ObjectType, map[string]MalType{
+		"area": constant(NewIntFromInt(0)),
+		"name": constant(NewString("shape")),
+	})
+	p.Extend(NewBuiltinType("Int"), map[string]MalType{"area": constant(NewIntFromInt(4))})
+
+	for _, c := range []struct {
+		method *Function
+		arg    MalType
+		want   string
+	}{
+		{area, NewIntFromInt(2), "4"},
+		{name, NewIntFromInt(2), `"shape"`},
+		{area, NewString("a"), "0"},
+		{name, NewString("a"), `"shape"`},
+	} {
+		got, err := c.method.Eval(nil, c.arg)
+		if err != nil {
+			t.Errorf("%s %s: %v", c.method.Name(), c.arg.Print(true), err)
+		} else if got.Print(true) != c.want {
+			t.Errorf("%s %s: got %s, want %s", c.method.Name(), c.arg.Print(true), got.Print(true), c.want)
+		}
+	}
+	if _, err := corners.Eval(nil, NewIntFromInt(2)); err == nil {
+		t.Error("corners 2: a method neither Int nor Object implements did not fail")
+	}
+
+	// Extending Object later fills in the method for Int too.
+	p.Extend(ObjectType, map[string]MalType{"corners": constant(NewIntFromInt(0))})
+	if got, err := corners.Eval(nil, NewIntFromInt(2)); err != nil || got.Print(true) != "0" {
+		t.Errorf("corners 2 after extending Object: got %v, %v, want 0", got, err)
+	}
+}
+
+// TestProtocolConcurrent calls a method from several goroutines while the
+// protocol is being extended, for go test -race to check.
+func TestProtocolConcurrent(t *testing.T) {
+	p := NewProtocol("Describe", []string{"describe"})
+	describe := p.Method("describe")
+	p.Extend(ObjectType, map[string]MalType{"describe": constant(NewString("object"))})
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				if _, err := describe.Eval(nil, NewIntFromInt(i)); err != nil {
+					t.Error(err)
+					return
+				}
+				p.Satisfies(NewKeyword("a"))
+				NewBuiltinType(fmt.Sprint("T", i%10))
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		p.Extend(NewBuiltinType(fmt.Sprint("T", i%10)), map[string]MalType{"describe": constant(NewIntFromInt(i))})
+		p.Extend(NewBuiltinType("Int"), map[string]MalType{"describe": constant(NewIntFromInt(i))})
+		p.Extends(NewBuiltinType("Int"))
+	}
+	wg.Wait()
+	if got, _ := describe.Eval(nil, NewIntFromInt(0)); !got.Equal(NewIntFromInt(49)) {
+		t.Errorf("describe 0 after the last extend: got %s, want 49", got.Print(true))
+	}
+}
+
+func BenchmarkProtocolDispatch(b *testing.B) {
+	p := NewProtocol("Describe", []string{"describe"})
+	describe := p.Method("describe")
+	p.Extend(ObjectType, map[string]MalType{"describe": constant(NilValue)})
+	args := []MalType{NewIntFromInt(1), NewString("a"), NewKeyword("a"), NewList()}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sink, _ = describe.Eval(nil, args[i%len(args)])
+	}
+}
@@ -0,0 +1,226 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RecordType is a type defined with defrecord or deftype: a name,
+// qualified by the namespace it was defined in, and the keywords naming its
+// fields, in order.  Every call to defrecord or deftype makes a new type,
+// so types are compared by identity.
+type RecordType struct {
+	ns     string
+	name   string
+	fields []*String
+	// deftype is set for a type defined with deftype, whose values are
+	// Instances rather than Records.
+	deftype bool
+}
+
+func NewRecordType(ns, name string, fields []*String) *RecordType {
+	return &RecordType{ns: ns, name: name, fields: fields}
+}
+
+// NewDeftype returns a type defined with deftype.
+func NewDeftype(ns, name string, fields []*String) *RecordType {
+	return &RecordType{ns: ns, name: name, fields: fields, deftype: true}
+}
+
+func (rt *RecordType) TypeName() string           { return "RecordType" }
+func (rt *RecordType) Print(readably bool) string { return rt.ns + "." + rt.name }
+func (rt *RecordType) Equal(other MalType) bool   { return rt == other }
+func (rt *RecordType) Hash() uint64               { return identityHash(rt) }
+
+func (rt *RecordType) Namespace() string { return rt.ns }
+func (rt *RecordType) Name() string      { return rt.name }
+func (rt *RecordType) Fields() []*String { return rt.fields }
+func (rt *RecordType) IsDeftype() bool   { return rt.deftype }
+
+// field returns the position of the field named by key, or -1 if the type
+// has no such field.
+func (rt *RecordType) field(key MalType) int {
+	for i, f := range rt.fields {
+		if f == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// Record is a value of a RecordType.  It behaves as a map from the field
+// keywords to their values; keys that are not fields of the type can be
+// added with Assoc and are kept in a HashMap of their own.
+type Record struct {
+	rtype  *RecordType
+	values []MalType
+	extra  *HashMap
+	meta   MalType
+}
+
+// NewRecord returns a record of type rt with the given field values, in the
+// order the fields were declared.
+func NewRecord(rt *RecordType, values ...MalType) (*Record, error) {
+	if len(values) != len(rt.fields) {
+		return nil, fmt.Errorf("->%s: wrong number of arguments (%d instead of %d)", rt.name, len(values), len(rt.fields))
+	}
+	return &Record{rt, values, &HashMap{}, nil}, nil
+}
+
+// NewRecordFromMap returns a record of type rt holding the entries of hm.
+// Fields missing from hm are nil.
+func NewRecordFromMap(rt *RecordType, hm *HashMap) *Record {
+	r := &Record{rt, make([]MalType, len(rt.fields)), &HashMap{}, nil}
+	for i := range r.values {
+		r.values[i] = NilValue
+	}
+	for _, e := range hm.entries() {
+		r = r.Assoc(e.key, e.value)
+	}
+	return r
+}
+
+func (r *Record) TypeName() string { return "Record" }
+func (r *Record) Print(readably bool) string {
+	str := []string{}
+	for _, e := range r.Map().entries() {
+		str = append(str, e.key.Print(readably), e.value.Print(readably))
+	}
+	return "#" + r.rtype.Print(readably) + "{" + strings.Join(str, " ") + "}"
+}
+
+func (r *Record) Meta() MalType { return metaOrNil(r.meta) }
+func (r *Record) WithMeta(meta MalType) MalType {
+	return &Record{r.rtype, r.values, r.extra, meta}
+}
+
+func (r *Record) Type() *RecordType { return r.rtype }
+func (r *Record) Length() int       { return len(r.values) + r.extra.Length() }
+
+// A record is only equal to another record of the same type with equal
+// entries; in particular it is never equal to a map.
+func (r *Record) Equal(other MalType) bool {
+	o, ok := other.(*Record)
+	if !ok || r.rtype != o.rtype {
+		return false
+	}
+	for i, v := range r.values {
+		if !v.Equal(o.values[i]) {
+			return false
+		}
+	}
+	return r.extra.Equal(o.extra)
+}
+func (r *Record) Hash() uint64 { return mix(r.rtype.Hash() ^ r.Map().Hash()) }
+
+func (r *Record) Get(key MalType) (MalType, bool) {
+	if i := r.rtype.field(key); i >= 0 {
+		return r.values[i], true
+	}
+	return r.extra.Get(key)
+}
+
+func (r *Record) Assoc(key MalType, value MalType) *Record {
+	if i := r.rtype.field(key); i >= 0 {
+		values := make([]MalType, len(r.values))
+		copy(values, r.values)
+		values[i] = value
+		return &Record{r.rtype, values, r.extra, r.meta}
+	}
+	return &Record{r.rtype, r.values, r.extra.Assoc(key, value), r.meta}
+}
+
+// Dissoc removes key from the record.  Removing a field leaves a value
+// that no longer has the shape of the type, so a plain map is returned
+// instead.
+func (r *Record) Dissoc(key MalType) MalType {
+	if r.rtype.field(key) >= 0 {
+		return r.Map().Dissoc(key)
+	}
+	return &Record{r.rtype, r.values, r.extra.Dissoc(key), r.meta}
+}
+
+// Map returns the entries of the record as a HashMap: the fields in the
+// order they were declared, followed by any other keys.
+func (r *Record) Map() *HashMap {
+	hm := &HashMap{}
+	for i, f := range r.rtype.fields {
+		hm = hm.Assoc(f, r.values[i])
+	}
+	for _, e := range r.extra.entries() {
+		hm = hm.Assoc(e.key, e.value)
+	}
+	return hm
+}
+
+// Like a map, a record is a sequence of [key value] vectors.
+func (r *Record) IsEmpty() (bool, error)  { return r.Length() == 0, nil }
+func (r *Record) First() (MalType, error) { return r.Map().First() }
+func (r *Record) Rest() (Seq, error)      { return r.Map().Rest() }
+
+// Instance is a value of a type defined with deftype.  Unlike a record it
+// is not a map: its fields are read with (.-field x), and it is only equal
+// to itself.
+type Instance struct {
+	itype  *RecordType
+	values []MalType
+}
+
+// NewInstance returns a value of the deftype rt with the given field
+// values, in the order the fields were declared.
+func NewInstance(rt *RecordType, values ...MalType) (*Instance, error) {
+	if len(values) != len(rt.fields) {
+		return nil, fmt.Errorf("->%s: wrong number of arguments (%d instead of %d)", rt.name, len(values), len(rt.fields))
+	}
+	return &Instance{rt, values}, nil
+}
+
+func (in *Instance) TypeName() string { return "Instance" }
+func (in *Instance) Print(readably bool) string {
+	str := make([]string, len(in.values))
+	for i, v := range in.values {
+		str[i] = v.Print(readably)
+	}
+	return "#" + in.itype.Print(readably) + "[" + strings.Join(str, " ") + "]"
+}
+func (in *Instance) Equal(other MalType) bool { return in == other }
+func (in *Instance) Hash() uint64             { return identityHash(in) }
+
+func (in *Instance) Type() *RecordType { return in.itype }
+
+// Values returns the field values, in the order the fields were declared.
+func (in *Instance) Values() []MalType { return in.values }
+
+// Field returns the value of the field named by the keyword key.
+func (in *Instance) Field(key *String) (MalType, bool) {
+	if i := in.itype.field(key); i >= 0 {
+		return in.values[i], true
+	}
+	return nil, false
+}
+
+// fieldReader returns the function a symbol .-field names, which reads
+// field of a deftype instance or a record.
+func fieldReader(field string) *Function {
+	name := ".-" + field
+	key := NewKeyword(field)
+	return NewFunction(name, func(args ...MalType) (MalType, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%s: wrong number of arguments (%d instead of 1)", name, len(args))
+		}
+		var v MalType
+		ok := false
+		switch t := args[0].(type) {
+		case *Instance:
+			v, ok = t.Field(key)
+		case *Record:
+			if i := t.rtype.field(key); i >= 0 {
+				v, ok = t.values[i], true
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("%s: no field %s in %s", name, field, args[0].Print(true))
+		}
+		return v, nil
+	})
+}
@@ -0,0 +1,249 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Seq is implemented by the values that can be walked one item at a time:
+// lists, vectors, maps, sets, strings and the lazy sequences.  Walking a lazy
+// sequence runs the code that produces it, so each step can fail.
+type Seq interface {
+	MalType
+	// IsEmpty reports whether the sequence has no items.
+	IsEmpty() (bool, error)
+	// First returns the first item, or nil if the sequence is empty.
+	First() (MalType, error)
+	// Rest returns the items after the first; it is empty if there are none.
+	Rest() (Seq, error)
+}
+
+// ToSeq returns v as a Seq; nil is treated as the empty list.
+func ToSeq(v MalType) (Seq, error) {
+	switch s := v.(type) {
+	case *Nil:
+		return NewList(), nil
+	case *String:
+		if s.keyword {
+			break
+		}
+		return s, nil
+	case Seq:
+		return s, nil
+	}
+	return nil, fmt.Errorf("not a sequence: %s", v.Print(true))
+}
+
+// seqItems walks s to the end, realizing it if it is lazy.
+func seqItems(s Seq) ([]MalType, error) {
+	r := []MalType{}
+	for {
+		empty, err := s.IsEmpty()
+		if err != nil {
+			return r, err
+		}
+		if empty {
+			return r, nil
+		}
+		v, err := s.First()
+		if err != nil {
+			return r, err
+		}
+		r = append(r, v)
+		if s, err = s.Rest(); err != nil {
+			return r, err
+		}
+	}
+}
+
+// Realize walks v and everything nested in it so that any lazy sequence is
+// fully realized, returning the first error raised while doing so.  Printing
+// cannot fail, so values are realized before they are printed.
+func Realize(v MalType) error {
+	switch c := v.(type) {
+	case *LazySeq, *Cons:
+		items, err := seqItems(c.(Seq))
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			if err := Realize(item); err != nil {
+				return err
+			}
+		}
+	case *List, *Vector, *Set:
+		items, _ := Sequence(v)
+		for _, item := range items {
+			if err := Realize(item); err != nil {
+				return err
+			}
+		}
+	case *Record:
+		return Realize(c.Map())
+	case *HashMap:
+		for _, e := range c.entries() {
+			if err := Realize(e.key); err != nil {
+				return err
+			}
+			if err := Realize(e.value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// BindEnv binds each symbol in bindings, a sequence of symbol and form pairs,
+// to the result of evaluating its form in env.  Each form can refer to the
+// symbols bound before it.
+func BindEnv(bindings Seq, env *Env, eval func(MalType, *Env) (MalType, error)) error {
+	items, err := seqItems(bindings)
+	if err != nil {
+		return err
+	}
+	if len(items)%2 != 0 {
+		return fmt.Errorf("odd number of binding forms: %s", bindings.Print(true))
+	}
+	for i := 0; i < len(items); i += 2 {
+		key, ok := items[i].(*Symbol)
+		if !ok {
+			return fmt.Errorf("attempting to bind to a non symbol: %s", items[i].Print(true))
+		}
+		val, err := eval(items[i+1], env)
+		if err != nil {
+			return err
+		}
+		env.Set(key, val)
+	}
+	return nil
+}
+
+func mapItems(items []MalType, f func(arg MalType, env *Env) (MalType, error), env *Env) ([]MalType, error) {
+	r := make([]MalType, 0, len(items))
+	for _, v := range items {
+		item, err := f(v, env)
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, item)
+	}
+	return r, nil
+}
+
+func printSeq(s Seq, readably bool) string {
+	items, err := seqItems(s)
+	str := make([]string, 0, len(items)+1)
+	for _, v := range items {
+		str = append(str, v.Print(readably))
+	}
+	if err != nil {
+		str = append(str, "#<error: "+err.Error()+">")
+	}
+	return "(" + strings.Join(str, " ") + ")"
+}
+
+// Cons is a sequence made of a first item and the rest of the sequence.  It
+// lets an item be put in front of a lazy sequence without realizing it.
+type Cons struct {
+	first MalType
+	rest  Seq
+	meta  MalType
+}
+
+func NewCons(first MalType, rest Seq) *Cons {
+	return &Cons{first: first, rest: rest}
+}
+
+func (c *Cons) TypeName() string           { return "Cons" }
+func (c *Cons) Print(readably bool) string { return printSeq(c, readably) }
+func (c *Cons) Equal(other MalType) bool   { return seqEqual(c, other) }
+func (c *Cons) Hash() uint64               { return seqHash(c) }
+
+func (c *Cons) Meta() MalType { return metaOrNil(c.meta) }
+func (c *Cons) WithMeta(meta MalType) MalType {
+	return &Cons{c.first, c.rest, meta}
+}
+
+func (c *Cons) IsEmpty() (bool, error)  { return false, nil }
+func (c *Cons) First() (MalType, error) { return c.first, nil }
+func (c *Cons) Rest() (Seq, error)      { return c.rest, nil }
+
+// LazySeq is a sequence whose items are produced on demand.  The first time
+// it is walked it calls its producer, which returns a sequence or nil, and
+// from then on it behaves as that sequence.  A producer that fails is called
+// again the next time the sequence is walked.
+type LazySeq struct {
+	producer func() (MalType, error)
+	seq      Seq
+	meta     MalType
+}
+
+func NewLazySeq(producer func() (MalType, error)) *LazySeq {
+	return &LazySeq{producer: producer}
+}
+
+func (ls *LazySeq) TypeName() string           { return "LazySeq" }
+func (ls *LazySeq) Print(readably bool) string { return printSeq(ls, readably) }
+func (ls *LazySeq) Equal(other MalType) bool   { return seqEqual(ls, other) }
+func (ls *LazySeq) Hash() uint64               { return seqHash(ls) }
+
+func (ls *LazySeq) Meta() MalType { return metaOrNil(ls.meta) }
+func (ls *LazySeq) WithMeta(meta MalType) MalType {
+	return &LazySeq{ls.producer, ls.seq, meta}
+}
+
+// realize runs the producer if that has not been done yet.  A producer that
+// returns another LazySeq is unwrapped here, in a loop, so that long chains
+// of empty lazy sequences do not use up the stack.
+func (ls *LazySeq) realize() (Seq, error) {
+	if ls.seq != nil {
+		return ls.seq, nil
+	}
+	v, err := ls.producer()
+	for err == nil {
+		inner, ok := v.(*LazySeq)
+		if !ok {
+			break
+		}
+		if inner.seq != nil {
+			v = inner.seq
+			break
+		}
+		v, err = inner.producer()
+	}
+	if err != nil {
+		return nil, err
+	}
+	s, err := ToSeq(v)
+	if err != nil {
+		return nil, errors.New("lazy-seq: body did not return a sequence: " + v.Print(true))
+	}
+	ls.seq = s
+	ls.producer = nil
+	return s, nil
+}
+
+func (ls *LazySeq) IsEmpty() (bool, error) {
+	s, err := ls.realize()
+	if err != nil {
+		return false, err
+	}
+	return s.IsEmpty()
+}
+
+func (ls *LazySeq) First() (MalType, error) {
+	s, err := ls.realize()
+	if err != nil {
+		return nil, err
+	}
+	return s.First()
+}
+
+func (ls *LazySeq) Rest() (Seq, error) {
+	s, err := ls.realize()
+	if err != nil {
+		return nil, err
+	}
+	return s.Rest()
+}
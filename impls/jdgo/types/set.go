@@ -0,0 +1,117 @@
+package types
+
+import (
+	"strings"
+)
+
+// Set is a persistent set of distinct values.  It is backed by a HashMap
+// from each member to itself, so members are hashed and compared the same
+// way as map keys and are listed in the order selected with SetMapOrder.
+type Set struct {
+	members *HashMap
+	meta    MalType
+}
+
+func NewSet(items ...MalType) *Set {
+	s := &Set{members: &HashMap{}}
+	for _, item := range items {
+		s = s.Conj(item)
+	}
+	return s
+}
+
+func (s *Set) TypeName() string { return "Set" }
+func (s *Set) Print(readably bool) string {
+	str := []string{}
+	for _, v := range s.Items() {
+		str = append(str, v.Print(readably))
+	}
+	return "#{" + strings.Join(str, " ") + "}"
+}
+
+func (s *Set) Meta() MalType { return metaOrNil(s.meta) }
+func (s *Set) WithMeta(meta MalType) MalType {
+	return &Set{s.members, meta}
+}
+
+func (s *Set) Length() int { return s.members.Length() }
+
+func (s *Set) Equal(other MalType) bool {
+	o, ok := other.(*Set)
+	if !ok || s.Length() != o.Length() {
+		return false
+	}
+	for _, v := range s.Items() {
+		if !o.Contains(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// Hash sums the hashes of the members so that it does not depend on their
+// order.
+func (s *Set) Hash() uint64 {
+	var h uint64 = 0x5e7
+	s.members.root.each(func(e *mapEntry) {
+		h += e.key.Hash()
+	})
+	return mix(h)
+}
+
+// Get returns the member equal to v, if there is one.
+func (s *Set) Get(v MalType) (MalType, bool) {
+	return s.members.Get(v)
+}
+func (s *Set) Contains(v MalType) bool {
+	_, ok := s.members.Get(v)
+	return ok
+}
+
+// Conj returns a new Set with v added.
+func (s *Set) Conj(v MalType) *Set {
+	if s.Contains(v) {
+		return s
+	}
+	return &Set{s.members.Assoc(v, v), s.meta}
+}
+
+// Disj returns a new Set without v.
+func (s *Set) Disj(v MalType) *Set {
+	return &Set{s.members.Dissoc(v), s.meta}
+}
+
+// Items returns the members in the order selected with SetMapOrder.
+func (s *Set) Items() []MalType {
+	return s.members.Keys()
+}
+
+func (s *Set) IsEmpty() (bool, error) { return s.Length() == 0, nil }
+func (s *Set) First() (MalType, error) {
+	items := s.Items()
+	if len(items) == 0 {
+		return NilValue, nil
+	}
+	return items[0], nil
+}
+func (s *Set) Rest() (Seq, error) {
+	items := s.Items()
+	if len(items) == 0 {
+		return NewList(), nil
+	}
+	return NewList(items[1:]...), nil
+}
+
+func (s *Set) Map(f func(arg MalType, env *Env) (MalType, error), env *Env) (MalType, error) {
+	r := NewSet()
+
+	for _, v := range s.Items() {
+		item, err := f(v, env)
+		if err != nil {
+			return nil, err
+		}
+		r = r.Conj(item)
+	}
+
+	return r, nil
+}
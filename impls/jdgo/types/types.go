@@ -3,40 +3,146 @@ package types
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
+// MalType is implemented by every mal value.  Print renders the value; when
+// readably is true strings are quoted and escaped so the result can be read
+// back, otherwise they are printed as is (as str and println do).  Equal and
+// Hash compare values structurally; see equal.go.
 type MalType interface {
 	TypeName() string
-	Print() string
+	Print(readably bool) string
+	Equal(other MalType) bool
+	Hash() uint64
 }
+
+// Metadatable is implemented by the values that can carry metadata: the
+// collections and functions.  WithMeta returns a copy of the value with the
+// new metadata attached; the original is left untouched.
+type Metadatable interface {
+	MalType
+	Meta() MalType
+	WithMeta(meta MalType) MalType
+}
+
+// Callable is implemented by values that can be applied to arguments.  b
+// holds the dynamic var bindings of the caller, which are in effect for the
+// call.
+type Callable interface {
+	MalType
+	Eval(b *Bindings, args ...MalType) (MalType, error)
+}
+
+// Associative is implemented by the values that map keys to values: maps,
+// records, and sets, which map each member to itself.
+type Associative interface {
+	MalType
+	Get(key MalType) (MalType, bool)
+}
+
+func metaOrNil(meta MalType) MalType {
+	if meta == nil {
+		return NilValue
+	}
+	return meta
+}
+
+// Env maps symbols to values.  Symbols are interned, so they are used as
+// keys directly rather than by name.  The outermost Envs of a chain belong
+// to namespaces; see Namespace.
 type Env struct {
 	outer *Env
-	items map[string]MalType
+	items map[*Symbol]MalType
+	ns    *Namespace
 }
 
 func NewEnv(outer *Env) *Env {
-	return &Env{outer, map[string]MalType{}}
+	return &Env{outer, map[*Symbol]MalType{}, nil}
+}
+
+// Namespace returns the namespace env belongs to, or nil if it is not part
+// of one.
+func (env *Env) Namespace() *Namespace {
+	for e := env; e != nil; e = e.outer {
+		if e.ns != nil {
+			return e.ns
+		}
+	}
+	return nil
+}
+
+// Global returns the Env that def! defines in from env: that of the
+// namespace env belongs to, or the outermost Env if it is not part of one.
+func (env *Env) Global() *Env {
+	e := env
+	for e.ns == nil && e.outer != nil {
+		e = e.outer
+	}
+	return e
 }
 
 func (env *Env) Set(k *Symbol, v MalType) {
-	env.items[k.value] = v
+	env.items[k] = v
 }
 
 func (env *Env) Find(k *Symbol) (MalType, error) {
-	workingEnv := env
-	for {
-		v, ok := workingEnv.items[k.value]
-		if ok {
-			return v, nil
+	return env.FindBound(k, nil)
+}
+
+// FindBound is like Find but gives a dynamic var the value b binds it to.
+func (env *Env) FindBound(k *Symbol, b *Bindings) (MalType, error) {
+	if v, ok := env.LookupBound(k, b); ok {
+		return v, nil
+	}
+	return nil, fmt.Errorf("'%s' not found", k.value)
+}
+
+// Lookup is like Find but reports a missing symbol with ok rather than an
+// error, which is cheaper when a miss is expected.  A symbol that is not
+// defined is looked up in the namespace it refers to, if any, and a symbol
+// qualified with a namespace or alias, like str/join, in that namespace.
+// A symbol .-field that is not defined names the function that reads field
+// of a deftype instance or a record.  The value of a dynamic var is its
+// root value.
+func (env *Env) Lookup(k *Symbol) (MalType, bool) {
+	return env.LookupBound(k, nil)
+}
+
+// LookupBound is like Lookup but gives a dynamic var the value b binds it
+// to.
+func (env *Env) LookupBound(k *Symbol, b *Bindings) (MalType, bool) {
+	v, ok := env.lookup(k)
+	if dv, isVar := v.(*Var); isVar {
+		return dv.Value(b), true
+	}
+	return v, ok
+}
+
+// lookup is Lookup without dereferencing dynamic vars.
+func (env *Env) lookup(k *Symbol) (MalType, bool) {
+	for e := env; e != nil; e = e.outer {
+		if v, ok := e.items[k]; ok {
+			return v, true
 		}
-		workingEnv = workingEnv.outer
-		if workingEnv == nil {
-			break
+		if e.ns != nil {
+			if from, ok := e.ns.refers[k]; ok {
+				return from.public(k, e.ns)
+			}
 		}
 	}
-	return nil, fmt.Errorf("'%s' not found", k.value)
+	if ns := env.Namespace(); ns != nil {
+		if v, ok := lookupQualified(k, ns); ok {
+			return v, true
+		}
+	}
+	if field, ok := strings.CutPrefix(k.value, ".-"); ok && field != "" {
+		return fieldReader(field), true
+	}
+	return nil, false
 }
 
 func (env *Env) Get(k *Symbol) (MalType, error) {
@@ -45,18 +151,19 @@ func (env *Env) Get(k *Symbol) (MalType, error) {
 
 type List struct {
 	items []MalType
+	meta  MalType
 }
 
 func NewList(items ...MalType) *List {
-	return &List{items}
+	return &List{items: items}
 }
 
 func (list *List) TypeName() string { return "List" }
-func (list *List) Print() string {
+func (list *List) Print(readably bool) string {
 	var b strings.Builder
 	b.WriteString("(")
 	for _, v := range list.items {
-		b.WriteString(v.Print())
+		b.WriteString(v.Print(readably))
 		b.WriteString(" ")
 	}
 	r := b.String()
@@ -64,193 +171,130 @@ func (list *List) Print() string {
 	return fmt.Sprintf("%s)", r)
 }
 
-func (list *List) Append(form MalType) {
-	list.items = append(list.items, form)
+func (list *List) Meta() MalType { return metaOrNil(list.meta) }
+func (list *List) WithMeta(meta MalType) MalType {
+	return &List{list.items, meta}
 }
-func (list *List) Map(f func(arg MalType, env *Env) (MalType, error), env *Env) (MalType, error) {
-	r := []MalType{}
 
-	for _, v := range list.items {
-		item, err := f(v, env)
-		if err != nil {
-			return nil, err
-		}
-		r = append(r, item)
-	}
+func (list *List) Equal(other MalType) bool { return seqEqual(list, other) }
+func (list *List) Hash() uint64             { return seqHash(list) }
 
-	return &List{r}, nil
+func (list *List) Items() []MalType { return list.items }
+func (list *List) Map(f func(arg MalType, env *Env) (MalType, error), env *Env) (MalType, error) {
+	r, err := mapItems(list.items, f, env)
+	if err != nil {
+		return nil, err
+	}
+	return &List{items: r}, nil
 }
-func (list *List) Length() int { return len(list.items) }
+func (list *List) Length() int            { return len(list.items) }
+func (list *List) IsEmpty() (bool, error) { return len(list.items) == 0, nil }
 func (list *List) First() (MalType, error) {
-	if list.Length() == 0 {
-		return nil, errors.New("can't take first of empty list")
+	if len(list.items) == 0 {
+		return NilValue, nil
 	}
 	return list.items[0], nil
 }
-func (list *List) Rest() ([]MalType, error) {
-	if list.Length() == 0 {
-		return nil, errors.New("can't take first of empty list")
-	}
-	return list.items[1:], nil
-}
-func (list *List) BindEnv(env *Env, eval func(MalType, *Env) (MalType, error)) error {
-	for i := 0; i < len(list.items); i += 2 {
-		keyObj := list.items[i]
-		key, ok := keyObj.(*Symbol)
-		if !ok {
-			return fmt.Errorf("attempting to bind to a non symbol: %s", keyObj.Print())
-		}
-		val, err := eval(list.items[i+1], env)
-		if err != nil {
-			return err
-		}
-		env.Set(key, val)
+func (list *List) Rest() (Seq, error) {
+	if len(list.items) == 0 {
+		return NewList(), nil
 	}
-	return nil
+	return &List{items: list.items[1:]}, nil
 }
 
-type Vector struct {
-	items []MalType
+type Symbol struct {
+	value string
+	hash  uint64
 }
 
-func NewVector() *Vector {
-	return &Vector{}
-}
+func (sym *Symbol) TypeName() string           { return "Symbol" }
+func (sym *Symbol) Print(readably bool) string { return sym.value }
+func (sym *Symbol) Equal(other MalType) bool   { return sym == other }
+func (sym *Symbol) Hash() uint64               { return sym.hash }
 
-func (vec *Vector) TypeName() string { return "Vector" }
-func (vec *Vector) Print() string {
-	vv := []string{}
-	for _, v := range vec.items {
-		vv = append(vv, v.Print())
-	}
-	return "[" + strings.Join(vv, " ") + "]"
+type String struct {
+	value   string
+	keyword bool
 }
 
-func (vec *Vector) Append(form MalType) {
-	vec.items = append(vec.items, form)
+func NewString(value string) *String {
+	return &String{value, false}
 }
 
-func (vec *Vector) Map(f func(arg MalType, env *Env) (MalType, error), env *Env) (MalType, error) {
-	r := []MalType{}
-
-	for _, v := range vec.items {
-		item, err := f(v, env)
-		if err != nil {
-			return nil, err
-		}
-		r = append(r, item)
+func (str *String) TypeName() string { return "String" }
+func (str *String) Value() string    { return str.value }
+func (str *String) IsKeyword() bool  { return str.keyword }
+
+// Keywords are interned, so they are equal only to themselves.
+func (str *String) Equal(other MalType) bool {
+	o, ok := other.(*String)
+	if !ok || str.keyword || o.keyword {
+		return str == other
 	}
-
-	return &Vector{r}, nil
+	return str.value == o.value
 }
-func (vec *Vector) Length() int { return len(vec.items) }
-func (vec *Vector) First() (MalType, error) {
-	if vec.Length() == 0 {
-		return nil, errors.New("can't take first of empty list")
+func (str *String) Hash() uint64 {
+	if str.keyword {
+		return hashString(":" + str.value)
 	}
-	return vec.items[0], nil
+	return hashString(`"` + str.value)
 }
-func (vec *Vector) Rest() ([]MalType, error) {
-	if vec.Length() == 0 {
-		return nil, errors.New("can't take first of empty list")
+
+// A keyword can be called to look itself up in a map or record, as in
+// (:k m) or (:k m default).  Other strings cannot be called.
+func (str *String) Eval(_ *Bindings, args ...MalType) (MalType, error) {
+	if !str.keyword {
+		return nil, fmt.Errorf("%s is not a function", str.Print(true))
 	}
-	return vec.items[1:], nil
-}
-func (vec *Vector) BindEnv(env *Env, eval func(MalType, *Env) (MalType, error)) error {
-	for i := 0; i < len(vec.items); i += 2 {
-		keyObj := vec.items[i]
-		key, ok := keyObj.(*Symbol)
-		if !ok {
-			return fmt.Errorf("attempting to bind to a non symbol: %s", keyObj.Print())
-		}
-		val, err := eval(vec.items[i+1], env)
-		if err != nil {
-			return err
-		}
-		env.Set(key, val)
+	if len(args) != 1 && len(args) != 2 {
+		return nil, fmt.Errorf("%s: wrong number of arguments (%d instead of 1 or 2)", str.Print(true), len(args))
 	}
-	return nil
-}
-
-type HashMap struct {
-	items map[String]MalType
-}
-
-func NewHashMap(forms []MalType) *HashMap {
-	items := map[String]MalType{}
-
-	for i := 0; i < len(forms); i += 2 {
-		k := forms[i]
-		v := forms[i+1]
-		key, ok := k.(*String)
-		if ok {
-			items[*key] = v
-		} else {
-			fmt.Printf("WTF: %T %v", k, k)
+	if coll, ok := args[0].(Associative); ok {
+		if v, ok := coll.Get(str); ok {
+			return v, nil
 		}
 	}
-	return &HashMap{items}
-}
-
-func (hm *HashMap) TypeName() string { return "HashMap" }
-func (hm *HashMap) Print() string {
-	str := []string{}
-	for k, v := range hm.items {
-		str = append(str, k.Print())
-		str = append(str, v.Print())
+	if len(args) == 2 {
+		return args[1], nil
 	}
-	return "{" + strings.Join(str, " ") + "}"
+	return NilValue, nil
 }
 
-func (hm *HashMap) Set(key String, form MalType) {
-	hm.items[key] = form
+// A string is a sequence of one character strings; a keyword is not a
+// sequence and has no items.
+func (str *String) IsEmpty() (bool, error) { return str.keyword || str.value == "", nil }
+func (str *String) First() (MalType, error) {
+	if str.keyword || str.value == "" {
+		return NilValue, nil
+	}
+	c, _ := utf8.DecodeRuneInString(str.value)
+	return NewString(string(c)), nil
 }
-func (hm *HashMap) Map(f func(arg MalType, env *Env) (MalType, error), env *Env) (MalType, error) {
-	r := map[String]MalType{}
-
-	for k, v := range hm.items {
-		item, err := f(v, env)
-		if err != nil {
-			return nil, err
+func (str *String) Rest() (Seq, error) {
+	if str.keyword || str.value == "" {
+		return NewList(), nil
+	}
+	r := []MalType{}
+	for i, c := range str.value {
+		if i > 0 {
+			r = append(r, NewString(string(c)))
 		}
-		r[k] = item
 	}
-
-	return &HashMap{r}, nil
-}
-func (hm *HashMap) Length() int { return len(hm.items) }
-
-type Symbol struct {
-	value string
+	return NewList(r...), nil
 }
-
-func NewSymbol(value string) *Symbol {
-	return &Symbol{value}
-}
-
-func (sym *Symbol) TypeName() string { return "Symbol" }
-func (sym *Symbol) Print() string    { return sym.value }
-
-type String struct {
-	value   string
-	keyword bool
-}
-
-func NewString(value string) *String {
-	return &String{value, false}
-}
-
-func (str *String) TypeName() string { return "String" }
-func (str *String) Print() string {
-	if !str.keyword {
-		return `"` + strings.Replace(
-			strings.Replace(
-				strings.Replace(str.value, `\`, `\\`, -1),
-				`"`, `\"`, -1),
-			"\n", `\n`, -1) + `"`
+func (str *String) Print(readably bool) string {
+	if str.keyword {
+		return fmt.Sprintf(":%s", str.value)
+	}
+	if !readably {
+		return str.value
 	}
 
-	return fmt.Sprintf(":%s", str.value)
+	return `"` + strings.Replace(
+		strings.Replace(
+			strings.Replace(str.value, `\`, `\\`, -1),
+			`"`, `\"`, -1),
+		"\n", `\n`, -1) + `"`
 }
 
 type Int struct {
@@ -269,26 +313,71 @@ func NewIntFromInt(i int) *Int {
 	return &Int{value: i}
 }
 
-func (i *Int) TypeName() string { return "Int" }
-func (i *Int) Print() string    { return fmt.Sprintf("%d", i.value) }
-func (i *Int) AsInt() int       { return i.value }
+func (i *Int) TypeName() string           { return "Int" }
+func (i *Int) Print(readably bool) string { return fmt.Sprintf("%d", i.value) }
+func (i *Int) AsInt() int                 { return i.value }
+func (i *Int) Equal(other MalType) bool {
+	switch o := other.(type) {
+	case *Int:
+		return i.value == o.value
+	case *Float:
+		return float64(i.value) == o.value && int(o.value) == i.value
+	}
+	return false
+}
+func (i *Int) Hash() uint64 { return mix(uint64(i.value)) }
 
 type Float struct {
 	value float64
 }
 
-func (f *Float) TypeName() string { return "Float" }
-func (f *Float) Print() string    { return fmt.Sprintf("%f", f.value) }
+func NewFloat(f float64) *Float {
+	return &Float{value: f}
+}
+
+func (f *Float) TypeName() string           { return "Float" }
+func (f *Float) Print(readably bool) string { return fmt.Sprintf("%f", f.value) }
+func (f *Float) Value() float64             { return f.value }
+func (f *Float) Equal(other MalType) bool {
+	switch o := other.(type) {
+	case *Float:
+		return f.value == o.value
+	case *Int:
+		return o.Equal(f)
+	}
+	return false
+}
+func (f *Float) Hash() uint64 { return floatHash(f.value) }
 
 type Boolean struct {
 	value bool
 }
 
+// NilValue, TrueValue and FalseValue are the only nil and boolean values:
+// nothing else of type *Nil or *Boolean is ever made, so they can be
+// compared by identity.
+var (
+	NilValue   = &Nil{}
+	TrueValue  = &Boolean{true}
+	FalseValue = &Boolean{false}
+)
+
+// NewBoolean returns TrueValue or FalseValue.
 func NewBoolean(value bool) *Boolean {
-	return &Boolean{value}
+	if value {
+		return TrueValue
+	}
+	return FalseValue
+}
+func (b *Boolean) TypeName() string         { return "Boolean" }
+func (b *Boolean) Equal(other MalType) bool { return b == other }
+func (b *Boolean) Hash() uint64 {
+	if b.value {
+		return mix(1)
+	}
+	return mix(2)
 }
-func (b *Boolean) TypeName() string { return "Boolean" }
-func (b *Boolean) Print() string {
+func (b *Boolean) Print(readably bool) string {
 	if b.value {
 		return "true"
 	}
@@ -298,23 +387,293 @@ func (b *Boolean) Print() string {
 type Nil struct {
 }
 
-func (n *Nil) TypeName() string { return "Nil" }
-func (n *Nil) Print() string    { return "nil" }
-func NewKeyword(value string) *String {
-	return &String{strings.TrimLeft(value, ":"), true}
-}
+func (n *Nil) TypeName() string           { return "Nil" }
+func (n *Nil) Print(readably bool) string { return "nil" }
+func (n *Nil) Equal(other MalType) bool   { return n == other }
+func (n *Nil) Hash() uint64               { return mix(0) }
 
 type Function struct {
 	name string
-	f    func(...MalType) (MalType, error)
+	f    func(*Bindings, ...MalType) (MalType, error)
+	meta MalType
+	// base is the function WithMeta made f from, if any.
+	base *Function
 }
 
 func NewFunction(name string, f func(...MalType) (MalType, error)) *Function {
-	return &Function{name, f}
+	return &Function{name: name, f: func(_ *Bindings, args ...MalType) (MalType, error) { return f(args...) }}
+}
+
+// NewDynamicFunction returns a Function that is given the dynamic var
+// bindings of its caller, as a builtin that calls the functions it is given
+// must be, to call them with.
+func NewDynamicFunction(name string, f func(*Bindings, ...MalType) (MalType, error)) *Function {
+	return &Function{name: name, f: f}
+}
+
+func (f *Function) TypeName() string           { return "Function" }
+func (f *Function) Equal(other MalType) bool   { return f == other }
+func (f *Function) Hash() uint64               { return identityHash(f) }
+func (f *Function) Print(readably bool) string { return f.name }
+func (f *Function) Name() string               { return f.name }
+func (f *Function) Meta() MalType              { return metaOrNil(f.meta) }
+func (f *Function) WithMeta(meta MalType) MalType {
+	return &Function{f.name, f.f, meta, f.Base()}
 }
 
-func (f *Function) TypeName() string { return "Function" }
-func (f *Function) Print() string    { return f.name }
-func (f *Function) Eval(args ...MalType) (MalType, error) {
-	return f.f(args...)
+// Base returns the function f was made from by giving it metadata, or f.
+func (f *Function) Base() *Function {
+	if f.base != nil {
+		return f.base
+	}
+	return f
+}
+func (f *Function) Eval(b *Bindings, args ...MalType) (MalType, error) {
+	return f.f(b, args...)
+}
+
+// Closure is a function defined in mal with fn*.  The body is evaluated by
+// the interpreter's eval function in a new Env binding the parameters, or,
+// if the interpreter compiled it, run by its Code.
+type Closure struct {
+	params  []*Symbol
+	rest    *Symbol
+	body    MalType
+	env     *Env
+	eval    func(MalType, *Env, *Bindings) (MalType, error)
+	code    Code
+	isMacro bool
+	meta    MalType
+}
+
+// Code is the compiled form of a closure, which runs its body with the
+// parameters bound to args and the dynamic vars bound by b.
+type Code interface {
+	Call(b *Bindings, args ...MalType) (MalType, error)
+}
+
+// SourceCode is Code that can describe the closure it runs.
+type SourceCode interface {
+	Code
+	Source() ClosureSource
+}
+
+// ClosureSource describes a closure by what it was made from, so that it
+// can be saved and made again: the parameters and body of its fn*, the Env
+// its globals are looked up in, and the local variables it captured,
+// innermost first and each only once.
+type ClosureSource struct {
+	Params MalType
+	Body   MalType
+	Env    *Env
+	Names  []*Symbol
+	Values []MalType
+}
+
+// NewCompiledClosure returns a Closure that code runs.
+func NewCompiledClosure(code Code) *Closure {
+	return &Closure{code: code}
+}
+
+func NewClosure(params MalType, body MalType, env *Env, eval func(MalType, *Env, *Bindings) (MalType, error)) (*Closure, error) {
+	var forms []MalType
+	switch p := params.(type) {
+	case *List:
+		forms = p.items
+	case *Vector:
+		forms = p.Items()
+	default:
+		return nil, fmt.Errorf("fn* parameters must be a list or a vector: %s", params.Print(true))
+	}
+
+	c := &Closure{body: body, env: env, eval: eval}
+	for i := 0; i < len(forms); i++ {
+		sym, ok := forms[i].(*Symbol)
+		if !ok {
+			return nil, fmt.Errorf("fn* parameter is not a symbol: %s", forms[i].Print(true))
+		}
+		if sym.value == "&" {
+			if i != len(forms)-2 {
+				return nil, errors.New("fn* expects exactly one parameter after &")
+			}
+			rest, ok := forms[i+1].(*Symbol)
+			if !ok {
+				return nil, fmt.Errorf("fn* parameter is not a symbol: %s", forms[i+1].Print(true))
+			}
+			c.rest = rest
+			break
+		}
+		c.params = append(c.params, sym)
+	}
+	return c, nil
+}
+
+func (c *Closure) TypeName() string           { return "Closure" }
+func (c *Closure) Equal(other MalType) bool   { return c == other }
+func (c *Closure) Hash() uint64               { return identityHash(c) }
+func (c *Closure) Print(readably bool) string { return "#<function>" }
+func (c *Closure) Meta() MalType              { return metaOrNil(c.meta) }
+func (c *Closure) WithMeta(meta MalType) MalType {
+	r := *c
+	r.meta = meta
+	return &r
+}
+func (c *Closure) Body() MalType { return c.body }
+func (c *Closure) Code() Code    { return c.code }
+
+// Source describes c, or reports false if it was compiled to Code that
+// cannot describe it.
+func (c *Closure) Source() (ClosureSource, bool) {
+	if c.code != nil {
+		sc, ok := c.code.(SourceCode)
+		if !ok {
+			return ClosureSource{}, false
+		}
+		return sc.Source(), true
+	}
+	params := make([]MalType, 0, len(c.params)+2)
+	for _, p := range c.params {
+		params = append(params, p)
+	}
+	if c.rest != nil {
+		params = append(params, NewSymbol("&"), c.rest)
+	}
+	src := ClosureSource{Params: NewList(params...), Body: c.body}
+	seen := map[*Symbol]bool{}
+	env := c.env
+	for ; env != nil && env.ns == nil; env = env.outer {
+		names := make([]*Symbol, 0, len(env.items))
+		for k := range env.items {
+			if !seen[k] {
+				names = append(names, k)
+				seen[k] = true
+			}
+		}
+		sort.Slice(names, func(i, j int) bool { return names[i].value < names[j].value })
+		for _, k := range names {
+			src.Names = append(src.Names, k)
+			src.Values = append(src.Values, env.items[k])
+		}
+	}
+	src.Env = env
+	return src, true
+}
+func (c *Closure) IsMacro() bool { return c.isMacro }
+func (c *Closure) AsMacro() *Closure {
+	r := *c
+	r.isMacro = true
+	return &r
+}
+
+// Bind returns a new Env, enclosed by the one the closure was defined in,
+// with the parameters bound to args.
+func (c *Closure) Bind(args ...MalType) (*Env, error) {
+	if len(args) < len(c.params) || (c.rest == nil && len(args) > len(c.params)) {
+		return nil, fmt.Errorf("wrong number of arguments (%d instead of %d)", len(args), len(c.params))
+	}
+	env := NewEnv(c.env)
+	for i, p := range c.params {
+		env.Set(p, args[i])
+	}
+	if c.rest != nil {
+		rest := make([]MalType, len(args)-len(c.params))
+		copy(rest, args[len(c.params):])
+		env.Set(c.rest, NewList(rest...))
+	}
+	return env, nil
+}
+func (c *Closure) Eval(b *Bindings, args ...MalType) (MalType, error) {
+	if c.code != nil {
+		return c.code.Call(b, args...)
+	}
+	env, err := c.Bind(args...)
+	if err != nil {
+		return nil, err
+	}
+	return c.eval(c.body, env, b)
+}
+
+// Apply calls f, which must be a Function or Closure, with args and the
+// dynamic var bindings b.
+func Apply(b *Bindings, f MalType, args ...MalType) (MalType, error) {
+	fn, ok := f.(Callable)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a function", f.Print(true))
+	}
+	return fn.Eval(b, args...)
+}
+
+type Atom struct {
+	value MalType
+}
+
+func NewAtom(value MalType) *Atom {
+	return &Atom{value}
+}
+
+func (a *Atom) TypeName() string         { return "Atom" }
+func (a *Atom) Equal(other MalType) bool { return a == other }
+func (a *Atom) Hash() uint64             { return identityHash(a) }
+func (a *Atom) Print(readably bool) string {
+	return "(atom " + a.value.Print(readably) + ")"
+}
+func (a *Atom) Deref() MalType { return a.value }
+func (a *Atom) Reset(value MalType) {
+	a.value = value
+}
+
+// Reduced wraps the result of a reducing function to tell reduce to stop
+// early and return that result.
+type Reduced struct {
+	value MalType
+}
+
+func NewReduced(value MalType) *Reduced {
+	return &Reduced{value}
+}
+
+func (r *Reduced) TypeName() string         { return "Reduced" }
+func (r *Reduced) Equal(other MalType) bool { return r == other }
+func (r *Reduced) Hash() uint64             { return identityHash(r) }
+func (r *Reduced) Print(readably bool) string {
+	return "(reduced " + r.value.Print(readably) + ")"
+}
+func (r *Reduced) Deref() MalType { return r.value }
+
+// MalError is the error returned when mal code throws a value.
+type MalError struct {
+	value MalType
+}
+
+func NewMalError(value MalType) *MalError {
+	return &MalError{value}
+}
+
+func (e *MalError) Error() string  { return e.value.Print(true) }
+func (e *MalError) Value() MalType { return e.value }
+
+// Truthy reports whether v counts as true in a conditional: everything but
+// nil and false does.
+func Truthy(v MalType) bool {
+	return v != NilValue && v != FalseValue
+}
+
+// Sequence returns the items of a Seq, realizing it if it is lazy; nil is
+// treated as empty.
+func Sequence(v MalType) ([]MalType, error) {
+	switch s := v.(type) {
+	case *List:
+		return s.items, nil
+	case *Vector:
+		return s.Items(), nil
+	case *Set:
+		return s.Items(), nil
+	case *Nil:
+		return nil, nil
+	}
+	s, err := ToSeq(v)
+	if err != nil {
+		return nil, err
+	}
+	return seqItems(s)
 }
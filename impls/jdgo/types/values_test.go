@@ -0,0 +1,24 @@
+package types
+
+import "testing"
+
+var sink MalType
+
+func BenchmarkNewBoolean(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sink = NewBoolean(i&1 == 0)
+	}
+}
+
+func BenchmarkTruthy(b *testing.B) {
+	values := []MalType{TrueValue, FalseValue, NilValue, NewIntFromInt(0), NewString("")}
+	b.ReportAllocs()
+	n := 0
+	for i := 0; i < b.N; i++ {
+		if Truthy(values[i%len(values)]) {
+			n++
+		}
+	}
+	sink = NewIntFromInt(n)
+}
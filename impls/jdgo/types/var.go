@@ -0,0 +1,102 @@
+package types
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// Var is a dynamic var: a definition, made with (def! ^:dynamic name value),
+// whose value binding can change for the dynamic extent of a call.  An Env
+// holds the Var itself.  Its value is the innermost one a Bindings gives it,
+// or else its root value.
+type Var struct {
+	ns   *Namespace
+	sym  *Symbol
+	root atomic.Value
+}
+
+// rootValue wraps a Var's root value so values of different types can be
+// stored in the same atomic.Value.
+type rootValue struct{ v MalType }
+
+func NewVar(ns *Namespace, sym *Symbol, root MalType) *Var {
+	v := &Var{ns: ns, sym: sym}
+	v.SetRoot(root)
+	return v
+}
+
+func (v *Var) TypeName() string { return "Var" }
+func (v *Var) Print(readably bool) string {
+	if v.ns == nil {
+		return "#'" + v.sym.value
+	}
+	return "#'" + v.ns.name + "/" + v.sym.value
+}
+func (v *Var) Equal(other MalType) bool { return v == other }
+func (v *Var) Hash() uint64             { return identityHash(v) }
+
+// Root returns the value of v when it is not bound.
+func (v *Var) Root() MalType { return v.root.Load().(rootValue).v }
+
+// SetRoot changes the value of v when it is not bound, as redefining it
+// does.
+func (v *Var) SetRoot(root MalType) { v.root.Store(rootValue{root}) }
+
+// Value returns the value of v under the bindings b.
+func (v *Var) Value(b *Bindings) MalType {
+	if b != nil {
+		if val, ok := b.vals[v]; ok {
+			return val
+		}
+	}
+	return v.Root()
+}
+
+// Bindings is a set of values bound to dynamic vars by binding, which also
+// holds the values bound by the bindings enclosing it.  A Bindings is not
+// changed once made, so an evaluator undoes a binding by going back to the
+// Bindings it had before.  The nil *Bindings binds nothing.
+type Bindings struct {
+	vals map[*Var]MalType
+}
+
+// Bind returns the bindings of b with each Var in vals bound to its value.
+func (b *Bindings) Bind(vals map[*Var]MalType) *Bindings {
+	r := &Bindings{vals: make(map[*Var]MalType, len(vals))}
+	if b != nil {
+		for k, v := range b.vals {
+			r.vals[k] = v
+		}
+	}
+	for k, v := range vals {
+		r.vals[k] = v
+	}
+	return r
+}
+
+// Var returns the dynamic var sym names in env.
+func (env *Env) Var(sym *Symbol) (*Var, error) {
+	v, ok := env.lookup(sym)
+	if !ok {
+		return nil, fmt.Errorf("'%s' not found", sym.value)
+	}
+	dv, ok := v.(*Var)
+	if !ok {
+		return nil, fmt.Errorf("can't dynamically bind non-dynamic var: %s", sym.value)
+	}
+	return dv, nil
+}
+
+// Define binds sym to v in env, as def! does: a dynamic var that env
+// already defines keeps its bindings and gets v as its root value.  With
+// dynamic set, sym is made a dynamic var if it is not one already.
+func (env *Env) Define(sym *Symbol, v MalType, dynamic bool) {
+	if dv, ok := env.items[sym].(*Var); ok {
+		dv.SetRoot(v)
+		return
+	}
+	if dynamic {
+		v = NewVar(env.ns, sym, v)
+	}
+	env.items[sym] = v
+}
@@ -0,0 +1,56 @@
+package types
+
+import "testing"
+
+func TestVarBindings(t *testing.T) {
+	v := NewVar(nil, NewSymbol("*test-var*"), NewString("root"))
+	w := NewVar(nil, NewSymbol("*other-var*"), NewString("other root"))
+	check := func(b *Bindings, v *Var, want string) {
+		t.Helper()
+		if got := v.Value(b).(*String).Value(); got != want {
+			t.Errorf("%s: got %s, want %s", v.Print(true), got, want)
+		}
+	}
+
+	var none *Bindings
+	outer := none.Bind(map[*Var]MalType{v: NewString("outer")})
+	inner := outer.Bind(map[*Var]MalType{v: NewString("inner"), w: NewString("bound")})
+	check(none, v, "root")
+	check(outer, v, "outer")
+	check(outer, w, "other root")
+	check(inner, v, "inner")
+	check(inner, w, "bound")
+
+	// Binding does not change the bindings it starts from, and a var's
+	// root value shows through where it is not bound.
+	v.SetRoot(NewString("new root"))
+	w.SetRoot(NewString("new other root"))
+	check(outer, v, "outer")
+	check(outer, w, "new other root")
+	check(none, v, "new root")
+}
+
+func TestLookupBound(t *testing.T) {
+	env := NewEnv(nil)
+	sym := NewSymbol("*d*")
+	env.Define(sym, NewIntFromInt(0), true)
+	dv, err := env.Var(sym)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := (*Bindings)(nil).Bind(map[*Var]MalType{dv: NewIntFromInt(1)})
+	if v, _ := env.Lookup(sym); !v.Equal(NewIntFromInt(0)) {
+		t.Errorf("Lookup: got %s, want the root value 0", v.Print(true))
+	}
+	if v, _ := env.LookupBound(sym, b); !v.Equal(NewIntFromInt(1)) {
+		t.Errorf("LookupBound: got %s, want 1", v.Print(true))
+	}
+	// Redefining the var changes its root value and keeps its bindings.
+	env.Define(sym, NewIntFromInt(2), false)
+	if v, _ := env.FindBound(sym, b); !v.Equal(NewIntFromInt(1)) {
+		t.Errorf("FindBound after def!: got %s, want 1", v.Print(true))
+	}
+	if v, _ := env.Find(sym); !v.Equal(NewIntFromInt(2)) {
+		t.Errorf("Find after def!: got %s, want 2", v.Print(true))
+	}
+}
@@ -0,0 +1,248 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Vector is a persistent vector: a 32-way trie holding all but the last few
+// items plus a tail of up to 32 items.  Updates copy only the path from the
+// root to the changed leaf and share everything else with the original, so
+// Conj, Assoc and Pop are O(log32 n) and never modify an existing Vector.
+type Vector struct {
+	count int
+	shift uint
+	root  *vnode
+	tail  []MalType
+	meta  MalType
+}
+
+const (
+	vbits  = 5
+	vwidth = 1 << vbits
+	vmask  = vwidth - 1
+)
+
+// vnode is a trie node; leaves hold items and the others hold children.
+type vnode struct {
+	children []*vnode
+	items    []MalType
+}
+
+var emptyVNode = &vnode{}
+
+func NewVector(items ...MalType) *Vector {
+	v := &Vector{shift: vbits, root: emptyVNode}
+	for _, item := range items {
+		v = v.Conj(item)
+	}
+	return v
+}
+
+func (vec *Vector) TypeName() string { return "Vector" }
+func (vec *Vector) Print(readably bool) string {
+	vv := []string{}
+	for _, v := range vec.Items() {
+		vv = append(vv, v.Print(readably))
+	}
+	return "[" + strings.Join(vv, " ") + "]"
+}
+
+func (vec *Vector) Meta() MalType { return metaOrNil(vec.meta) }
+func (vec *Vector) WithMeta(meta MalType) MalType {
+	r := *vec
+	r.meta = meta
+	return &r
+}
+
+func (vec *Vector) Length() int { return vec.count }
+
+func (vec *Vector) Equal(other MalType) bool { return seqEqual(vec, other) }
+func (vec *Vector) Hash() uint64             { return seqHash(vec) }
+
+// tailOffset is the index of the first item held in the tail.
+func (vec *Vector) tailOffset() int {
+	if vec.count < vwidth {
+		return 0
+	}
+	return ((vec.count - 1) >> vbits) << vbits
+}
+
+// leaf returns the slice of up to 32 items holding item i.
+func (vec *Vector) leaf(i int) []MalType {
+	if i >= vec.tailOffset() {
+		return vec.tail
+	}
+	node := vec.root
+	for level := vec.shift; level > 0; level -= vbits {
+		node = node.children[(i>>level)&vmask]
+	}
+	return node.items
+}
+
+// Nth returns item i, which must be in range.
+func (vec *Vector) Nth(i int) MalType {
+	return vec.leaf(i)[i&vmask]
+}
+
+// Items returns the items of the vector as a new slice.
+func (vec *Vector) Items() []MalType {
+	r := make([]MalType, 0, vec.count)
+	for i := 0; i < vec.count; i += vwidth {
+		r = append(r, vec.leaf(i)...)
+	}
+	return r
+}
+
+// Conj returns a new Vector with item added at the end.
+func (vec *Vector) Conj(item MalType) *Vector {
+	r := &Vector{count: vec.count + 1, shift: vec.shift, root: vec.root, meta: vec.meta}
+	if vec.count-vec.tailOffset() < vwidth {
+		r.tail = make([]MalType, len(vec.tail), len(vec.tail)+1)
+		copy(r.tail, vec.tail)
+		r.tail = append(r.tail, item)
+		return r
+	}
+
+	// The tail is full: push it into the trie and start a new one.
+	tailNode := &vnode{items: vec.tail}
+	if (vec.count >> vbits) > (1 << vec.shift) {
+		r.root = &vnode{children: []*vnode{vec.root, newPath(vec.shift, tailNode)}}
+		r.shift += vbits
+	} else {
+		r.root = vec.pushTail(vec.shift, vec.root, tailNode)
+	}
+	r.tail = []MalType{item}
+	return r
+}
+
+func newPath(level uint, node *vnode) *vnode {
+	if level == 0 {
+		return node
+	}
+	return &vnode{children: []*vnode{newPath(level-vbits, node)}}
+}
+
+func (vec *Vector) pushTail(level uint, parent *vnode, tailNode *vnode) *vnode {
+	i := ((vec.count - 1) >> level) & vmask
+	r := &vnode{children: make([]*vnode, len(parent.children), i+1)}
+	copy(r.children, parent.children)
+	var child *vnode
+	if level == vbits {
+		child = tailNode
+	} else if i < len(parent.children) {
+		child = vec.pushTail(level-vbits, parent.children[i], tailNode)
+	} else {
+		child = newPath(level-vbits, tailNode)
+	}
+	if i < len(r.children) {
+		r.children[i] = child
+	} else {
+		r.children = append(r.children, child)
+	}
+	return r
+}
+
+// Assoc returns a new Vector with item i replaced by item.  i may also be
+// the length of the vector, in which case item is added at the end.
+func (vec *Vector) Assoc(i int, item MalType) (*Vector, error) {
+	if i == vec.count {
+		return vec.Conj(item), nil
+	}
+	if i < 0 || i > vec.count {
+		return nil, fmt.Errorf("index %d out of range", i)
+	}
+	r := *vec
+	if i >= vec.tailOffset() {
+		r.tail = make([]MalType, len(vec.tail))
+		copy(r.tail, vec.tail)
+		r.tail[i&vmask] = item
+		return &r, nil
+	}
+	r.root = assocNode(vec.shift, vec.root, i, item)
+	return &r, nil
+}
+
+func assocNode(level uint, node *vnode, i int, item MalType) *vnode {
+	if level == 0 {
+		items := make([]MalType, len(node.items))
+		copy(items, node.items)
+		items[i&vmask] = item
+		return &vnode{items: items}
+	}
+	children := make([]*vnode, len(node.children))
+	copy(children, node.children)
+	j := (i >> level) & vmask
+	children[j] = assocNode(level-vbits, children[j], i, item)
+	return &vnode{children: children}
+}
+
+// Pop returns a new Vector without the last item.
+func (vec *Vector) Pop() (*Vector, error) {
+	switch {
+	case vec.count == 0:
+		return nil, errors.New("can't pop an empty vector")
+	case vec.count == 1:
+		return &Vector{shift: vbits, root: emptyVNode, meta: vec.meta}, nil
+	}
+	r := &Vector{count: vec.count - 1, shift: vec.shift, root: vec.root, meta: vec.meta}
+	if vec.count-vec.tailOffset() > 1 {
+		r.tail = vec.tail[: len(vec.tail)-1 : len(vec.tail)-1]
+		return r, nil
+	}
+
+	// The tail becomes empty: the last leaf of the trie becomes the tail.
+	r.tail = vec.leaf(vec.count - 2)
+	root := vec.popTail(vec.shift, vec.root)
+	if root == nil {
+		root = emptyVNode
+	}
+	if vec.shift > vbits && len(root.children) == 1 {
+		root = root.children[0]
+		r.shift -= vbits
+	}
+	r.root = root
+	return r, nil
+}
+
+func (vec *Vector) popTail(level uint, node *vnode) *vnode {
+	i := ((vec.count - 2) >> level) & vmask
+	if level > vbits {
+		child := vec.popTail(level-vbits, node.children[i])
+		if child == nil && i == 0 {
+			return nil
+		}
+		r := &vnode{children: make([]*vnode, i, i+1)}
+		copy(r.children, node.children[:i])
+		if child != nil {
+			r.children = append(r.children, child)
+		}
+		return r
+	}
+	if i == 0 {
+		return nil
+	}
+	return &vnode{children: node.children[:i:i]}
+}
+
+func (vec *Vector) Map(f func(arg MalType, env *Env) (MalType, error), env *Env) (MalType, error) {
+	r, err := mapItems(vec.Items(), f, env)
+	if err != nil {
+		return nil, err
+	}
+	return NewVector(r...), nil
+}
+func (vec *Vector) IsEmpty() (bool, error) { return vec.count == 0, nil }
+func (vec *Vector) First() (MalType, error) {
+	if vec.count == 0 {
+		return NilValue, nil
+	}
+	return vec.Nth(0), nil
+}
+func (vec *Vector) Rest() (Seq, error) {
+	if vec.count == 0 {
+		return NewList(), nil
+	}
+	return NewList(vec.Items()[1:]...), nil
+}
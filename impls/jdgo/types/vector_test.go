@@ -0,0 +1,124 @@
+package types
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// checkVector fails t unless vec holds exactly the ints in model.
+func checkVector(t *testing.T, what string, vec *Vector, model []int) {
+	t.Helper()
+	if vec.Length() != len(model) {
+		t.Fatalf("%s: length %d, want %d", what, vec.Length(), len(model))
+	}
+	items := vec.Items()
+	for i, want := range model {
+		if got := vec.Nth(i); !got.Equal(NewIntFromInt(want)) {
+			t.Fatalf("%s: item %d is %s, want %d", what, i, got.Print(true), want)
+		}
+		if !items[i].Equal(NewIntFromInt(want)) {
+			t.Fatalf("%s: Items()[%d] is %s, want %d", what, i, items[i].Print(true), want)
+		}
+	}
+}
+
+// TestVectorGrowAndShrink conjs items past the tail, the first level of the
+// trie and the second, which makes the root grow twice, then pops them all,
+// checking every version, and the one before it, around each boundary.
+func TestVectorGrowAndShrink(t *testing.T) {
+	const n = vwidth*vwidth*vwidth + 2*vwidth + 1
+	boundary := func(i int) bool {
+		for _, b := range []int{vwidth, vwidth * vwidth, vwidth * vwidth * vwidth} {
+			if i >= b-1 && i <= b+vwidth+1 {
+				return true
+			}
+		}
+		return i < 3 || i%997 == 0 || i >= n-2
+	}
+
+	var model []int
+	vec := NewVector()
+	for i := 0; i < n; i++ {
+		prev := vec
+		vec = vec.Conj(NewIntFromInt(i))
+		model = append(model, i)
+		if boundary(i) {
+			checkVector(t, "conj", vec, model)
+			checkVector(t, "before conj", prev, model[:i])
+		}
+	}
+	if vec.shift != 3*vbits {
+		t.Errorf("a vector of %d items has a trie %d levels deep, want 3", n, vec.shift/vbits)
+	}
+
+	for len(model) > 0 {
+		prev := vec
+		var err error
+		if vec, err = vec.Pop(); err != nil {
+			t.Fatal(err)
+		}
+		model = model[:len(model)-1]
+		if boundary(len(model)) {
+			checkVector(t, "pop", vec, model)
+			checkVector(t, "before pop", prev, append(model, len(model)))
+		}
+	}
+	if vec.shift != vbits || vec.root != emptyVNode {
+		t.Errorf("popping every item left a trie %d levels deep", vec.shift/vbits)
+	}
+	if _, err := vec.Pop(); err == nil {
+		t.Error("popping an empty vector did not fail")
+	}
+}
+
+// TestVectorModel applies random updates to vectors and to slices, keeping
+// every version of both, and checks that they agree.
+func TestVectorModel(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	vecs := []*Vector{NewVector()}
+	models := [][]int{nil}
+	for step := 0; step < 3000; step++ {
+		i := r.Intn(len(vecs))
+		vec, model := vecs[i], models[i]
+		switch op := r.Intn(10); {
+		case op < 5:
+			for k := r.Intn(100); k >= 0; k-- {
+				vec = vec.Conj(NewIntFromInt(step))
+				model = append(model[:len(model):len(model)], step)
+			}
+		case op < 8 && len(model) > 0:
+			j := r.Intn(len(model))
+			var err error
+			if vec, err = vec.Assoc(j, NewIntFromInt(-step)); err != nil {
+				t.Fatal(err)
+			}
+			model = append([]int(nil), model...)
+			model[j] = -step
+		case len(model) > 0:
+			for k := r.Intn(len(model)); k >= 0; k-- {
+				var err error
+				if vec, err = vec.Pop(); err != nil {
+					t.Fatal(err)
+				}
+				model = model[:len(model)-1]
+			}
+		}
+		vecs = append(vecs, vec)
+		models = append(models, model)
+	}
+	for i := range vecs {
+		checkVector(t, "version", vecs[i], models[i])
+	}
+}
+
+func TestVectorAssocOutOfRange(t *testing.T) {
+	vec := NewVector(NewIntFromInt(0))
+	for _, i := range []int{-1, 2} {
+		if _, err := vec.Assoc(i, NilValue); err == nil {
+			t.Errorf("assoc at %d of a vector of 1 item did not fail", i)
+		}
+	}
+	if v, err := vec.Assoc(1, NilValue); err != nil || v.Length() != 2 {
+		t.Errorf("assoc at the length of a vector did not add an item: %v, %v", v, err)
+	}
+}